@@ -0,0 +1,51 @@
+package conniver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"time"
+)
+
+var (
+	// dialContext is replaced in tests to simulate failed connection attempts.
+	dialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+
+	// retryBackoff is the delay before the first retry, doubled for each retry after that up to maxRetryBackoff.
+	retryBackoff    = 100 * time.Millisecond
+	maxRetryBackoff = 5 * time.Second
+)
+
+// DialWithSockStats dials addr, retrying up to retries times with exponential backoff if the
+// dial fails. The successful connection is wrapped like WrapConnWithContext, with Reconnects
+// set to the number of failed attempts before the open report is triggered. If every attempt
+// fails, the error from the last attempt is returned along with the number of attempts made.
+func DialWithSockStats(ctx context.Context, network, addr string, retries int, reportStatsFn ReportStatsFn) (net.Conn, error) {
+	backoff := retryBackoff
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			timer := time.NewTimer(backoff)
+			select {
+			case <-ctx.Done():
+				timer.Stop()
+				return nil, fmt.Errorf("dial %s %s: gave up after %d attempts: %w", network, addr, attempt, ctx.Err())
+			case <-timer.C:
+			}
+			backoff = min(backoff*2, maxRetryBackoff)
+		}
+
+		start := time.Now()
+		conn, err := dialContext(ctx, network, addr)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		w := newConn(ctx, conn, reportStatsFn)
+		w.DialStartedAt = start.UnixNano()
+		w.Reconnects = attempt
+		w.gatherAndReport(Opened)
+		return w, nil
+	}
+	return nil, fmt.Errorf("dial %s %s: failed after %d attempts: %w", network, addr, retries+1, lastErr)
+}
@@ -0,0 +1,128 @@
+package conniver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// refuseFirst makes the first n dials fail with ECONNREFUSED before dialing for real.
+func refuseFirst(t *testing.T, n int) *int {
+	var dials int
+	origDial, origBackoff := dialContext, retryBackoff
+	t.Cleanup(func() { dialContext, retryBackoff = origDial, origBackoff })
+	retryBackoff = time.Millisecond
+	dialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		dials++
+		if dials <= n {
+			return nil, &net.OpError{Op: "dial", Net: network, Err: syscall.ECONNREFUSED}
+		}
+		return origDial(ctx, network, addr)
+	}
+	return &dials
+}
+
+func TestDialWithSockStats(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	dials := refuseFirst(t, 2)
+
+	var opened int
+	conn, err := DialWithSockStats(context.Background(), "tcp", ln.Addr().String(), 3, func(c *Conn, state int) {
+		if state == Opened {
+			opened = c.Reconnects
+		}
+	})
+	if err != nil {
+		t.Fatalf("DialWithSockStats: %v", err)
+	}
+	defer conn.Close()
+
+	if *dials != 3 {
+		t.Errorf("dialed %d times, want 3", *dials)
+	}
+	if c := conn.(*Conn); c.Reconnects != 2 {
+		t.Errorf("Reconnects = %d, want 2", c.Reconnects)
+	}
+	if opened != 2 {
+		t.Errorf("open report saw Reconnects = %d, want 2", opened)
+	}
+	if d := conn.(*Conn).ConnectLatency(); d <= 0 {
+		t.Errorf("ConnectLatency = %v, want > 0", d)
+	}
+}
+
+func TestDialWithSockStatsGivesUp(t *testing.T) {
+	dials := refuseFirst(t, 10)
+
+	conn, err := DialWithSockStats(context.Background(), "tcp", "127.0.0.1:1", 2, nil)
+	if conn != nil {
+		t.Fatalf("expected no connection, got %v", conn)
+	}
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("expected the last dial error, got %v", err)
+	}
+	if *dials != 3 {
+		t.Errorf("dialed %d times, want 3", *dials)
+	}
+}
+
+func TestDialWithSockStatsListener(t *testing.T) {
+	origBackoff := retryBackoff
+	t.Cleanup(func() { retryBackoff = origBackoff })
+	retryBackoff = time.Millisecond
+
+	// A listener that accepts each connection and closes it right away.
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	addr := ln.Addr().String()
+	go func() {
+		for {
+			c, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			_ = c.Close()
+		}
+	}()
+
+	var reason string
+	conn, err := DialWithSockStats(context.Background(), "tcp", addr, 2, func(c *Conn, state int) {
+		if state == Closed {
+			reason = c.CloseReason
+		}
+	})
+	if err != nil {
+		t.Fatalf("DialWithSockStats: %v", err)
+	}
+	if c := conn.(*Conn); c.Reconnects != 0 {
+		t.Errorf("Reconnects = %d, want 0", c.Reconnects)
+	}
+	if _, err := conn.Read(make([]byte, 1)); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF from the closing listener, got %v", err)
+	}
+	_ = conn.Close()
+	if reason != CloseReasonEOF {
+		t.Errorf("CloseReason = %q, want %q", reason, CloseReasonEOF)
+	}
+
+	// Once the listener is gone every attempt is refused.
+	_ = ln.Close()
+	conn, err = DialWithSockStats(context.Background(), "tcp", addr, 2, nil)
+	if conn != nil {
+		t.Fatalf("expected no connection, got %v", conn)
+	}
+	if err == nil || !strings.Contains(err.Error(), "failed after 3 attempts") {
+		t.Errorf("expected 3 refused attempts, got %v", err)
+	}
+}
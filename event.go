@@ -0,0 +1,52 @@
+package conniver
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+)
+
+// Event is a point-in-time record of a connection, suitable for streaming to a collector as
+// newline-delimited JSON from the report callback.
+type Event struct {
+	Type       string        `json:"type"`      // "open", "close", or "sample"
+	Timestamp  int64         `json:"timestamp"` // When the event occurred in unix nanoseconds
+	LocalAddr  string        `json:"localAddr"`
+	RemoteAddr string        `json:"remoteAddr"`
+	TxBytes    int64         `json:"txBytes"`
+	RxBytes    int64         `json:"rxBytes"`
+	Info       *tcpinfo.Info `json:"info,omitempty"`
+}
+
+// Event returns an Event for the given state. Opened events carry OpenedAt and OpenedInfo,
+// Closed events carry ClosedAt and ClosedInfo, and Sampled events carry the current time and
+// the most recent sample gathered by StartSampling.
+func (w *Conn) Event(state int) Event {
+	w.Lock()
+	defer w.Unlock()
+	ev := Event{
+		Type:       StateMap[state],
+		LocalAddr:  addrString(w.LocalAddr()),
+		RemoteAddr: addrString(w.RemoteAddr()),
+		TxBytes:    w.TxBytes,
+		RxBytes:    w.RxBytes,
+	}
+	switch state {
+	case Opened:
+		ev.Timestamp, ev.Info = w.OpenedAt, w.OpenedInfo
+	case Closed:
+		ev.Timestamp, ev.Info = w.ClosedAt, w.ClosedInfo
+	default:
+		ev.Timestamp = time.Now().UnixNano()
+		ev.Info = w.latestSample()
+	}
+	return ev
+}
+
+// MarshalJSON encodes the event with its fields in declaration order, leaving out Info when
+// it is nil.
+func (e Event) MarshalJSON() ([]byte, error) {
+	type event Event
+	return json.Marshal(event(e))
+}
@@ -0,0 +1,66 @@
+package conniver
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+)
+
+func TestEventJSON(t *testing.T) {
+	client, _ := loopbackPair(t)
+	var events []string
+	c := WrapConn(client, func(c *Conn, state int) {
+		b, err := json.Marshal(c.Event(state))
+		if err != nil {
+			t.Errorf("marshal %s event: %v", StateMap[state], err)
+			return
+		}
+		events = append(events, string(b))
+	}).(*Conn)
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_ = c.Close()
+
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(events))
+	}
+	for i, want := range []struct {
+		typ     string
+		ts      int64
+		txBytes float64
+	}{
+		{"open", c.OpenedAt, 0},
+		{"close", c.ClosedAt, 5},
+	} {
+		if !strings.HasPrefix(events[i], `{"type":"`+want.typ+`","timestamp":`) {
+			t.Errorf("event %d does not start with type and timestamp: %s", i, events[i])
+		}
+		var m map[string]any
+		if err := json.Unmarshal([]byte(events[i]), &m); err != nil {
+			t.Fatalf("unmarshal %s: %v", events[i], err)
+		}
+		if m["timestamp"] != float64(want.ts) || m["txBytes"] != want.txBytes || m["localAddr"] != client.LocalAddr().String() {
+			t.Errorf("unexpected %s event: %s", want.typ, events[i])
+		}
+		if _, ok := m["info"].(map[string]any); ok != tcpinfo.Supported() {
+			t.Errorf("%s event info present = %v, want %v", want.typ, ok, tcpinfo.Supported())
+		}
+	}
+}
+
+func TestEventOmitsNilInfo(t *testing.T) {
+	client, _ := loopbackPair(t)
+	c := WrapConn(client, nil).(*Conn)
+	defer c.Close()
+
+	b, err := json.Marshal(c.Event(Sampled))
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	if strings.Contains(string(b), `"info"`) || !strings.Contains(string(b), `"type":"sample"`) {
+		t.Fatalf("unexpected sample event without samples: %s", b)
+	}
+}
@@ -0,0 +1,57 @@
+package conniver
+
+import (
+	"encoding/json"
+	"expvar"
+	"sync"
+)
+
+// eventRing is an expvar.Var holding the most recent events reported through ExpvarReporter.
+type eventRing struct {
+	mu     sync.Mutex
+	events []Event
+	next   int
+	full   bool
+}
+
+func (r *eventRing) add(ev Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events[r.next] = ev
+	r.next = (r.next + 1) % len(r.events)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// String returns the events as a JSON array, oldest first.
+func (r *eventRing) String() string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	events := r.events[:r.next]
+	if r.full {
+		events = append(r.events[r.next:len(r.events):len(r.events)], r.events[:r.next]...)
+	}
+	b, err := json.Marshal(events)
+	if err != nil {
+		return "null"
+	}
+	return string(b)
+}
+
+// PublishExpvar registers an expvar.Var with the given name that holds the ring most recent
+// events reported through ExpvarReporter(name), so they appear at /debug/vars. A ring of less
+// than one keeps a single event. As with expvar.Publish, it panics if name is already registered.
+func PublishExpvar(name string, ring int) {
+	expvar.Publish(name, &eventRing{events: make([]Event, max(ring, 1))})
+}
+
+// ExpvarReporter returns a ReportStatsFn that records an Event for each report in the expvar
+// registered by PublishExpvar with the same name. Reports are dropped until it is published.
+func ExpvarReporter(name string) ReportStatsFn {
+	return func(c *Conn, state int) {
+		if r, ok := expvar.Get(name).(*eventRing); ok {
+			r.add(c.Event(state))
+		}
+	}
+}
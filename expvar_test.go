@@ -0,0 +1,62 @@
+package conniver
+
+import (
+	"encoding/json"
+	"expvar"
+	"testing"
+)
+
+func TestExpvarReporter(t *testing.T) {
+	const name = "conniver_test_events"
+	report := ExpvarReporter(name)
+
+	// Reports before the expvar is published are dropped.
+	client, _ := loopbackPair(t)
+	_ = WrapConn(client, report).Close()
+
+	PublishExpvar(name, 3)
+	var addrs []string
+	for range 2 {
+		client, _ := loopbackPair(t)
+		addrs = append(addrs, client.LocalAddr().String())
+		_ = WrapConn(client, report).Close()
+	}
+
+	v := expvar.Get(name)
+	if v == nil {
+		t.Fatalf("expvar %q is not published", name)
+	}
+	var events []Event
+	if err := json.Unmarshal([]byte(v.String()), &events); err != nil {
+		t.Fatalf("unmarshal %s: %v", v.String(), err)
+	}
+	// Only the last three of the four reports are kept, oldest first.
+	want := []struct{ typ, addr string }{
+		{"close", addrs[0]},
+		{"open", addrs[1]},
+		{"close", addrs[1]},
+	}
+	if len(events) != len(want) {
+		t.Fatalf("got %d events, want %d: %s", len(events), len(want), v.String())
+	}
+	for i, ev := range events {
+		if ev.Type != want[i].typ || ev.LocalAddr != want[i].addr {
+			t.Errorf("event %d = %s from %s, want %s from %s", i, ev.Type, ev.LocalAddr, want[i].typ, want[i].addr)
+		}
+	}
+}
+
+func TestEventRingPartial(t *testing.T) {
+	r := &eventRing{events: make([]Event, 4)}
+	if got := r.String(); got != "[]" {
+		t.Errorf("empty ring = %s, want []", got)
+	}
+	r.add(Event{Type: "open"})
+	var events []Event
+	if err := json.Unmarshal([]byte(r.String()), &events); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if len(events) != 1 || events[0].Type != "open" {
+		t.Errorf("unexpected events %+v", events)
+	}
+}
@@ -0,0 +1,103 @@
+package conniver
+
+import (
+	"net"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+)
+
+// ClientTrace returns an httptrace.ClientTrace that records the phases of an HTTP request sent over
+// w, so they can be lined up with the TCP info gathered for the connection. Attach it with
+// httptrace.WithClientTrace. GotConn starts a new request and clears the times of the previous one,
+// so on a reused connection only the most recent request is kept. Use NewClientTrace instead when
+// the connection is not known until the transport picks one.
+func (w *Conn) ClientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GotConn:              func(httptrace.GotConnInfo) { w.traceGotConn() },
+		WroteRequest:         func(httptrace.WroteRequestInfo) { w.traceWroteRequest() },
+		GotFirstResponseByte: w.traceFirstResponseByte,
+	}
+}
+
+// NewClientTrace returns an httptrace.ClientTrace that records the phases of an HTTP request onto
+// the *Conn the transport hands it, found through GotConnInfo.Conn and any NetConn methods, such as
+// the one on *tls.Conn. Requests over connections that were not wrapped are not recorded. The trace
+// follows a single request and must not be shared between requests.
+func NewClientTrace() *httptrace.ClientTrace {
+	var conn atomic.Pointer[Conn]
+	return &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if w := connFromNetConn(info.Conn); w != nil {
+				conn.Store(w)
+				w.traceGotConn()
+			}
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			if w := conn.Load(); w != nil {
+				w.traceWroteRequest()
+			}
+		},
+		GotFirstResponseByte: func() {
+			if w := conn.Load(); w != nil {
+				w.traceFirstResponseByte()
+			}
+		},
+	}
+}
+
+// TTFB returns the time to first byte of the most recent HTTP request traced with ClientTrace or
+// NewClientTrace: from when the request was written, or when the connection was obtained if the
+// write was not seen, until the first response byte arrived. It returns zero until a response has
+// started.
+func (w *Conn) TTFB() time.Duration {
+	w.Lock()
+	defer w.Unlock()
+	return w.ttfb()
+}
+
+func (w *Conn) ttfb() time.Duration {
+	start := w.WroteRequestAt
+	if start == 0 {
+		start = w.GotConnAt
+	}
+	if start == 0 || w.FirstRespByteAt == 0 {
+		return 0
+	}
+	return time.Duration(w.FirstRespByteAt - start)
+}
+
+func (w *Conn) traceGotConn() {
+	w.Lock()
+	defer w.Unlock()
+	w.GotConnAt = time.Now().UnixNano()
+	w.WroteRequestAt, w.FirstRespByteAt = 0, 0
+}
+
+func (w *Conn) traceWroteRequest() {
+	w.Lock()
+	defer w.Unlock()
+	w.WroteRequestAt = time.Now().UnixNano()
+}
+
+func (w *Conn) traceFirstResponseByte() {
+	w.Lock()
+	defer w.Unlock()
+	w.FirstRespByteAt = time.Now().UnixNano()
+}
+
+// connFromNetConn returns the *Conn wrapped by conn, unwrapping connections such as *tls.Conn
+// through their NetConn method, or nil if there is none.
+func connFromNetConn(conn net.Conn) *Conn {
+	for conn != nil {
+		switch c := conn.(type) {
+		case *Conn:
+			return c
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		default:
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,159 @@
+package conniver
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"sync"
+	"testing"
+	"time"
+)
+
+// slowServer responds after delay, so the TTFB is clearly above loopback noise.
+func slowServer(t *testing.T, delay time.Duration) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(delay)
+		_, _ = io.WriteString(w, "hello")
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func getWithTrace(t *testing.T, cl *http.Client, url string, trace *httptrace.ClientTrace) {
+	t.Helper()
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatalf("new request: %v", err)
+	}
+	resp, err := cl.Do(req)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+}
+
+func TestNewClientTraceTTFB(t *testing.T) {
+	const delay = 50 * time.Millisecond
+	srv := slowServer(t, delay)
+
+	var mu sync.Mutex
+	var opened *Conn
+	tr := NewTransport(&http.Transport{}, func(c *Conn, state int) {
+		if state != Opened {
+			return
+		}
+		mu.Lock()
+		opened = c
+		mu.Unlock()
+	})
+	defer tr.CloseIdleConnections()
+	getWithTrace(t, &http.Client{Transport: tr}, srv.URL, NewClientTrace())
+
+	mu.Lock()
+	defer mu.Unlock()
+	if opened == nil {
+		t.Fatal("expected an open report")
+	}
+	if opened.GotConnAt == 0 || opened.WroteRequestAt == 0 || opened.FirstRespByteAt == 0 {
+		t.Fatalf("expected all trace times, got %d %d %d", opened.GotConnAt, opened.WroteRequestAt, opened.FirstRespByteAt)
+	}
+	if ttfb := opened.TTFB(); ttfb < delay || ttfb > 10*time.Second {
+		t.Errorf("TTFB = %v, want at least %v", ttfb, delay)
+	}
+	if _, ok := opened.ToMap()["ttfb"]; !ok {
+		t.Error("expected ttfb in ToMap")
+	}
+}
+
+func TestConnClientTrace(t *testing.T) {
+	const delay = 20 * time.Millisecond
+	srv := slowServer(t, delay)
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	w := WrapConn(conn, nil).(*Conn)
+	if w.TTFB() != 0 {
+		t.Fatalf("TTFB before any request = %v, want 0", w.TTFB())
+	}
+	tr := &http.Transport{
+		DialContext: func(context.Context, string, string) (net.Conn, error) { return w, nil },
+	}
+	defer tr.CloseIdleConnections()
+	getWithTrace(t, &http.Client{Transport: tr}, srv.URL, w.ClientTrace())
+
+	if ttfb := w.TTFB(); ttfb < delay || ttfb > 10*time.Second {
+		t.Errorf("TTFB = %v, want at least %v", ttfb, delay)
+	}
+}
+
+func TestResetClearsClientTrace(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	w := &Conn{Conn: a, GotConnAt: 1, WroteRequestAt: 2, FirstRespByteAt: 3}
+	if w.TTFB() != 1 {
+		t.Fatalf("TTFB = %v, want 1ns", w.TTFB())
+	}
+	w.Reset(b)
+	if w.TTFB() != 0 || w.GotConnAt != 0 {
+		t.Errorf("expected Reset to clear the trace times")
+	}
+}
+
+func TestConnFromNetConn(t *testing.T) {
+	a, b := net.Pipe()
+	defer a.Close()
+	defer b.Close()
+	if connFromNetConn(a) != nil {
+		t.Error("expected nil for an unwrapped conn")
+	}
+	w := &Conn{Conn: a}
+	if got := connFromNetConn(netConnWrapper{w}); got != w {
+		t.Errorf("connFromNetConn = %p, want %p", got, w)
+	}
+}
+
+// netConnWrapper exposes the wrapped connection through NetConn, like *tls.Conn.
+type netConnWrapper struct{ net.Conn }
+
+func (c netConnWrapper) NetConn() net.Conn { return c.Conn }
+
+func ExampleConn_ClientTrace() {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		_, _ = io.WriteString(w, "hello")
+	}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	w := WrapConn(conn, nil).(*Conn)
+	tr := &http.Transport{
+		DialContext: func(context.Context, string, string) (net.Conn, error) { return w, nil },
+	}
+	defer tr.CloseIdleConnections()
+
+	ctx := httptrace.WithClientTrace(context.Background(), w.ClientTrace())
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, srv.URL, nil)
+	resp, err := (&http.Client{Transport: tr}).Do(req)
+	if err != nil {
+		fmt.Println(err)
+		return
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	_ = resp.Body.Close()
+
+	fmt.Println("ttfb at least 10ms:", w.TTFB() >= 10*time.Millisecond)
+	// Output: ttfb at least 10ms: true
+}
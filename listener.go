@@ -0,0 +1,25 @@
+package conniver
+
+import (
+	"net"
+)
+
+type listener struct {
+	net.Listener
+	reportStats ReportStatsFn
+}
+
+// WrapListener returns a net.Listener that wraps every accepted connection with WrapConn
+// using the given report function. Close and Addr are forwarded to the underlying listener.
+func WrapListener(l net.Listener, reportStatsFn ReportStatsFn) net.Listener {
+	return &listener{Listener: l, reportStats: reportStatsFn}
+}
+
+// Accept waits for the next connection and returns it wrapped.
+func (l *listener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return WrapConn(conn, l.reportStats), nil
+}
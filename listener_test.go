@@ -0,0 +1,53 @@
+package conniver
+
+import (
+	"net"
+	"testing"
+)
+
+func TestWrapListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	opened := make(chan struct{}, 1)
+	wl := WrapListener(ln, func(c *Conn, state int) {
+		if state == Opened {
+			opened <- struct{}{}
+		}
+	})
+	defer wl.Close()
+
+	if wl.Addr().String() != ln.Addr().String() {
+		t.Fatalf("unexpected addr: %s", wl.Addr())
+	}
+
+	client, err := net.Dial("tcp", wl.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+
+	conn, err := wl.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	defer conn.Close()
+
+	if _, ok := conn.(*Conn); !ok {
+		t.Fatalf("expected *Conn, got %T", conn)
+	}
+	select {
+	case <-opened:
+	default:
+		t.Fatal("expected an open report")
+	}
+
+	if err := wl.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if _, err := ln.Accept(); err == nil {
+		t.Fatal("expected underlying listener to be closed")
+	}
+}
@@ -0,0 +1,83 @@
+package conniver
+
+import (
+	"context"
+	"errors"
+	"net"
+)
+
+// ErrNotUDP is returned by PacketConn.ReadFromUDP when the wrapped connection is not a *net.UDPConn.
+var ErrNotUDP = errors.New("not a UDP connection")
+
+// PacketConn wraps a net.PacketConn, such as the *net.UDPConn used by QUIC, tracking bytes and
+// timestamps for ReadFrom and WriteTo. The embedded Conn holds the stats and is what the report
+// function receives. TCP info is never gathered, so OpenedInfo and ClosedInfo stay nil.
+type PacketConn struct {
+	*Conn
+	pc net.PacketConn
+}
+
+// WrapPacketConn wraps the given net.PacketConn, triggers an immediate report in Open state,
+// and returns the wrapped connection. Reads and writes, including ReadFrom, WriteTo, and
+// ReadFromUDP, are tracked and the final report is triggered on Close.
+func WrapPacketConn(pc net.PacketConn, reportStatsFn ReportStatsFn) net.PacketConn {
+	w := newConn(context.Background(), asConn(pc), reportStatsFn)
+	w.supportsTCPInfo = false
+	w.gatherAndReport(Opened)
+	return &PacketConn{Conn: w, pc: pc}
+}
+
+// asConn returns pc as a net.Conn. A *net.UDPConn already is one; anything else is adapted.
+func asConn(pc net.PacketConn) net.Conn {
+	if c, ok := pc.(net.Conn); ok {
+		return c
+	}
+	return packetConnAdapter{pc}
+}
+
+// ReadFrom wraps the underlying ReadFrom method and tracks the bytes received.
+func (p *PacketConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	n, addr, err := p.pc.ReadFrom(b)
+	p.recordRead(n, err)
+	return n, addr, err
+}
+
+// WriteTo wraps the underlying WriteTo method and tracks the bytes sent.
+func (p *PacketConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	n, err := p.pc.WriteTo(b, addr)
+	p.recordWrite(n, err)
+	return n, err
+}
+
+// ReadFromUDP wraps the ReadFromUDP method of a *net.UDPConn and tracks the bytes received.
+// It returns ErrNotUDP if the wrapped connection is not a *net.UDPConn.
+func (p *PacketConn) ReadFromUDP(b []byte) (int, *net.UDPAddr, error) {
+	udpConn, ok := p.pc.(*net.UDPConn)
+	if !ok {
+		return 0, nil, ErrNotUDP
+	}
+	n, addr, err := udpConn.ReadFromUDP(b)
+	p.recordRead(n, err)
+	return n, addr, err
+}
+
+// packetConnAdapter lets a net.PacketConn without Read and Write methods be stored in a Conn.
+// It has no remote address, so Write always fails.
+type packetConnAdapter struct {
+	net.PacketConn
+}
+
+func (a packetConnAdapter) Read(b []byte) (int, error) {
+	n, _, err := a.ReadFrom(b)
+	return n, err
+}
+
+func (a packetConnAdapter) Write(b []byte) (int, error) {
+	return 0, &net.OpError{Op: "write", Net: a.LocalAddr().Network(), Source: a.LocalAddr(), Err: errNoRemoteAddr}
+}
+
+func (a packetConnAdapter) RemoteAddr() net.Addr {
+	return nil
+}
+
+var errNoRemoteAddr = errors.New("missing destination address")
@@ -0,0 +1,97 @@
+package conniver
+
+import (
+	"errors"
+	"net"
+	"testing"
+)
+
+func udpPair(t *testing.T) (a, b *net.UDPConn) {
+	t.Helper()
+	a, err := net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = a.Close() })
+	b, err = net.ListenUDP("udp", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { _ = b.Close() })
+	return a, b
+}
+
+func TestWrapPacketConn(t *testing.T) {
+	a, b := udpPair(t)
+
+	var states []int
+	var closed *Conn
+	pc := WrapPacketConn(a, func(c *Conn, state int) {
+		states = append(states, state)
+		if state == Closed {
+			closed = c
+		}
+	})
+
+	if _, err := pc.WriteTo([]byte("hello"), b.LocalAddr()); err != nil {
+		t.Fatalf("WriteTo: %v", err)
+	}
+	buf := make([]byte, 16)
+	if _, _, err := b.ReadFrom(buf); err != nil {
+		t.Fatalf("peer ReadFrom: %v", err)
+	}
+	if _, err := b.WriteTo([]byte("hi"), a.LocalAddr()); err != nil {
+		t.Fatalf("peer WriteTo: %v", err)
+	}
+	if _, err := b.WriteTo([]byte("there"), a.LocalAddr()); err != nil {
+		t.Fatalf("peer WriteTo: %v", err)
+	}
+	n, addr, err := pc.ReadFrom(buf)
+	if err != nil || string(buf[:n]) != "hi" || addr.String() != b.LocalAddr().String() {
+		t.Fatalf("ReadFrom = %q, %v, %v", buf[:n], addr, err)
+	}
+	n, udpAddr, err := pc.(*PacketConn).ReadFromUDP(buf)
+	if err != nil || string(buf[:n]) != "there" || udpAddr.String() != b.LocalAddr().String() {
+		t.Fatalf("ReadFromUDP = %q, %v, %v", buf[:n], udpAddr, err)
+	}
+	if err := pc.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	if len(states) != 2 || states[0] != Opened || states[1] != Closed {
+		t.Fatalf("unexpected report states: %v", states)
+	}
+	if closed.TxBytes != 5 || closed.RxBytes != 7 || closed.ReadCalls != 2 || closed.WriteCalls != 1 {
+		t.Errorf("tx=%d rx=%d reads=%d writes=%d, want 5/7/2/1", closed.TxBytes, closed.RxBytes, closed.ReadCalls, closed.WriteCalls)
+	}
+	if closed.FirstTxAt == 0 || closed.FirstRxAt == 0 || closed.LastRxAt < closed.FirstRxAt {
+		t.Errorf("unexpected timestamps: %+v", closed)
+	}
+	if closed.OpenedInfo != nil || closed.ClosedInfo != nil || closed.InfoErr != nil {
+		t.Errorf("expected no TCP info, got opened=%v closed=%v err=%v", closed.OpenedInfo, closed.ClosedInfo, closed.InfoErr)
+	}
+	if m := closed.ToMap(); m["remoteAddr"] != "" {
+		t.Errorf("unexpected remoteAddr for an unconnected socket: %v", m["remoteAddr"])
+	}
+}
+
+// plainPacketConn hides the net.Conn methods of a *net.UDPConn.
+type plainPacketConn struct {
+	net.PacketConn
+}
+
+func TestWrapPacketConnNotUDP(t *testing.T) {
+	a, _ := udpPair(t)
+	pc := WrapPacketConn(plainPacketConn{a}, nil).(*PacketConn)
+	defer pc.Close()
+
+	if _, _, err := pc.ReadFromUDP(make([]byte, 1)); !errors.Is(err, ErrNotUDP) {
+		t.Fatalf("ReadFromUDP error = %v, want ErrNotUDP", err)
+	}
+	if _, err := pc.Write([]byte("x")); err == nil {
+		t.Fatalf("expected Write without a destination to fail")
+	}
+	if m := pc.ToMap(); m["localAddr"] != a.LocalAddr().String() {
+		t.Errorf("localAddr = %v, want %v", m["localAddr"], a.LocalAddr())
+	}
+}
@@ -0,0 +1,171 @@
+// Package grpcstats attaches TCP info snapshots to gRPC connections through a stats.Handler.
+package grpcstats
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/runZeroInc/conniver"
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+	"google.golang.org/grpc/stats"
+)
+
+type connInfoKey struct{}
+
+// ConnInfo holds the TCP info snapshots of a gRPC connection, taken at ConnBegin and ConnEnd.
+// A snapshot is nil when TCP info could not be gathered, such as on unsupported platforms.
+type ConnInfo struct {
+	conn *conniver.Conn
+
+	mu    sync.Mutex
+	begin *tcpinfo.Info
+	end   *tcpinfo.Info
+}
+
+// Begin returns the snapshot taken when the connection began.
+func (c *ConnInfo) Begin() *tcpinfo.Info {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.begin
+}
+
+// End returns the snapshot taken when the connection ended, or nil while it is still open.
+func (c *ConnInfo) End() *tcpinfo.Info {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.end
+}
+
+// FromContext returns the ConnInfo stored by Handler in a connection context, or nil if there is
+// none. On the server, RPC contexts derive from the connection context, so interceptors and
+// handlers can use it to reach the TCP info of the connection serving the RPC.
+func FromContext(ctx context.Context) *ConnInfo {
+	ci, _ := ctx.Value(connInfoKey{}).(*ConnInfo)
+	return ci
+}
+
+// Handler is a stats.Handler that snapshots the TCP info of each gRPC connection at ConnBegin and
+// ConnEnd. gRPC does not pass the net.Conn to stats handlers, so connections must come from the
+// Listener or Dialer of the same Handler, which wrap them with conniver.WrapConn and match them up
+// by address in TagConn. Connections from elsewhere get a ConnInfo without snapshots.
+type Handler struct {
+	// OnConnEnd, if set, is called with the ConnInfo of each connection after its ConnEnd snapshot.
+	OnConnEnd func(*ConnInfo)
+
+	mu    sync.Mutex
+	conns map[connKey]*conniver.Conn
+}
+
+var _ stats.Handler = (*Handler)(nil)
+
+type connKey struct {
+	local, remote string
+}
+
+func newConnKey(local, remote net.Addr) connKey {
+	k := connKey{}
+	if local != nil {
+		k.local = local.String()
+	}
+	if remote != nil {
+		k.remote = remote.String()
+	}
+	return k
+}
+
+// NewHandler returns a Handler with no connections.
+func NewHandler() *Handler {
+	return &Handler{conns: map[connKey]*conniver.Conn{}}
+}
+
+// Listener wraps ln so that accepted connections are tracked, for use with grpc.Server.Serve.
+func (h *Handler) Listener(ln net.Listener) net.Listener {
+	return conniver.WrapListener(ln, h.track)
+}
+
+// Dialer wraps dial, or a default net.Dialer if nil, so that dialed connections are tracked, for
+// use with grpc.WithContextDialer.
+func (h *Handler) Dialer(dial func(ctx context.Context, addr string) (net.Conn, error)) func(ctx context.Context, addr string) (net.Conn, error) {
+	if dial == nil {
+		var d net.Dialer
+		dial = func(ctx context.Context, addr string) (net.Conn, error) {
+			return d.DialContext(ctx, "tcp", addr)
+		}
+	}
+	return func(ctx context.Context, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, addr)
+		if err != nil {
+			return nil, err
+		}
+		return conniver.WrapConnWithContext(ctx, conn, h.track), nil
+	}
+}
+
+// track registers connections as they open until TagConn claims them, and forgets any that close
+// without being claimed.
+func (h *Handler) track(c *conniver.Conn, state int) {
+	key := newConnKey(c.LocalAddr(), c.RemoteAddr())
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch state {
+	case conniver.Opened:
+		h.conns[key] = c
+	case conniver.Closed:
+		if h.conns[key] == c {
+			delete(h.conns, key)
+		}
+	}
+}
+
+// TagConn stores a ConnInfo for the connection in the returned context.
+func (h *Handler) TagConn(ctx context.Context, info *stats.ConnTagInfo) context.Context {
+	key := newConnKey(info.LocalAddr, info.RemoteAddr)
+	h.mu.Lock()
+	conn := h.conns[key]
+	delete(h.conns, key)
+	h.mu.Unlock()
+	return context.WithValue(ctx, connInfoKey{}, &ConnInfo{conn: conn})
+}
+
+// HandleConn takes the ConnBegin and ConnEnd snapshots.
+func (h *Handler) HandleConn(ctx context.Context, s stats.ConnStats) {
+	ci := FromContext(ctx)
+	if ci == nil || ci.conn == nil {
+		return
+	}
+	switch s.(type) {
+	case *stats.ConnBegin:
+		info := snapshot(ci.conn)
+		ci.mu.Lock()
+		ci.begin = info
+		ci.mu.Unlock()
+	case *stats.ConnEnd:
+		info := snapshot(ci.conn)
+		ci.mu.Lock()
+		ci.end = info
+		ci.mu.Unlock()
+		if h.OnConnEnd != nil {
+			h.OnConnEnd(ci)
+		}
+	}
+}
+
+// snapshot returns the current TCP info of c, falling back to the snapshot taken by Close once
+// the connection is closed.
+func snapshot(c *conniver.Conn) *tcpinfo.Info {
+	if info, _ := c.Snapshot(); info != nil {
+		return info
+	}
+	c.Lock()
+	defer c.Unlock()
+	return c.ClosedInfo
+}
+
+// TagRPC returns ctx unchanged.
+func (h *Handler) TagRPC(ctx context.Context, _ *stats.RPCTagInfo) context.Context {
+	return ctx
+}
+
+// HandleRPC does nothing; RPCs reach the connection snapshots through FromContext.
+func (h *Handler) HandleRPC(context.Context, stats.RPCStats) {}
@@ -0,0 +1,91 @@
+package grpcstats
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/stats"
+)
+
+func TestHandler(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+
+	h := NewHandler()
+	ended := make(chan *ConnInfo, 1)
+	h.OnConnEnd = func(ci *ConnInfo) { ended <- ci }
+
+	rpcInfo := make(chan *tcpinfo.Info, 1)
+	srv := grpc.NewServer(
+		grpc.StatsHandler(h),
+		grpc.UnaryInterceptor(func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+			var begin *tcpinfo.Info
+			if ci := FromContext(ctx); ci != nil {
+				begin = ci.Begin()
+			}
+			rpcInfo <- begin
+			return handler(ctx, req)
+		}),
+	)
+	healthpb.RegisterHealthServer(srv, health.NewServer())
+	go func() { _ = srv.Serve(h.Listener(ln)) }()
+	defer srv.Stop()
+
+	client, err := grpc.NewClient(ln.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("client: %v", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if _, err := healthpb.NewHealthClient(client).Check(ctx, &healthpb.HealthCheckRequest{}); err != nil {
+		t.Fatalf("check: %v", err)
+	}
+	if begin := <-rpcInfo; begin == nil || begin.State != "ESTABLISHED" {
+		t.Errorf("interceptor saw ConnBegin info %v, want an established connection", begin)
+	}
+	_ = client.Close()
+
+	select {
+	case ci := <-ended:
+		if ci.End() == nil {
+			t.Fatalf("no TCP info captured at ConnEnd")
+		}
+		if ci.Begin() == nil {
+			t.Errorf("no TCP info captured at ConnBegin")
+		}
+	case <-ctx.Done():
+		t.Fatalf("ConnEnd was not handled")
+	}
+}
+
+func TestHandlerUntrackedConn(t *testing.T) {
+	h := NewHandler()
+	ctx := h.TagConn(context.Background(), &stats.ConnTagInfo{
+		LocalAddr:  &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 1},
+		RemoteAddr: &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: 2},
+	})
+	h.HandleConn(ctx, &stats.ConnBegin{})
+	h.HandleConn(ctx, &stats.ConnEnd{})
+	ci := FromContext(ctx)
+	if ci == nil {
+		t.Fatal("TagConn did not store a ConnInfo")
+	}
+	if ci.Begin() != nil || ci.End() != nil {
+		t.Errorf("unexpected snapshots for an untracked connection")
+	}
+	if FromContext(context.Background()) != nil {
+		t.Errorf("FromContext found a ConnInfo in an empty context")
+	}
+}
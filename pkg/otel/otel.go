@@ -0,0 +1,130 @@
+// Package otel exports TCP info as OpenTelemetry metrics and span attributes.
+package otel
+
+import (
+	"context"
+	"net"
+	"sync"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+)
+
+// MetricPrefix is prepended to the tcpi field name to form each instrument name,
+// matching the Prometheus metric names.
+const MetricPrefix = "tcpinfo_"
+
+// ErrNoSyscallConn is returned by Record when no TCP socket can be found beneath the connection.
+// It is the same error as tcpinfo.ErrNotTCP.
+var ErrNoSyscallConn = tcpinfo.ErrNotTCP
+
+// TCPInfoRecorder holds the latest TCP info snapshot for each recorded connection and reports
+// them through asynchronous instruments whenever the meter is collected.
+type TCPInfoRecorder struct {
+	mu           sync.Mutex
+	snapshots    map[net.Conn]snapshot
+	instruments  []instrument
+	registration metric.Registration
+}
+
+type snapshot struct {
+	values map[string]any
+	attrs  metric.MeasurementOption
+}
+
+type instrument struct {
+	key     string // SysInfo.ToMap key
+	counter metric.Int64ObservableCounter
+	gauge   metric.Float64ObservableGauge
+}
+
+// RegisterTCPInfoMetrics creates an instrument for every numeric tcpi field of the platform SysInfo.
+// Fields tagged prom_type=counter become int64 monotonic counters; all others become float64 gauges,
+// with durations reported in seconds.
+func RegisterTCPInfoMetrics(meter metric.Meter) (*TCPInfoRecorder, error) {
+	r := &TCPInfoRecorder{snapshots: map[net.Conn]snapshot{}}
+
+	var observables []metric.Observable
+	for _, f := range tcpinfo.FieldMetadata() {
+		if !f.Numeric || f.Key == "" {
+			continue
+		}
+		var err error
+		name := MetricPrefix + f.PromName
+		inst := instrument{key: f.Key}
+		if f.PromType == "counter" {
+			inst.counter, err = meter.Int64ObservableCounter(name, metric.WithDescription(f.Help))
+			observables = append(observables, inst.counter)
+		} else {
+			gaugeOpts := []metric.Float64ObservableGaugeOption{metric.WithDescription(f.Help)}
+			if f.Unit == "seconds" {
+				gaugeOpts = append(gaugeOpts, metric.WithUnit("s"))
+			}
+			inst.gauge, err = meter.Float64ObservableGauge(name, gaugeOpts...)
+			observables = append(observables, inst.gauge)
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.instruments = append(r.instruments, inst)
+	}
+
+	reg, err := meter.RegisterCallback(r.observe, observables...)
+	if err != nil {
+		return nil, err
+	}
+	r.registration = reg
+	return r, nil
+}
+
+// Record takes a TCP info snapshot of conn and stores it, replacing any earlier snapshot of the
+// same connection, to be reported with attrs on the next collection. Partial information is
+// stored when available; an error is only returned if no information could be gathered.
+// Wrapped connections, such as *conniver.Conn and *tls.Conn, are unwrapped automatically.
+func (r *TCPInfoRecorder) Record(conn net.Conn, attrs []attribute.KeyValue) error {
+	info, err := tcpinfo.GetTCPInfoFromConn(conn)
+	if info == nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.snapshots[conn] = snapshot{values: info.ToMap(), attrs: metric.WithAttributes(attrs...)}
+	r.mu.Unlock()
+	return nil
+}
+
+// Forget stops reporting the snapshot recorded for conn.
+func (r *TCPInfoRecorder) Forget(conn net.Conn) {
+	r.mu.Lock()
+	delete(r.snapshots, conn)
+	r.mu.Unlock()
+}
+
+// Unregister stops reporting all instruments.
+func (r *TCPInfoRecorder) Unregister() error {
+	return r.registration.Unregister()
+}
+
+func (r *TCPInfoRecorder) observe(_ context.Context, o metric.Observer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, s := range r.snapshots {
+		for _, inst := range r.instruments {
+			v, ok := s.values[inst.key]
+			if !ok {
+				continue
+			}
+			if inst.counter != nil {
+				if n, ok := tcpinfo.CounterValue(v); ok {
+					o.ObserveInt64(inst.counter, n, s.attrs)
+				}
+				continue
+			}
+			if f, ok := tcpinfo.MetricValue(v); ok {
+				o.ObserveFloat64(inst.gauge, f, s.attrs)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package otel
+
+import (
+	"context"
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+	"go.opentelemetry.io/otel/attribute"
+	sdkmetric "go.opentelemetry.io/otel/sdk/metric"
+	"go.opentelemetry.io/otel/sdk/metric/metricdata"
+)
+
+func TestRecorder(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	reader := sdkmetric.NewManualReader()
+	provider := sdkmetric.NewMeterProvider(sdkmetric.WithReader(reader))
+	defer provider.Shutdown(context.Background())
+
+	rec, err := RegisterTCPInfoMetrics(provider.Meter("tcpinfo"))
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := rec.Record(conn, []attribute.KeyValue{attribute.String("peer", "test")}); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	var rm metricdata.ResourceMetrics
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	got := map[string]metricdata.Aggregation{}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			got[m.Name] = m.Data
+		}
+	}
+
+	rtt, ok := got[MetricPrefix+"rtt"].(metricdata.Gauge[float64])
+	if !ok || len(rtt.DataPoints) != 1 {
+		t.Fatalf("missing rtt gauge: %#v", got[MetricPrefix+"rtt"])
+	}
+	if v, _ := rtt.DataPoints[0].Attributes.Value("peer"); v.AsString() != "test" {
+		t.Errorf("unexpected attributes: %v", rtt.DataPoints[0].Attributes)
+	}
+
+	if runtime.GOOS == "linux" {
+		sent, ok := got[MetricPrefix+"bytes_sent"].(metricdata.Sum[int64])
+		if !ok || len(sent.DataPoints) != 1 || !sent.IsMonotonic {
+			t.Fatalf("missing bytes_sent counter: %#v", got[MetricPrefix+"bytes_sent"])
+		}
+		if sent.DataPoints[0].Value < 5 {
+			t.Errorf("bytes_sent = %d, want at least 5", sent.DataPoints[0].Value)
+		}
+	}
+
+	// Forgotten connections are no longer reported
+	rec.Forget(conn)
+	rm = metricdata.ResourceMetrics{}
+	if err := reader.Collect(context.Background(), &rm); err != nil {
+		t.Fatalf("collect: %v", err)
+	}
+	for _, sm := range rm.ScopeMetrics {
+		for _, m := range sm.Metrics {
+			if g, ok := m.Data.(metricdata.Gauge[float64]); ok && len(g.DataPoints) > 0 {
+				t.Fatalf("%s still reported after Forget", m.Name)
+			}
+		}
+	}
+}
+
+func TestRecordNotSyscallConn(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	provider := sdkmetric.NewMeterProvider()
+	rec, err := RegisterTCPInfoMetrics(provider.Meter("tcpinfo"))
+	if err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	if err := rec.Record(client, nil); err != ErrNoSyscallConn {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
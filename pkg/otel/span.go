@@ -0,0 +1,53 @@
+package otel
+
+import (
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Span attribute keys set by AnnotateSpan.
+const (
+	AttrRTT         = attribute.Key("net.tcp.rtt_ms")
+	AttrRetransmits = attribute.Key("net.tcp.retransmits")
+	AttrCwnd        = attribute.Key("net.tcp.cwnd")
+	AttrState       = attribute.Key("net.tcp.state")
+	AttrWarnings    = attribute.Key("net.tcp.warnings")
+)
+
+// WarningsEvent is the name of the span event added by AnnotateSpan when the connection
+// retransmitted or the platform reported warnings such as bufferbloat.
+const WarningsEvent = "net.tcp.warnings"
+
+// AnnotateSpan sets the RTT in milliseconds, retransmissions, congestion window in segments, and
+// state from info as attributes on span, such as when a connection closes. If any segments were
+// retransmitted or info.Sys reports warnings, a WarningsEvent listing them is added as well. It
+// does nothing if span or info is nil or the span is not recording.
+func AnnotateSpan(span trace.Span, info *tcpinfo.Info) {
+	if span == nil || info == nil || !span.IsRecording() {
+		return
+	}
+
+	attrs := []attribute.KeyValue{
+		AttrRTT.Float64(float64(info.RTT.Microseconds()) / 1000),
+		AttrRetransmits.Int64(int64(info.Retransmits)),
+	}
+	if cwnd, ok := info.CongestionWindow(); ok {
+		attrs = append(attrs, AttrCwnd.Int64(int64(cwnd)))
+	}
+	if info.State != "" {
+		attrs = append(attrs, AttrState.String(info.State))
+	}
+	span.SetAttributes(attrs...)
+
+	var warns []string
+	if info.Sys != nil {
+		warns = info.Sys.Warnings()
+	}
+	if info.Retransmits > 0 || len(warns) > 0 {
+		span.AddEvent(WarningsEvent, trace.WithAttributes(
+			AttrRetransmits.Int64(int64(info.Retransmits)),
+			AttrWarnings.StringSlice(warns),
+		))
+	}
+}
@@ -0,0 +1,74 @@
+package otel
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestAnnotateSpan(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer provider.Shutdown(context.Background())
+
+	_, span := provider.Tracer("test").Start(context.Background(), "request")
+	AnnotateSpan(span, &tcpinfo.Info{
+		State:        "ESTABLISHED",
+		RTT:          12500 * time.Microsecond,
+		TxWindowSegs: 10,
+		Retransmits:  3,
+	})
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("recorded %d spans, want 1", len(spans))
+	}
+	attrs := map[attribute.Key]attribute.Value{}
+	for _, kv := range spans[0].Attributes() {
+		attrs[kv.Key] = kv.Value
+	}
+	if got := attrs[AttrRTT].AsFloat64(); got != 12.5 {
+		t.Errorf("%s = %v, want 12.5", AttrRTT, got)
+	}
+	if got := attrs[AttrRetransmits].AsInt64(); got != 3 {
+		t.Errorf("%s = %v, want 3", AttrRetransmits, got)
+	}
+	if got := attrs[AttrCwnd].AsInt64(); got != 10 {
+		t.Errorf("%s = %v, want 10", AttrCwnd, got)
+	}
+	if got := attrs[AttrState].AsString(); got != "ESTABLISHED" {
+		t.Errorf("%s = %q, want ESTABLISHED", AttrState, got)
+	}
+	if events := spans[0].Events(); len(events) != 1 || events[0].Name != WarningsEvent {
+		t.Errorf("events = %v, want a single %s event", events, WarningsEvent)
+	}
+}
+
+func TestAnnotateSpanNoop(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	defer provider.Shutdown(context.Background())
+
+	AnnotateSpan(nil, &tcpinfo.Info{RTT: time.Millisecond})
+	_, span := provider.Tracer("test").Start(context.Background(), "request")
+	AnnotateSpan(span, nil)
+	AnnotateSpan(span, &tcpinfo.Info{RTT: time.Millisecond})
+	span.End()
+
+	spans := recorder.Ended()
+	if len(spans) != 1 {
+		t.Fatalf("recorded %d spans, want 1", len(spans))
+	}
+	if events := spans[0].Events(); len(events) != 0 {
+		t.Errorf("events = %v, want none without retransmits or warnings", events)
+	}
+	if n := len(spans[0].Attributes()); n != 2 {
+		t.Errorf("recorded %d attributes, want only the RTT and retransmits", n)
+	}
+}
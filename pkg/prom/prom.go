@@ -0,0 +1,160 @@
+// Package prom exports wrapped connections as Prometheus metrics.
+package prom
+
+import (
+	"errors"
+	"net"
+	"reflect"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/runZeroInc/conniver"
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+)
+
+// ConnCollector is a prometheus.Collector that reports the TCP info and the wrapper counters of
+// each tracked connection. Every scrape takes a fresh snapshot, so no sampling is needed.
+type ConnCollector struct {
+	txBytes      *prometheus.Desc
+	rxBytes      *prometheus.Desc
+	reconnects   *prometheus.Desc
+	scrapeErrors *prometheus.Desc
+	tracked      *prometheus.Desc
+	fields       []field
+
+	mu           sync.Mutex
+	conns        map[*conniver.Conn][]string
+	scrapeErrCnt uint64
+}
+
+// field is a numeric tcpi field of the platform SysInfo and the metric it is reported as.
+type field struct {
+	index     int
+	desc      *prometheus.Desc
+	valueType prometheus.ValueType
+}
+
+// NewConnCollector returns a collector whose metric names are prefix followed by the tcpi field
+// name, such as tcpinfo_rtt for a prefix of "tcpinfo_", along with the prefix+"tx_bytes_total",
+// prefix+"rx_bytes_total", and prefix+"reconnects_total" counters from the Conn itself. Fields
+// tagged prom_type=counter are reported as counters and all others as gauges, with durations in
+// seconds. The constLabels are added to every metric, and labelNames name the values passed to Track.
+//
+// The collector also reports on itself, with only the constLabels: prefix+"scrape_errors_total"
+// counts the snapshots that failed, and prefix+"tracked_connections" is the number of connections
+// still tracked after the scrape.
+func NewConnCollector(prefix string, constLabels prometheus.Labels, labelNames ...string) *ConnCollector {
+	c := &ConnCollector{
+		txBytes:      prometheus.NewDesc(prefix+"tx_bytes_total", "Bytes written through the wrapped connection.", labelNames, constLabels),
+		rxBytes:      prometheus.NewDesc(prefix+"rx_bytes_total", "Bytes read through the wrapped connection.", labelNames, constLabels),
+		reconnects:   prometheus.NewDesc(prefix+"reconnects_total", "Times the wrapped connection was reconnected.", labelNames, constLabels),
+		scrapeErrors: prometheus.NewDesc(prefix+"scrape_errors_total", "Snapshots of tracked connections that failed.", nil, constLabels),
+		tracked:      prometheus.NewDesc(prefix+"tracked_connections", "Connections currently tracked by the collector.", nil, constLabels),
+		conns:        map[*conniver.Conn][]string{},
+	}
+
+	t := reflect.TypeOf(tcpinfo.SysInfo{})
+	for _, f := range tcpinfo.FieldMetadata() {
+		sf, ok := t.FieldByName(f.GoName)
+		if !ok || !f.Numeric {
+			continue
+		}
+		valueType := prometheus.GaugeValue
+		if f.PromType == "counter" {
+			valueType = prometheus.CounterValue
+		}
+		c.fields = append(c.fields, field{
+			index:     sf.Index[0],
+			desc:      prometheus.NewDesc(prefix+f.PromName, f.Help, labelNames, constLabels),
+			valueType: valueType,
+		})
+	}
+	return c
+}
+
+// Track starts reporting conn with the given label values, one for each label name passed to
+// NewConnCollector. Tracking a connection again replaces its label values. A mismatched number of
+// values is reported as an invalid metric when collected.
+func (c *ConnCollector) Track(conn *conniver.Conn, labels []string) {
+	c.mu.Lock()
+	c.conns[conn] = labels
+	c.mu.Unlock()
+}
+
+// Untrack stops reporting conn.
+func (c *ConnCollector) Untrack(conn *conniver.Conn) {
+	c.mu.Lock()
+	delete(c.conns, conn)
+	c.mu.Unlock()
+}
+
+// Describe implements prometheus.Collector.
+func (c *ConnCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.txBytes
+	ch <- c.rxBytes
+	ch <- c.reconnects
+	ch <- c.scrapeErrors
+	ch <- c.tracked
+	for _, f := range c.fields {
+		ch <- f.desc
+	}
+}
+
+// Collect implements prometheus.Collector. The TCP info of each connection is read through the
+// connection itself rather than a cached descriptor, so a closed connection can never be confused
+// with a new socket that reused its descriptor. Closed connections are untracked instead of
+// reported. Connections whose TCP info cannot be read for other reasons, such as those that are
+// not TCP, only report the wrapper counters. Every failed snapshot, including that of a closed
+// connection, counts as a scrape error, except on connections where TCP info is not supported.
+func (c *ConnCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	conns := make(map[*conniver.Conn][]string, len(c.conns))
+	for conn, labels := range c.conns {
+		conns[conn] = labels
+	}
+	c.mu.Unlock()
+
+	var scrapeErrs uint64
+	for conn, labels := range conns {
+		info, err := conn.Snapshot()
+		if err != nil && !errors.Is(err, conniver.ErrUnsupported) && !errors.Is(err, conniver.ErrNotTCP) {
+			scrapeErrs++
+		}
+		if errors.Is(err, net.ErrClosed) {
+			c.Untrack(conn)
+			continue
+		}
+
+		conn.Lock()
+		txBytes, rxBytes, reconnects := conn.TxBytes, conn.RxBytes, conn.Reconnects
+		conn.Unlock()
+
+		m, err := prometheus.NewConstMetric(c.txBytes, prometheus.CounterValue, float64(txBytes), labels...)
+		if err != nil {
+			ch <- prometheus.NewInvalidMetric(c.txBytes, err)
+			continue
+		}
+		ch <- m
+		ch <- prometheus.MustNewConstMetric(c.rxBytes, prometheus.CounterValue, float64(rxBytes), labels...)
+		ch <- prometheus.MustNewConstMetric(c.reconnects, prometheus.CounterValue, float64(reconnects), labels...)
+
+		if info == nil || info.Sys == nil {
+			continue
+		}
+		sys := reflect.ValueOf(info.Sys).Elem()
+		for _, f := range c.fields {
+			v, ok := tcpinfo.MetricValue(sys.Field(f.index).Interface())
+			if !ok {
+				continue
+			}
+			ch <- prometheus.MustNewConstMetric(f.desc, f.valueType, v, labels...)
+		}
+	}
+
+	c.mu.Lock()
+	c.scrapeErrCnt += scrapeErrs
+	scrapeErrCnt, tracked := c.scrapeErrCnt, len(c.conns)
+	c.mu.Unlock()
+	ch <- prometheus.MustNewConstMetric(c.scrapeErrors, prometheus.CounterValue, float64(scrapeErrCnt))
+	ch <- prometheus.MustNewConstMetric(c.tracked, prometheus.GaugeValue, float64(tracked))
+}
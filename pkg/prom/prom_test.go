@@ -0,0 +1,188 @@
+package prom
+
+import (
+	"io"
+	"net"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/runZeroInc/conniver"
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+)
+
+func TestConnCollector(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer c.Close()
+		_, _ = io.Copy(io.Discard, c)
+	}()
+	nc, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	conn := conniver.WrapConn(nc, nil).(*conniver.Conn)
+	defer conn.Close()
+	if _, err := conn.Write([]byte("hello")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	collector := NewConnCollector("test_", prometheus.Labels{"service": "api"}, "peer")
+	reg := prometheus.NewPedanticRegistry()
+	if err := reg.Register(collector); err != nil {
+		t.Fatalf("register: %v", err)
+	}
+	collector.Track(conn, []string{"upstream"})
+
+	families := gather(t, reg)
+	txBytes := families["test_tx_bytes_total"]
+	if txBytes == nil || txBytes.GetType() != dto.MetricType_COUNTER || txBytes.Metric[0].GetCounter().GetValue() != 5 {
+		t.Fatalf("test_tx_bytes_total = %v, want a counter of 5", txBytes)
+	}
+	labels := map[string]string{}
+	for _, l := range txBytes.Metric[0].GetLabel() {
+		labels[l.GetName()] = l.GetValue()
+	}
+	if labels["service"] != "api" || labels["peer"] != "upstream" {
+		t.Errorf("labels = %v, want service=api and peer=upstream", labels)
+	}
+	state := families["test_state"]
+	if state == nil || state.GetType() != dto.MetricType_GAUGE || state.Metric[0].GetGauge().GetValue() == 0 {
+		t.Errorf("test_state = %v, want a non-zero TCP info gauge", state)
+	}
+
+	collector.Untrack(conn)
+	families = gather(t, reg)
+	if mf := families["test_tx_bytes_total"]; mf != nil {
+		t.Errorf("test_tx_bytes_total = %v after Untrack, want none", mf)
+	}
+	if got := families["test_tracked_connections"].GetMetric()[0].GetGauge().GetValue(); got != 0 {
+		t.Errorf("test_tracked_connections = %v after Untrack, want 0", got)
+	}
+}
+
+func TestConnCollectorLabelMismatch(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	conn := conniver.WrapConn(client, nil).(*conniver.Conn)
+	defer conn.Close()
+
+	collector := NewConnCollector("test_", nil, "peer")
+	reg := prometheus.NewRegistry()
+	reg.MustRegister(collector)
+	collector.Track(conn, nil)
+	if _, err := reg.Gather(); err == nil {
+		t.Errorf("expected an error gathering a connection without label values")
+	}
+}
+
+func gather(t *testing.T, reg *prometheus.Registry) map[string]*dto.MetricFamily {
+	t.Helper()
+	mfs, err := reg.Gather()
+	if err != nil {
+		t.Fatalf("gather: %v", err)
+	}
+	families := map[string]*dto.MetricFamily{}
+	for _, mf := range mfs {
+		families[mf.GetName()] = mf
+	}
+	return families
+}
+
+func TestConnCollectorEvictsClosed(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	dial := func() *conniver.Conn {
+		nc, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		return conniver.WrapConn(nc, nil).(*conniver.Conn)
+	}
+
+	collector := NewConnCollector("test_", nil, "peer")
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(collector)
+
+	closed := dial()
+	collector.Track(closed, []string{"closed"})
+	if err := closed.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	// The new socket is likely to reuse the descriptor of the closed one.
+	open := dial()
+	defer open.Close()
+	collector.Track(open, []string{"open"})
+
+	families := gather(t, reg)
+	for _, name := range []string{"test_tx_bytes_total", "test_state"} {
+		mf := families[name]
+		if mf == nil || len(mf.Metric) != 1 || mf.Metric[0].GetLabel()[0].GetValue() != "open" {
+			t.Errorf("%s = %v, want only the open connection", name, mf)
+		}
+	}
+	collector.mu.Lock()
+	_, tracked := collector.conns[closed]
+	collector.mu.Unlock()
+	if tracked {
+		t.Errorf("closed connection is still tracked")
+	}
+}
+
+func TestConnCollectorSelfMetrics(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	nc, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	conn := conniver.WrapConn(nc, nil).(*conniver.Conn)
+
+	collector := NewConnCollector("test_", prometheus.Labels{"service": "api"})
+	reg := prometheus.NewPedanticRegistry()
+	reg.MustRegister(collector)
+	collector.Track(conn, nil)
+
+	selfMetrics := func() (scrapeErrors, tracked float64) {
+		families := gather(t, reg)
+		return families["test_scrape_errors_total"].GetMetric()[0].GetCounter().GetValue(),
+			families["test_tracked_connections"].GetMetric()[0].GetGauge().GetValue()
+	}
+	if scrapeErrors, tracked := selfMetrics(); scrapeErrors != 0 || tracked != 1 {
+		t.Errorf("scrape_errors_total=%v tracked_connections=%v, want 0 and 1", scrapeErrors, tracked)
+	}
+
+	// Snapshots of a closed connection fail, so it is counted and untracked
+	if err := conn.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if scrapeErrors, tracked := selfMetrics(); scrapeErrors != 1 || tracked != 0 {
+		t.Errorf("scrape_errors_total=%v tracked_connections=%v after close, want 1 and 0", scrapeErrors, tracked)
+	}
+	if scrapeErrors, _ := selfMetrics(); scrapeErrors != 1 {
+		t.Errorf("scrape_errors_total = %v on a later scrape, want it to stay at 1", scrapeErrors)
+	}
+}
@@ -0,0 +1,28 @@
+// Package sockdiag lists TCP sockets along with their TCP info using the Linux
+// sock_diag netlink interface, without needing a file descriptor for each socket.
+package sockdiag
+
+import (
+	"errors"
+	"net/netip"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+)
+
+// ErrUnsupported is returned by Dump on platforms without sock_diag.
+var ErrUnsupported = errors.New("sock_diag is only supported on Linux")
+
+// Socket describes a TCP socket found by Dump.
+type Socket struct {
+	Local  netip.AddrPort
+	Remote netip.AddrPort
+	UID    uint32           // Owner of the socket
+	Inode  uint64           // Inode of the socket, as found in /proc/<pid>/fd
+	Info   *tcpinfo.SysInfo // TCP info reported by the kernel, or nil if unavailable
+}
+
+// Options selects which sockets Dump returns.
+type Options struct {
+	Family int     // AF_INET or AF_INET6, or zero for both
+	States []uint8 // TCP states such as tcpinfo.TCP_ESTABLISHED, or empty for all states
+}
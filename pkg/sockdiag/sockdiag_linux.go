@@ -0,0 +1,192 @@
+//go:build linux
+
+package sockdiag
+
+import (
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"syscall"
+	"unsafe"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+	"golang.org/x/sys/unix"
+)
+
+// inetDiagSockID mirrors struct inet_diag_sockid from linux/inet_diag.h
+type inetDiagSockID struct {
+	SPort  [2]byte  // idiag_sport: source port, big endian
+	DPort  [2]byte  // idiag_dport: destination port, big endian
+	Src    [16]byte // idiag_src: source address
+	Dst    [16]byte // idiag_dst: destination address
+	If     uint32   // idiag_if: interface index
+	Cookie [2]uint32
+}
+
+// inetDiagReqV2 mirrors struct inet_diag_req_v2 from linux/inet_diag.h
+type inetDiagReqV2 struct {
+	Family   uint8  // sdiag_family
+	Protocol uint8  // sdiag_protocol
+	Ext      uint8  // idiag_ext: bitmask of extensions to include
+	_        uint8  // pad
+	States   uint32 // idiag_states: bitmask of TCP states to dump
+	ID       inetDiagSockID
+}
+
+// inetDiagMsg mirrors struct inet_diag_msg from linux/inet_diag.h
+type inetDiagMsg struct {
+	Family  uint8 // idiag_family
+	State   uint8 // idiag_state
+	Timer   uint8 // idiag_timer
+	Retrans uint8 // idiag_retrans
+	ID      inetDiagSockID
+	Expires uint32 // idiag_expires
+	RQueue  uint32 // idiag_rqueue
+	WQueue  uint32 // idiag_wqueue
+	UID     uint32 // idiag_uid
+	Inode   uint32 // idiag_inode
+}
+
+const (
+	inetDiagInfo      = 2 // INET_DIAG_INFO
+	sizeofDiagReqV2   = int(unsafe.Sizeof(inetDiagReqV2{}))
+	sizeofDiagMsg     = int(unsafe.Sizeof(inetDiagMsg{}))
+	sizeofRtAttr      = 4
+	allStates         = 0xfff
+	receiveBufferSize = 32 * 1024
+)
+
+// Dump returns the TCP sockets on the system matching opts. Sockets in other network namespaces are not included.
+func Dump(opts Options) ([]Socket, error) {
+	states := uint32(allStates)
+	if len(opts.States) > 0 {
+		states = 0
+		for _, s := range opts.States {
+			states |= 1 << s
+		}
+	}
+
+	families := []uint8{unix.AF_INET, unix.AF_INET6}
+	switch opts.Family {
+	case 0:
+	case unix.AF_INET, unix.AF_INET6:
+		families = []uint8{uint8(opts.Family)}
+	default:
+		return nil, fmt.Errorf("unsupported address family %d", opts.Family)
+	}
+
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, unix.NETLINK_INET_DIAG)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+
+	var socks []Socket
+	for i, family := range families {
+		found, err := dumpFamily(fd, uint32(i+1), family, states)
+		if err != nil {
+			return socks, err
+		}
+		socks = append(socks, found...)
+	}
+	return socks, nil
+}
+
+func dumpFamily(fd int, seq uint32, family uint8, states uint32) ([]Socket, error) {
+	req := inetDiagReqV2{
+		Family:   family,
+		Protocol: unix.IPPROTO_TCP,
+		Ext:      1 << (inetDiagInfo - 1),
+		States:   states,
+	}
+	msg := make([]byte, unix.NLMSG_HDRLEN+sizeofDiagReqV2)
+	*(*unix.NlMsghdr)(unsafe.Pointer(&msg[0])) = unix.NlMsghdr{
+		Len:   uint32(len(msg)),
+		Type:  unix.SOCK_DIAG_BY_FAMILY,
+		Flags: unix.NLM_F_REQUEST | unix.NLM_F_DUMP,
+		Seq:   seq,
+	}
+	*(*inetDiagReqV2)(unsafe.Pointer(&msg[unix.NLMSG_HDRLEN])) = req
+	if err := unix.Sendto(fd, msg, 0, &unix.SockaddrNetlink{Family: unix.AF_NETLINK}); err != nil {
+		return nil, err
+	}
+
+	var socks []Socket
+	buf := make([]byte, receiveBufferSize)
+	for {
+		n, _, err := unix.Recvfrom(fd, buf, 0)
+		if err != nil {
+			return socks, err
+		}
+		msgs, err := syscall.ParseNetlinkMessage(buf[:n])
+		if err != nil {
+			return socks, err
+		}
+		for _, m := range msgs {
+			if m.Header.Seq != seq {
+				continue
+			}
+			switch m.Header.Type {
+			case unix.NLMSG_DONE:
+				return socks, nil
+			case unix.NLMSG_ERROR:
+				if len(m.Data) >= 4 {
+					if errno := -int32(binary.NativeEndian.Uint32(m.Data)); errno != 0 {
+						return socks, syscall.Errno(errno)
+					}
+				}
+				return socks, nil
+			case unix.SOCK_DIAG_BY_FAMILY:
+				if s, ok := parseDiagMsg(m.Data); ok {
+					socks = append(socks, s)
+				}
+			}
+		}
+	}
+}
+
+func parseDiagMsg(b []byte) (Socket, bool) {
+	if len(b) < sizeofDiagMsg {
+		return Socket{}, false
+	}
+	var msg inetDiagMsg
+	copy(unsafe.Slice((*byte)(unsafe.Pointer(&msg)), sizeofDiagMsg), b)
+
+	s := Socket{
+		Local:  addrPort(msg.Family, msg.ID.Src, msg.ID.SPort),
+		Remote: addrPort(msg.Family, msg.ID.Dst, msg.ID.DPort),
+		UID:    msg.UID,
+		Inode:  uint64(msg.Inode),
+	}
+
+	// Walk the attributes that follow the message, looking for INET_DIAG_INFO
+	attrs := b[nlmAlign(sizeofDiagMsg):]
+	for len(attrs) >= sizeofRtAttr {
+		l := int(binary.NativeEndian.Uint16(attrs[0:2]))
+		t := binary.NativeEndian.Uint16(attrs[2:4])
+		if l < sizeofRtAttr || l > len(attrs) {
+			break
+		}
+		if t == inetDiagInfo {
+			s.Info = tcpinfo.UnpackBytes(attrs[sizeofRtAttr:l])
+		}
+		next := nlmAlign(l)
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+	return s, true
+}
+
+func addrPort(family uint8, addr [16]byte, port [2]byte) netip.AddrPort {
+	p := binary.BigEndian.Uint16(port[:])
+	if family == unix.AF_INET {
+		return netip.AddrPortFrom(netip.AddrFrom4([4]byte(addr[:4])), p)
+	}
+	return netip.AddrPortFrom(netip.AddrFrom16(addr), p)
+}
+
+func nlmAlign(n int) int {
+	return (n + unix.NLMSG_ALIGNTO - 1) &^ (unix.NLMSG_ALIGNTO - 1)
+}
@@ -0,0 +1,90 @@
+//go:build linux
+
+package sockdiag
+
+import (
+	"net"
+	"net/netip"
+	"testing"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+	"golang.org/x/sys/unix"
+)
+
+func TestDumpFindsLoopbackPair(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	client, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer client.Close()
+	server, err := ln.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	defer server.Close()
+
+	socks, err := Dump(Options{Family: unix.AF_INET, States: []uint8{tcpinfo.TCP_ESTABLISHED}})
+	if err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+
+	clientAddr := netip.MustParseAddrPort(client.LocalAddr().String())
+	serverAddr := netip.MustParseAddrPort(server.LocalAddr().String())
+	var foundClient, foundServer bool
+	for _, s := range socks {
+		if s.Info == nil || s.Info.StateName != "ESTABLISHED" {
+			t.Errorf("unexpected socket in ESTABLISHED dump: %+v", s)
+			continue
+		}
+		switch {
+		case s.Local == clientAddr && s.Remote == serverAddr:
+			foundClient = true
+		case s.Local == serverAddr && s.Remote == clientAddr:
+			foundServer = true
+		}
+	}
+	if !foundClient || !foundServer {
+		t.Fatalf("loopback pair not found (client=%v server=%v) in %d sockets", foundClient, foundServer, len(socks))
+	}
+}
+
+func TestDumpFiltersFamilyAndState(t *testing.T) {
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	listenAddr := netip.MustParseAddrPort(ln.Addr().String())
+
+	socks, err := Dump(Options{Family: unix.AF_INET, States: []uint8{tcpinfo.TCP_LISTEN}})
+	if err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	var found bool
+	for _, s := range socks {
+		if !s.Local.Addr().Is4() {
+			t.Errorf("unexpected non-IPv4 socket %v", s.Local)
+		}
+		if s.Local == listenAddr {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("listener %v not found", listenAddr)
+	}
+
+	socks, err = Dump(Options{Family: unix.AF_INET6, States: []uint8{tcpinfo.TCP_LISTEN}})
+	if err != nil {
+		t.Fatalf("Dump: %v", err)
+	}
+	for _, s := range socks {
+		if s.Local == listenAddr {
+			t.Fatalf("IPv4 listener returned for AF_INET6")
+		}
+	}
+}
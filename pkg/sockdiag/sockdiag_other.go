@@ -0,0 +1,8 @@
+//go:build !linux
+
+package sockdiag
+
+// Dump is not supported on this platform.
+func Dump(opts Options) ([]Socket, error) {
+	return nil, ErrUnsupported
+}
@@ -0,0 +1,79 @@
+// Package statsd reports TCP info as StatsD or DogStatsD metrics.
+package statsd
+
+import (
+	"sync"
+
+	"github.com/runZeroInc/conniver"
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+)
+
+// MetricPrefix is prepended to the tcpi field name to form each metric name, such as tcpinfo.rtt.
+const MetricPrefix = "tcpinfo."
+
+// StatsdClient is the subset of a StatsD client used by the reporter. The method signatures
+// match the DataDog datadog-go client, so it can be used directly.
+type StatsdClient interface {
+	Gauge(name string, value float64, tags []string, rate float64) error
+	Count(name string, value int64, tags []string, rate float64) error
+}
+
+// metric maps a SysInfo.ToMap key to the name and type of the metric it is reported as.
+type metric struct {
+	key     string
+	name    string
+	counter bool
+}
+
+// metrics lists a metric for every numeric tcpi field of the platform SysInfo.
+var metrics = sync.OnceValue(func() []metric {
+	var ms []metric
+	for _, f := range tcpinfo.FieldMetadata() {
+		if !f.Numeric || f.Key == "" {
+			continue
+		}
+		ms = append(ms, metric{key: f.Key, name: MetricPrefix + f.PromName, counter: f.PromType == "counter"})
+	}
+	return ms
+})
+
+// NewReporter returns a ReportStatsFn that emits the platform TCP info of each open and close report
+// to client, along with the given tags and a state tag of state:open or state:close. Fields tagged
+// prom_type=counter are sent as counts and all others as gauges, with durations in seconds. Since
+// StatsD counts are increments, the close report sends the change in each counter since the open
+// report, so the two add up to the connection total. Errors from the client are ignored.
+func NewReporter(client StatsdClient, tags []string) conniver.ReportStatsFn {
+	return func(c *conniver.Conn, state int) {
+		var info map[string]any
+		switch state {
+		case conniver.Opened:
+			c.Lock()
+			if c.OpenedInfo != nil {
+				info = c.OpenedInfo.ToMap()
+			}
+			c.Unlock()
+		case conniver.Closed:
+			info = c.InfoDelta()
+		}
+		sys, ok := info["sysInfo"].(map[string]any)
+		if !ok {
+			return
+		}
+		stateTags := append(tags[:len(tags):len(tags)], "state:"+conniver.StateMap[state])
+		for _, m := range metrics() {
+			v, ok := sys[m.key]
+			if !ok {
+				continue
+			}
+			if m.counter {
+				if n, ok := tcpinfo.CounterValue(v); ok {
+					_ = client.Count(m.name, n, stateTags, 1)
+				}
+				continue
+			}
+			if f, ok := tcpinfo.MetricValue(v); ok {
+				_ = client.Gauge(m.name, f, stateTags, 1)
+			}
+		}
+	}
+}
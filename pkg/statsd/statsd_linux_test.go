@@ -0,0 +1,91 @@
+package statsd
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/runZeroInc/conniver"
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+)
+
+type sample struct {
+	value any
+	tags  []string
+}
+
+type fakeClient struct {
+	gauges map[string]sample
+	counts map[string]sample
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{gauges: map[string]sample{}, counts: map[string]sample{}}
+}
+
+func (f *fakeClient) Gauge(name string, value float64, tags []string, rate float64) error {
+	f.gauges[name] = sample{value, tags}
+	return nil
+}
+
+func (f *fakeClient) Count(name string, value int64, tags []string, rate float64) error {
+	f.counts[name] = sample{value, tags}
+	return nil
+}
+
+func TestNewReporter(t *testing.T) {
+	c := &conniver.Conn{
+		OpenedInfo: &tcpinfo.Info{Sys: &tcpinfo.SysInfo{
+			RTT:          10 * time.Millisecond,
+			TotalRetrans: 1,
+			BytesRetrans: tcpinfo.NullableUint64{Valid: true, Value: 100},
+		}},
+		ClosedInfo: &tcpinfo.Info{Sys: &tcpinfo.SysInfo{
+			RTT:          25 * time.Millisecond,
+			TotalRetrans: 3,
+			BytesRetrans: tcpinfo.NullableUint64{Valid: true, Value: 400},
+		}},
+	}
+	tags := []string{"env:test"}
+
+	for _, tc := range []struct {
+		state        int
+		wantTags     []string
+		wantRTT      float64
+		wantRetrans  int64
+		wantBytesRtx int64
+	}{
+		{conniver.Opened, []string{"env:test", "state:open"}, 0.01, 1, 100},
+		{conniver.Closed, []string{"env:test", "state:close"}, 0.025, 2, 300},
+	} {
+		client := newFakeClient()
+		NewReporter(client, tags)(c, tc.state)
+
+		if got := client.gauges["tcpinfo.rtt"]; got.value != tc.wantRTT || !reflect.DeepEqual(got.tags, tc.wantTags) {
+			t.Errorf("%s: tcpinfo.rtt = %+v, want %v with %v", conniver.StateMap[tc.state], got, tc.wantRTT, tc.wantTags)
+		}
+		if got := client.counts["tcpinfo.total_retrans"]; got.value != tc.wantRetrans {
+			t.Errorf("%s: tcpinfo.total_retrans = %v, want %d", conniver.StateMap[tc.state], got.value, tc.wantRetrans)
+		}
+		if got := client.counts["tcpinfo.bytes_retrans"]; got.value != tc.wantBytesRtx {
+			t.Errorf("%s: tcpinfo.bytes_retrans = %v, want %d", conniver.StateMap[tc.state], got.value, tc.wantBytesRtx)
+		}
+		if _, ok := client.counts["tcpinfo.bytes_sent"]; ok {
+			t.Errorf("%s: unexpected tcpinfo.bytes_sent for a null field", conniver.StateMap[tc.state])
+		}
+		if _, ok := client.gauges["tcpinfo.total_retrans"]; ok {
+			t.Errorf("%s: counter reported as a gauge", conniver.StateMap[tc.state])
+		}
+	}
+	if !reflect.DeepEqual(tags, []string{"env:test"}) {
+		t.Errorf("caller tags modified: %v", tags)
+	}
+}
+
+func TestNewReporterWithoutInfo(t *testing.T) {
+	client := newFakeClient()
+	NewReporter(client, nil)(&conniver.Conn{}, conniver.Opened)
+	if len(client.gauges)+len(client.counts) != 0 {
+		t.Errorf("unexpected metrics without TCP info: %v %v", client.gauges, client.counts)
+	}
+}
@@ -0,0 +1,91 @@
+package tcpinfo
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// ErrNotTCP is returned by GetTCPInfoFromConn when no TCP socket can be found beneath the connection.
+var ErrNotTCP = errors.New("connection is not a *net.TCPConn")
+
+// GetTCPInfoFromConn retrieves the TCP info of conn. Besides *net.TCPConn, conn may be a wrapper exposing
+// the connection it wraps through a NetConn method, such as *tls.Conn or *conniver.Conn, or any other
+// connection implementing syscall.Conn. As with GetTCPInfo, partial information may be returned along
+// with an error. Unlike GetTCPInfo, the local and remote addresses of conn are recorded and copied to
+// Info by ToInfo, as are the IPv6 flow label and traffic class of IPv6 connections on Linux.
+func GetTCPInfoFromConn(conn net.Conn) (*SysInfo, error) {
+	var sysInfo *SysInfo
+	err := ControlConn(conn, func(fd uintptr) error {
+		var err error
+		sysInfo, err = GetTCPInfo(fd)
+		// The IPv6 flow details take a few more syscalls, so they are only gathered for IPv6 connections
+		if sysInfo != nil && isIPv6(conn.LocalAddr()) {
+			sysInfo.addIPv6FlowInfo(fd)
+		}
+		return err
+	})
+	if sysInfo != nil {
+		sysInfo.localAddr = addrString(conn.LocalAddr())
+		sysInfo.remoteAddr = addrString(conn.RemoteAddr())
+	}
+	return sysInfo, err
+}
+
+// ControlConn runs fn with the file descriptor of the TCP socket beneath conn, which is found the
+// same way as by GetTCPInfoFromConn. It returns ErrNotTCP if there is no such socket, and otherwise
+// the error from reaching the socket or the one returned by fn.
+func ControlConn(conn net.Conn, fn func(fd uintptr) error) error {
+	sc := unwrapSyscallConn(conn)
+	if sc == nil {
+		return ErrNotTCP
+	}
+
+	rawConn, err := sc.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var fnErr error
+	err = rawConn.Control(func(fd uintptr) {
+		fnErr = fn(fd)
+	})
+	if err != nil {
+		return err
+	}
+	return fnErr
+}
+
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
+
+// isIPv6 reports whether addr is a TCP address in the IPv6 address family, not counting IPv4
+// addresses on dual-stack sockets.
+func isIPv6(addr net.Addr) bool {
+	a, ok := addr.(*net.TCPAddr)
+	return ok && a.AddrPort().Addr().Unmap().Is6()
+}
+
+// unwrapSyscallConn follows NetConn methods until it reaches a connection exposing its socket,
+// returning nil if there is none or it is one of the non-TCP connection types of package net.
+func unwrapSyscallConn(conn net.Conn) syscall.Conn {
+	for conn != nil {
+		switch c := conn.(type) {
+		case *net.TCPConn:
+			return c
+		case *net.UDPConn, *net.UnixConn, *net.IPConn:
+			return nil
+		case interface{ NetConn() net.Conn }:
+			conn = c.NetConn()
+		case syscall.Conn:
+			return c
+		default:
+			return nil
+		}
+	}
+	return nil
+}
@@ -0,0 +1,119 @@
+package tcpinfo
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetTCPInfoFromConn(t *testing.T) {
+	if !Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	info, err := GetTCPInfoFromConn(conn)
+	if info == nil {
+		t.Fatalf("GetTCPInfoFromConn: %v", err)
+	}
+	if state := info.ToInfo().State; state != "ESTABLISHED" {
+		t.Errorf("unexpected state %q", state)
+	}
+}
+
+func TestGetTCPInfoFromConnAddrs(t *testing.T) {
+	if !Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	sysInfo, err := GetTCPInfoFromConn(conn)
+	if sysInfo == nil {
+		t.Fatalf("GetTCPInfoFromConn: %v", err)
+	}
+	info := sysInfo.ToInfo()
+	if info.LocalAddr != conn.LocalAddr().String() || info.RemoteAddr != conn.RemoteAddr().String() {
+		t.Errorf("addrs = %q -> %q, want %q -> %q", info.LocalAddr, info.RemoteAddr, conn.LocalAddr(), conn.RemoteAddr())
+	}
+	b, err := json.Marshal(info)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	var m map[string]any
+	if err := json.Unmarshal(b, &m); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	if m["localAddr"] != info.LocalAddr || m["remoteAddr"] != info.RemoteAddr {
+		t.Errorf("JSON addrs = %v -> %v, want %q -> %q", m["localAddr"], m["remoteAddr"], info.LocalAddr, info.RemoteAddr)
+	}
+}
+
+func TestGetTCPInfoFromConnTLS(t *testing.T) {
+	if !Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	conn, err := tls.Dial("tcp", srv.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	info, err := GetTCPInfoFromConn(conn)
+	if info == nil {
+		t.Fatalf("GetTCPInfoFromConn: %v", err)
+	}
+	if state := info.ToInfo().State; state != "ESTABLISHED" {
+		t.Errorf("unexpected state %q", state)
+	}
+}
+
+func TestGetTCPInfoFromConnNotTCP(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	if _, err := GetTCPInfoFromConn(client); !errors.Is(err, ErrNotTCP) {
+		t.Fatalf("expected ErrNotTCP, got %v", err)
+	}
+}
+
+func TestGetTCPInfoFromConnNotTCPSocket(t *testing.T) {
+	addr, err := net.ResolveUDPAddr("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("resolve: %v", err)
+	}
+	conn, err := net.ListenUDP("udp", addr)
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := GetTCPInfoFromConn(conn); !errors.Is(err, ErrNotTCP) {
+		t.Fatalf("expected ErrNotTCP for a UDP socket, got %v", err)
+	}
+}
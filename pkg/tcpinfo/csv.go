@@ -0,0 +1,95 @@
+package tcpinfo
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"time"
+)
+
+// csvColumn is a column written by CSVEncoder, named after the matching Info.ToMap key.
+type csvColumn struct {
+	name  string
+	value func(i *Info) string
+}
+
+func csvUint(v uint64) string            { return strconv.FormatUint(v, 10) }
+func csvDuration(d time.Duration) string { return strconv.FormatInt(int64(d), 10) }
+
+// csvSysCounter formats a counter reported by SysInfo, or an empty string when it is not available.
+func csvSysCounter(get func(s *SysInfo) (uint64, bool)) func(i *Info) string {
+	return func(i *Info) string {
+		if i.Sys == nil {
+			return ""
+		}
+		if v, ok := get(i.Sys); ok {
+			return csvUint(v)
+		}
+		return ""
+	}
+}
+
+// csvColumns is the fixed column order of CSVEncoder. Append new columns to the end so that
+// existing consumers keep working.
+var csvColumns = []csvColumn{
+	{"sampledAt", func(i *Info) string {
+		if i.SampledAt.IsZero() {
+			return ""
+		}
+		return strconv.FormatInt(i.SampledAt.UnixNano(), 10)
+	}},
+	{"state", func(i *Info) string { return i.State }},
+	{"localAddr", func(i *Info) string { return i.LocalAddr }},
+	{"remoteAddr", func(i *Info) string { return i.RemoteAddr }},
+	{"rtt", func(i *Info) string { return csvDuration(i.RTT) }},
+	{"rttVar", func(i *Info) string { return csvDuration(i.RTTVar) }},
+	{"rto", func(i *Info) string { return csvDuration(i.RTO) }},
+	{"ato", func(i *Info) string { return csvDuration(i.ATO) }},
+	{"txMSS", func(i *Info) string { return csvUint(i.TxMSS) }},
+	{"rxMSS", func(i *Info) string { return csvUint(i.RxMSS) }},
+	{"rxWindow", func(i *Info) string { return csvUint(i.RxWindow) }},
+	{"txSSThreshold", func(i *Info) string { return csvUint(i.TxSSThreshold) }},
+	{"txCWindowSegs", func(i *Info) string { return csvUint(i.TxWindowSegs) }},
+	{"txCWindowBytes", func(i *Info) string { return csvUint(i.TxWindowBytes) }},
+	{"retransmits", func(i *Info) string { return csvUint(i.Retransmits) }},
+	{"bytesSent", csvSysCounter((*SysInfo).SentBytes)},
+	{"bytesRetrans", csvSysCounter((*SysInfo).RetransmittedBytes)},
+}
+
+// CSVEncoder writes Info snapshots as CSV rows with a fixed column order. Durations are written
+// as nanoseconds and sampledAt as unix nanoseconds. Byte counters come from the platform-specific
+// SysInfo and are left empty when the platform does not report them.
+type CSVEncoder struct {
+	w *csv.Writer
+}
+
+// NewCSVEncoder returns a CSVEncoder writing to w.
+func NewCSVEncoder(w io.Writer) *CSVEncoder {
+	return &CSVEncoder{w: csv.NewWriter(w)}
+}
+
+// WriteHeader writes the column names, in the same order as the values written by Write.
+func (e *CSVEncoder) WriteHeader() error {
+	record := make([]string, len(csvColumns))
+	for n, c := range csvColumns {
+		record[n] = c.name
+	}
+	return e.write(record)
+}
+
+// Write writes i as a single row.
+func (e *CSVEncoder) Write(i *Info) error {
+	record := make([]string, len(csvColumns))
+	for n, c := range csvColumns {
+		record[n] = c.value(i)
+	}
+	return e.write(record)
+}
+
+func (e *CSVEncoder) write(record []string) error {
+	if err := e.w.Write(record); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
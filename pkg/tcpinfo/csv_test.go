@@ -0,0 +1,57 @@
+package tcpinfo
+
+import (
+	"bytes"
+	"encoding/csv"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestCSVEncoder(t *testing.T) {
+	at := time.Unix(1700000000, 5)
+	rows := []*Info{
+		{State: "ESTABLISHED", RTT: 12 * time.Millisecond, RTTVar: 3 * time.Millisecond, TxMSS: 1448, TxWindowSegs: 10, Retransmits: 2, SampledAt: at},
+		{State: "CLOSE_WAIT", RemoteAddr: "127.0.0.1:443", RTO: 200 * time.Millisecond, TxWindowBytes: 65535},
+	}
+
+	var buf bytes.Buffer
+	enc := NewCSVEncoder(&buf)
+	if err := enc.WriteHeader(); err != nil {
+		t.Fatalf("WriteHeader: %v", err)
+	}
+	for _, i := range rows {
+		if err := enc.Write(i); err != nil {
+			t.Fatalf("Write: %v", err)
+		}
+	}
+
+	records, err := csv.NewReader(&buf).ReadAll()
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records, want 3", len(records))
+	}
+	header := records[0]
+	got := make([]map[string]string, 0, 2)
+	for _, rec := range records[1:] {
+		m := map[string]string{}
+		for n, name := range header {
+			m[name] = rec[n]
+		}
+		got = append(got, m)
+	}
+
+	want := []map[string]string{
+		{"sampledAt": strconv.FormatInt(at.UnixNano(), 10), "state": "ESTABLISHED", "rtt": "12000000", "rttVar": "3000000", "txMSS": "1448", "txCWindowSegs": "10", "retransmits": "2", "bytesSent": ""},
+		{"sampledAt": "", "state": "CLOSE_WAIT", "remoteAddr": "127.0.0.1:443", "rto": "200000000", "txCWindowBytes": "65535", "rtt": "0", "bytesRetrans": ""},
+	}
+	for n := range want {
+		for k, v := range want[n] {
+			if got[n][k] != v {
+				t.Errorf("row %d column %s = %q, want %q", n, k, got[n][k], v)
+			}
+		}
+	}
+}
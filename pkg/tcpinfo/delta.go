@@ -0,0 +1,77 @@
+package tcpinfo
+
+import (
+	"reflect"
+	"sync"
+)
+
+// counterKeys returns the SysInfo.ToMap keys of the fields tagged prom_type=counter.
+var counterKeys = sync.OnceValue(func() map[string]bool {
+	keys := map[string]bool{}
+	for _, f := range fieldMetadata() {
+		if f.PromType == "counter" && f.Key != "" {
+			keys[f.Key] = true
+		}
+	}
+	return keys
+})
+
+// InfoDelta summarizes how a connection changed between two snapshots, such as the
+// OpenedInfo and ClosedInfo of a Conn. The result has the same layout as Info.ToMap:
+// platform fields tagged prom_type=counter hold the difference between the snapshots,
+// while every other field holds its value from the later snapshot. Counters missing from
+// the earlier snapshot, or that went backwards, also keep their later value. When only one
+// snapshot is available, its ToMap is returned as is, and nil if neither is.
+func InfoDelta(before, after *Info) map[string]any {
+	switch {
+	case before == nil && after == nil:
+		return nil
+	case after == nil:
+		return before.ToMap()
+	case before == nil:
+		return after.ToMap()
+	}
+
+	m := after.ToMap()
+	if before.Sys == nil || after.Sys == nil {
+		return m
+	}
+	prev := before.Sys.ToMap()
+	sys := after.Sys.ToMap()
+	for key := range counterKeys() {
+		cur, ok := sys[key]
+		if !ok {
+			continue
+		}
+		if d, ok := subtractCounter(cur, prev[key]); ok {
+			sys[key] = d
+		}
+	}
+	m["sysInfo"] = sys
+	return m
+}
+
+// subtractCounter returns cur - prev in the type of cur, or false if the values are not
+// integers of the same type or cur is less than prev.
+func subtractCounter(cur, prev any) (any, bool) {
+	c, p := reflect.ValueOf(cur), reflect.ValueOf(prev)
+	if !c.IsValid() || !p.IsValid() || c.Type() != p.Type() {
+		return nil, false
+	}
+	d := reflect.New(c.Type()).Elem()
+	switch {
+	case c.CanUint():
+		if c.Uint() < p.Uint() {
+			return nil, false
+		}
+		d.SetUint(c.Uint() - p.Uint())
+	case c.CanInt():
+		if c.Int() < p.Int() {
+			return nil, false
+		}
+		d.SetInt(c.Int() - p.Int())
+	default:
+		return nil, false
+	}
+	return d.Interface(), true
+}
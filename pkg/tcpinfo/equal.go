@@ -0,0 +1,81 @@
+package tcpinfo
+
+import (
+	"reflect"
+	"sync"
+)
+
+// sysInfoField is an exported SysInfo field compared by Equal and Diff.
+type sysInfoField struct {
+	index      int
+	key        string
+	comparable bool
+}
+
+// sysInfoFields returns the exported SysInfo fields that have a JSON name, keyed like ToMap.
+// Fields without a json tag are skipped, as are State, which is left out in favor of StateName,
+// and unexported fields such as the sample time.
+var sysInfoFields = sync.OnceValue(func() []sysInfoField {
+	var fields []sysInfoField
+	t := reflect.TypeOf(SysInfo{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if !f.IsExported() {
+			continue
+		}
+		opts, _ := ParseTag(f.Tag.Get("tcpi"))
+		key := mapKey(f, opts)
+		if key == "" {
+			continue
+		}
+		fields = append(fields, sysInfoField{index: i, key: key, comparable: f.Type.Comparable() && f.Type.Kind() != reflect.Pointer})
+	}
+	return fields
+})
+
+// Equal reports whether s and other hold the same TCP information. Only exported fields with a
+// JSON name are compared; the sample time and connection addresses are not, so two samples of an
+// idle connection taken at different times are equal. A nil SysInfo is only equal to another nil
+// SysInfo.
+func (s *SysInfo) Equal(other *SysInfo) bool {
+	if s == nil || other == nil {
+		return s == other
+	}
+	a, b := reflect.ValueOf(s).Elem(), reflect.ValueOf(other).Elem()
+	for _, f := range sysInfoFields() {
+		if !fieldEqual(f, a.Field(f.index), b.Field(f.index)) {
+			return false
+		}
+	}
+	return true
+}
+
+// Diff returns the fields that differ between s and other, keyed like ToMap, with the value from s
+// followed by the value from other. The same fields as Equal are compared, and a nil SysInfo is
+// treated as an empty one. The result is empty when the two are equal.
+func (s *SysInfo) Diff(other *SysInfo) map[string][2]any {
+	a, b := reflect.ValueOf(&SysInfo{}).Elem(), reflect.ValueOf(&SysInfo{}).Elem()
+	if s != nil {
+		a = reflect.ValueOf(s).Elem()
+	}
+	if other != nil {
+		b = reflect.ValueOf(other).Elem()
+	}
+	diff := map[string][2]any{}
+	for _, f := range sysInfoFields() {
+		av, bv := a.Field(f.index), b.Field(f.index)
+		if !fieldEqual(f, av, bv) {
+			diff[f.key] = [2]any{av.Interface(), bv.Interface()}
+		}
+	}
+	return diff
+}
+
+// fieldEqual compares a field of two SysInfos, avoiding reflect.DeepEqual for comparable types.
+// Pointers, such as MPTCP, are compared by what they point to.
+func fieldEqual(f sysInfoField, a, b reflect.Value) bool {
+	if f.comparable {
+		return a.Equal(b)
+	}
+	return reflect.DeepEqual(a.Interface(), b.Interface())
+}
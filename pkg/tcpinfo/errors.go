@@ -0,0 +1,47 @@
+package tcpinfo
+
+import (
+	"errors"
+	"syscall"
+)
+
+// Errors returned by GetTCPInfo. Errors from the platform are wrapped with context, so compare
+// them using errors.Is, such as errors.Is(err, tcpinfo.ENOTCONN), rather than ==.
+var (
+	EAGAIN      error = syscall.EAGAIN
+	EINVAL      error = syscall.EINVAL
+	ENOENT      error = syscall.ENOENT
+	ENOTCONN    error = syscall.ENOTCONN
+	EOPNOTSUPP  error = syscall.EOPNOTSUPP
+	ENOPROTOOPT error = syscall.ENOPROTOOPT
+
+	// ErrUnsupportedPlatform is returned on platforms where TCP info is not available.
+	ErrUnsupportedPlatform = errors.New("tcp info is not supported on this platform")
+)
+
+// tcpInfoError wraps an error from the platform call that retrieves tcp_info, adding the platform and
+// operation, such as "tcp_info: linux getsockopt(TCP_INFO): bad file descriptor". errors.Unwrap returns
+// err itself, so the original syscall.Errno can be matched with errors.Is and errors.As.
+func tcpInfoError(err error) error {
+	return &opError{op: tcpInfoOp, err: err}
+}
+
+// opError is an error from a platform operation. Besides the wrapped error, errors.Is also matches
+// sentinel, for platforms whose native errors differ from the package errno sentinels.
+type opError struct {
+	op       string
+	err      error
+	sentinel error
+}
+
+func (e *opError) Error() string {
+	return "tcp_info: " + e.op + ": " + e.err.Error()
+}
+
+func (e *opError) Unwrap() error {
+	return e.err
+}
+
+func (e *opError) Is(target error) bool {
+	return e.sentinel != nil && target == e.sentinel
+}
@@ -0,0 +1,46 @@
+package tcpinfo
+
+import (
+	"errors"
+	"strings"
+	"syscall"
+	"testing"
+)
+
+func TestTCPInfoErrorMatchesSentinels(t *testing.T) {
+	for _, tc := range []struct {
+		errno    syscall.Errno
+		sentinel error
+	}{
+		{syscall.ENOTCONN, ENOTCONN},
+		{syscall.EOPNOTSUPP, EOPNOTSUPP},
+		{syscall.ENOPROTOOPT, ENOPROTOOPT},
+		{syscall.EAGAIN, EAGAIN},
+	} {
+		err := tcpInfoError(tc.errno)
+		if !errors.Is(err, tc.sentinel) {
+			t.Errorf("errors.Is(%v, %v) = false", err, tc.sentinel)
+		}
+		if errors.Is(err, EINVAL) {
+			t.Errorf("errors.Is(%v, EINVAL) = true", err)
+		}
+	}
+}
+
+func TestGetTCPInfoErrorUnwrap(t *testing.T) {
+	if !Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	// An invalid descriptor fails in the platform call itself.
+	_, err := GetTCPInfo(^uintptr(0))
+	var errno syscall.Errno
+	if !errors.As(err, &errno) {
+		t.Fatalf("GetTCPInfo error %v does not wrap a syscall.Errno", err)
+	}
+	if got := errors.Unwrap(err); got != errno {
+		t.Errorf("errors.Unwrap(%v) = %#v, want the original errno %#v", err, got, errno)
+	}
+	if want := "tcp_info: " + tcpInfoOp + ": "; !strings.HasPrefix(err.Error(), want) {
+		t.Errorf("error %q does not start with %q", err, want)
+	}
+}
@@ -0,0 +1,50 @@
+package tcpinfo
+
+import "math"
+
+// Weights of the inputs to HealthScore, and the values at which each input costs its full weight.
+const (
+	healthRetransmitWeight = 50
+	healthRTTWeight        = 30
+	healthLimitedWeight    = 20
+
+	healthRetransmitWorst = 0.05 // 5% of sent bytes retransmitted
+	healthRTTWorst        = 3.0  // RTT four times the minimum RTT
+)
+
+// HealthScore folds the connection quality into a single number from 0 (worst) to 100 (best).
+// Three inputs each cost up to their weight in points, scaling linearly up to a worst case:
+//
+//   - Retransmissions (weight 50): RetransmitRatio, with 5% or more costing the full weight.
+//   - Bufferbloat (weight 30): how far RTT is inflated over the minimum RTT, with an RTT of four
+//     times the minimum or more costing the full weight. [Linux and Windows]
+//   - Window limits (weight 20): the fraction of sending time limited by the receive window or send
+//     buffer rather than the network. [Linux and Windows]
+//
+// Inputs the platform does not report are left out and the remaining weights are scaled up to
+// 100, so a missing input is never penalized. With no inputs at all, the score is 100. The score
+// is rounded to the nearest integer.
+func (i *Info) HealthScore() int {
+	var weight, penalty float64
+	add := func(w, p float64) {
+		weight += w
+		penalty += w * min(max(p, 0), 1)
+	}
+
+	if ratio, ok := i.retransmitRatio(); ok {
+		add(healthRetransmitWeight, ratio/healthRetransmitWorst)
+	}
+	if i != nil && i.Sys != nil {
+		if base, ok := i.Sys.BaseRTT(); ok && i.RTT > 0 {
+			add(healthRTTWeight, (float64(i.RTT)/float64(base)-1)/healthRTTWorst)
+		}
+		if limited, ok := i.Sys.LimitedFraction(); ok {
+			add(healthLimitedWeight, limited)
+		}
+	}
+
+	if weight == 0 {
+		return 100
+	}
+	return int(math.Round(100 * (1 - penalty/weight)))
+}
@@ -13,10 +13,7 @@ import (
 	"github.com/runZeroInc/conniver/pkg/kernel"
 )
 
-var (
-	linuxKernelVersion *kernel.VersionInfo
-	sizeOfRawTCPInfo   int
-)
+var linuxKernelVersion *kernel.VersionInfo
 
 type VersionedStructSize struct {
 	Version kernel.VersionInfo
@@ -38,15 +35,20 @@ var (
 	kernelVersionIsAtLeast_5_5   = false
 	kernelVersionIsAtLeast_6_2   = false
 	kernelVersionIsAtLeast_6_7   = false
+
+	// kernelVersionIsAtLeast_5_16 does not change the tcp_info size; it gates the SOL_MPTCP socket options.
+	kernelVersionIsAtLeast_5_16 = false
 )
 
+var mptcpSockoptVersion = kernel.VersionInfo{Kernel: 5, Major: 16, Minor: 0}
+
 var tcpInfoSizes = []VersionedStructSize{
 	{Version: kernel.VersionInfo{Kernel: 2, Major: 6, Minor: 2}, Size: 104, Flag: &kernelVersionIsAtLeast_2_6_2},
 	{Version: kernel.VersionInfo{Kernel: 3, Major: 15, Minor: 0}, Size: 120, Flag: &kernelVersionIsAtLeast_3_15},
 	{Version: kernel.VersionInfo{Kernel: 4, Major: 1, Minor: 0}, Size: 136, Flag: &kernelVersionIsAtLeast_4_1},
 	{Version: kernel.VersionInfo{Kernel: 4, Major: 2, Minor: 0}, Size: 144, Flag: &kernelVersionIsAtLeast_4_2},
 	{Version: kernel.VersionInfo{Kernel: 4, Major: 6, Minor: 0}, Size: 160, Flag: &kernelVersionIsAtLeast_4_6},
-	{Version: kernel.VersionInfo{Kernel: 4, Major: 9, Minor: 0}, Size: 148, Flag: &kernelVersionIsAtLeast_4_9},
+	{Version: kernel.VersionInfo{Kernel: 4, Major: 9, Minor: 0}, Size: 168, Flag: &kernelVersionIsAtLeast_4_9},
 	{Version: kernel.VersionInfo{Kernel: 4, Major: 10, Minor: 0}, Size: 192, Flag: &kernelVersionIsAtLeast_4_10},
 	{Version: kernel.VersionInfo{Kernel: 4, Major: 18, Minor: 0}, Size: 200, Flag: &kernelVersionIsAtLeast_4_18},
 	{Version: kernel.VersionInfo{Kernel: 4, Major: 19, Minor: 0}, Size: 224, Flag: &kernelVersionIsAtLeast_4_19},
@@ -56,20 +58,113 @@ var tcpInfoSizes = []VersionedStructSize{
 	{Version: kernel.VersionInfo{Kernel: 6, Major: 7, Minor: 0}, Size: 248, Flag: &kernelVersionIsAtLeast_6_7},
 }
 
+// tcpInfoFields maps the tcp_info fields added after 2.6.2 to the flag of the kernel version that introduced them.
+var tcpInfoFields = map[string]*bool{
+	"pacing_rate":               &kernelVersionIsAtLeast_3_15,
+	"max_pacing_rate":           &kernelVersionIsAtLeast_3_15,
+	"bytes_acked":               &kernelVersionIsAtLeast_4_1,
+	"bytes_received":            &kernelVersionIsAtLeast_4_1,
+	"segs_out":                  &kernelVersionIsAtLeast_4_2,
+	"segs_in":                   &kernelVersionIsAtLeast_4_2,
+	"notsent_bytes":             &kernelVersionIsAtLeast_4_6,
+	"min_rtt":                   &kernelVersionIsAtLeast_4_6,
+	"data_segs_in":              &kernelVersionIsAtLeast_4_6,
+	"data_segs_out":             &kernelVersionIsAtLeast_4_6,
+	"delivery_rate_app_limited": &kernelVersionIsAtLeast_4_9,
+	"delivery_rate":             &kernelVersionIsAtLeast_4_9,
+	"busy_time":                 &kernelVersionIsAtLeast_4_10,
+	"rwnd_limited":              &kernelVersionIsAtLeast_4_10,
+	"sndbuf_limited":            &kernelVersionIsAtLeast_4_10,
+	"delivered":                 &kernelVersionIsAtLeast_4_18,
+	"delivered_ce":              &kernelVersionIsAtLeast_4_18,
+	"bytes_sent":                &kernelVersionIsAtLeast_4_19,
+	"bytes_retrans":             &kernelVersionIsAtLeast_4_19,
+	"dsack_dups":                &kernelVersionIsAtLeast_4_19,
+	"reord_seen":                &kernelVersionIsAtLeast_4_19,
+	"rcv_ooopack":               &kernelVersionIsAtLeast_5_4,
+	"snd_wnd":                   &kernelVersionIsAtLeast_5_4,
+	"fastopen_client_fail":      &kernelVersionIsAtLeast_5_5,
+	"rcv_wnd":                   &kernelVersionIsAtLeast_6_2,
+	"rehash":                    &kernelVersionIsAtLeast_6_2,
+	"total_rto":                 &kernelVersionIsAtLeast_6_7,
+	"total_rto_recoveries":      &kernelVersionIsAtLeast_6_7,
+	"total_rto_time":            &kernelVersionIsAtLeast_6_7,
+}
+
+// AvailableFields reports, by tcp_info field name, whether each field added after kernel 2.6.2 is provided by
+// the running kernel. Fields present since 2.6.2 are always available when Supported returns true.
+func AvailableFields() map[string]bool {
+	fields := make(map[string]bool, len(tcpInfoFields))
+	for name, flag := range tcpInfoFields {
+		fields[name] = *flag
+	}
+	return fields
+}
+
+// fieldSinceVersion returns the kernel version that introduced the named tcp_info field, and false if the
+// field is not one of those added after 2.6.2.
+func fieldSinceVersion(name string) (kernel.VersionInfo, bool) {
+	flag, ok := tcpInfoFields[name]
+	if !ok {
+		return kernel.VersionInfo{}, false
+	}
+	for _, size := range tcpInfoSizes {
+		if size.Flag == flag {
+			return size.Version, true
+		}
+	}
+	return kernel.VersionInfo{}, false
+}
+
+// getKernelVersion is replaced in tests to exercise the fallback path.
+var getKernelVersion = kernel.GetKernelVersion
+
+// initErr records why the kernel version could not be detected, if it could not.
+var initErr error
+
 func init() {
-	adaptToKernelVersion()
+	detectKernelVersion()
 }
 
-func adaptToKernelVersion() {
-	linuxKernelVersion, err := kernel.GetKernelVersion()
+// detectKernelVersion detects the running kernel version, falling back to 2.6.2 if that fails.
+func detectKernelVersion() {
+	var err error
+	linuxKernelVersion, err = getKernelVersion()
+	initErr = err
 	if err != nil {
 		linuxKernelVersion = &kernel.VersionInfo{Kernel: 2, Major: 6, Minor: 2} // Fallback to very old kernel version
 	}
+	adaptToKernelVersion()
+}
+
+// InitError returns the error encountered while detecting the kernel version at startup, or nil if detection
+// succeeded. When it is non-nil, field availability assumes a 2.6.2 kernel until SetKernelVersion is called.
+func InitError() error {
+	return initErr
+}
+
+// SetKernelVersion overrides the detected kernel version. GetTCPInfo decides which tcp_info fields are
+// available from the length the kernel returns, so the override only affects the bitfield fields
+// (DeliveryRateAppLimited and FastOpenClientFail), which share a word with older fields, and Unpack,
+// which has no length to go by. It also gates the MPTCP socket options and the kernel reported by
+// FieldError. This is useful when uname does not report the host kernel, such as in some containers.
+// It is not safe to call concurrently with GetTCPInfo.
+func SetKernelVersion(k, major, minor int) {
+	linuxKernelVersion = &kernel.VersionInfo{Kernel: k, Major: major, Minor: minor}
+	adaptToKernelVersion()
+}
+
+// KernelVersion returns the kernel version used to decide tcp_info field availability.
+func KernelVersion() (k, major, minor int) {
+	return linuxKernelVersion.Kernel, linuxKernelVersion.Major, linuxKernelVersion.Minor
+}
+
+// adaptToKernelVersion sets the version flags according to linuxKernelVersion.
+func adaptToKernelVersion() {
+	kernelVersionIsAtLeast_5_16 = kernel.CompareKernelVersion(*linuxKernelVersion, mptcpSockoptVersion) >= 0
 
 	for i := len(tcpInfoSizes) - 1; i >= 0; i-- {
 		if kernel.CompareKernelVersion(*linuxKernelVersion, tcpInfoSizes[i].Version) >= 0 {
-			sizeOfRawTCPInfo = tcpInfoSizes[i].Size
-
 			for j := i; j >= 0; j-- {
 				*tcpInfoSizes[j].Flag = true
 			}
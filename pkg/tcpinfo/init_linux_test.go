@@ -0,0 +1,127 @@
+//go:build linux
+
+package tcpinfo
+
+import (
+	"errors"
+	"testing"
+	"unsafe"
+
+	"github.com/runZeroInc/conniver/pkg/kernel"
+)
+
+func TestTCPInfoSizesMonotonic(t *testing.T) {
+	for i := 1; i < len(tcpInfoSizes); i++ {
+		prev, cur := tcpInfoSizes[i-1], tcpInfoSizes[i]
+		if kernel.CompareKernelVersion(cur.Version, prev.Version) <= 0 {
+			t.Errorf("version %v is not after %v", cur.Version, prev.Version)
+		}
+		if cur.Size < prev.Size {
+			t.Errorf("size for %v (%d) is smaller than size for %v (%d)", cur.Version, cur.Size, prev.Version, prev.Size)
+		}
+	}
+	if last := tcpInfoSizes[len(tcpInfoSizes)-1]; last.Size != int(unsafe.Sizeof(RawTCPInfo{})) {
+		t.Errorf("size for %v (%d) does not match RawTCPInfo (%d)", last.Version, last.Size, unsafe.Sizeof(RawTCPInfo{}))
+	}
+}
+
+func TestAvailableFields(t *testing.T) {
+	setKernelVersionForTest(t)
+	linuxKernelVersion = &kernel.VersionInfo{Kernel: 4, Major: 8, Minor: 0}
+	adaptToKernelVersion()
+
+	fields := AvailableFields()
+	for name, want := range map[string]bool{
+		"pacing_rate":   true,
+		"segs_in":       true,
+		"min_rtt":       true,
+		"delivery_rate": false,
+		"busy_time":     false,
+		"total_rto":     false,
+	} {
+		if got, ok := fields[name]; !ok || got != want {
+			t.Errorf("AvailableFields()[%q] = %v (present=%v), want %v", name, got, ok, want)
+		}
+	}
+}
+
+func TestSetKernelVersion(t *testing.T) {
+	setKernelVersionForTest(t)
+
+	SetKernelVersion(4, 19, 0)
+	if k, major, minor := KernelVersion(); k != 4 || major != 19 || minor != 0 {
+		t.Fatalf("KernelVersion() = %d.%d.%d, want 4.19.0", k, major, minor)
+	}
+	fields := AvailableFields()
+	if !fields["bytes_sent"] || fields["rcv_ooopack"] {
+		t.Errorf("unexpected availability for 4.19: bytes_sent=%v rcv_ooopack=%v", fields["bytes_sent"], fields["rcv_ooopack"])
+	}
+}
+
+func TestDetectKernelVersionFallback(t *testing.T) {
+	setKernelVersionForTest(t)
+	savedGet := getKernelVersion
+	t.Cleanup(func() { getKernelVersion = savedGet })
+
+	failure := errors.New("uname failed")
+	getKernelVersion = func() (*kernel.VersionInfo, error) { return nil, failure }
+	detectKernelVersion()
+
+	if err := InitError(); !errors.Is(err, failure) {
+		t.Fatalf("InitError() = %v, want %v", err, failure)
+	}
+	if k, major, minor := KernelVersion(); k != 2 || major != 6 || minor != 2 {
+		t.Fatalf("KernelVersion() = %d.%d.%d, want 2.6.2", k, major, minor)
+	}
+	if !Supported() || AvailableFields()["pacing_rate"] {
+		t.Error("fallback should only enable the 2.6.2 fields")
+	}
+}
+
+func TestSysInfoFieldError(t *testing.T) {
+	setKernelVersionForTest(t)
+	linuxKernelVersion = &kernel.VersionInfo{Kernel: 4, Major: 4, Minor: 0}
+	adaptToKernelVersion()
+
+	s := (&RawTCPInfo{}).Unpack()
+	if err := s.FieldError("segs_in"); err != nil {
+		t.Errorf("FieldError(segs_in) = %v, want nil", err)
+	}
+	if err := s.FieldError("rtt"); err != nil {
+		t.Errorf("FieldError(rtt) = %v, want nil", err)
+	}
+
+	err := s.FieldError("busy_time")
+	var kerr *FieldRequiresKernelError
+	if !errors.As(err, &kerr) {
+		t.Fatalf("FieldError(busy_time) = %v, want *FieldRequiresKernelError", err)
+	}
+	if kerr.Field != "busy_time" || kerr.Since.Kernel != 4 || kerr.Since.Major != 10 || kerr.Have.Major != 4 {
+		t.Errorf("unexpected error details %+v", kerr)
+	}
+	if want := "tcp_info field busy_time requires kernel 4.10, have 4.4"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err.Error(), want)
+	}
+
+	if err := s.FieldError("no_such_field"); err == nil || errors.As(err, &kerr) {
+		t.Errorf("FieldError(no_such_field) = %v, want an unknown field error", err)
+	}
+}
+
+func TestSupportedReasonOldKernel(t *testing.T) {
+	setKernelVersionForTest(t)
+	SetKernelVersion(2, 4, 0)
+
+	ok, reason := SupportedReason()
+	if ok || Supported() {
+		t.Fatal("expected tcp_info to be unsupported on 2.4.0")
+	}
+	if want := "linux kernel 2.4.0 < 2.6.2"; reason != want {
+		t.Fatalf("reason = %q, want %q", reason, want)
+	}
+
+	SetKernelVersion(2, 6, 2)
+	if ok, reason := SupportedReason(); !ok || reason != "" {
+		t.Fatalf("SupportedReason() = %v, %q on 2.6.2", ok, reason)
+	}
+}
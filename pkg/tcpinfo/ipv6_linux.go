@@ -0,0 +1,71 @@
+//go:build linux
+
+package tcpinfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// IPv6 flow label management from linux/in6.h, which x/sys/unix does not define.
+const (
+	IPV6_FLOWLABEL_MGR = 32
+	IPV6_FL_A_GET      = 0
+)
+
+// rawIn6FlowlabelReq mirrors struct in6_flowlabel_req from linux/in6.h.
+type rawIn6FlowlabelReq struct {
+	Dst     [16]byte
+	Label   [4]byte // Network byte order
+	Action  uint8
+	Share   uint8
+	Flags   uint16
+	Expires uint16
+	Linger  uint16
+	_       uint32
+}
+
+// GetIPv6FlowInfo returns the flow label and traffic class set on the given IPv6 socket. The
+// flow label is read with IPV6_FLOWLABEL_MGR and is zero unless one was set explicitly (or
+// reflected with IPV6_FLOWINFO_SEND), since automatic flow labels are not reported. Both values
+// are zero for IPv4 sockets.
+func GetIPv6FlowInfo(fd uintptr) (flowLabel uint32, tclass uint8, err error) {
+	sa, err := unix.Getsockname(int(fd))
+	if err != nil {
+		return 0, 0, ipv6FlowInfoError(err)
+	}
+	if _, ok := sa.(*unix.SockaddrInet6); !ok {
+		return 0, 0, nil
+	}
+
+	tc, err := unix.GetsockoptInt(int(fd), unix.IPPROTO_IPV6, unix.IPV6_TCLASS)
+	if err != nil {
+		return 0, 0, ipv6FlowInfoError(err)
+	}
+
+	req := rawIn6FlowlabelReq{Action: IPV6_FL_A_GET}
+	length := uint32(unsafe.Sizeof(req))
+	switch errNo := getsockopt(fd, unix.IPPROTO_IPV6, IPV6_FLOWLABEL_MGR, unsafe.Pointer(&req), &length); {
+	case errNo == 0:
+		flowLabel = binary.BigEndian.Uint32(req.Label[:])
+	case !errors.Is(errNo, unix.ENOENT):
+		// ENOENT only means no flow label was set on the socket
+		return 0, uint8(tc), ipv6FlowInfoError(errNo)
+	}
+	return flowLabel, uint8(tc), nil
+}
+
+// addIPv6FlowInfo records the IPv6 flow label and traffic class of fd. They are best effort, so
+// errors are ignored.
+func (s *SysInfo) addIPv6FlowInfo(fd uintptr) {
+	s.flowLabel, s.trafficClass, _ = GetIPv6FlowInfo(fd)
+}
+
+// ipv6FlowInfoError wraps an error from retrieving the IPv6 flow information so it can be matched with errors.Is.
+func ipv6FlowInfoError(err error) error {
+	return fmt.Errorf("tcp_info: ipv6 flow info: %w", err)
+}
@@ -0,0 +1,6 @@
+//go:build !linux
+
+package tcpinfo
+
+// addIPv6FlowInfo does nothing, since the IPv6 flow details are only gathered on Linux.
+func (s *SysInfo) addIPv6FlowInfo(fd uintptr) {}
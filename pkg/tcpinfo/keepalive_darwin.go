@@ -0,0 +1,9 @@
+//go:build darwin
+
+package tcpinfo
+
+import "golang.org/x/sys/unix"
+
+// tcpKeepIdle is the socket option SetKeepAlive uses for the keepalive idle time, which macOS
+// names TCP_KEEPALIVE.
+const tcpKeepIdle = unix.TCP_KEEPALIVE
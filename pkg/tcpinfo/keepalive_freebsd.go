@@ -0,0 +1,8 @@
+//go:build freebsd
+
+package tcpinfo
+
+import "golang.org/x/sys/unix"
+
+// tcpKeepIdle is the socket option SetKeepAlive uses for the keepalive idle time.
+const tcpKeepIdle = unix.TCP_KEEPIDLE
@@ -0,0 +1,14 @@
+//go:build !(linux || darwin || windows || freebsd)
+
+package tcpinfo
+
+import (
+	"fmt"
+	"runtime"
+	"time"
+)
+
+// SetKeepAlive is not implemented on this platform.
+func SetKeepAlive(fds uintptr, enable bool, idle, interval time.Duration, count int) error {
+	return fmt.Errorf("setting keepalive: %w: %s", ErrUnsupportedPlatform, runtime.GOOS)
+}
@@ -0,0 +1,48 @@
+//go:build linux || darwin || freebsd
+
+package tcpinfo
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// SetKeepAlive enables or disables SO_KEEPALIVE on the given socket. When enabling, idle sets the time
+// the connection must be idle before the first probe, interval the time between probes, and count the
+// number of unanswered probes before the connection is dropped. Zero values leave the system default in
+// place, and durations are rounded up to whole seconds.
+func SetKeepAlive(fds uintptr, enable bool, idle, interval time.Duration, count int) error {
+	fd := int(fds)
+	if !enable {
+		if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_KEEPALIVE, 0); err != nil {
+			return fmt.Errorf("could not disable keepalive: %w", err)
+		}
+		return nil
+	}
+	if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_KEEPALIVE, 1); err != nil {
+		return fmt.Errorf("could not enable keepalive: %w", err)
+	}
+	for _, opt := range []struct {
+		name  string
+		opt   int
+		value int
+	}{
+		{"idle time", tcpKeepIdle, roundUpSeconds(idle)},
+		{"interval", unix.TCP_KEEPINTVL, roundUpSeconds(interval)},
+		{"count", unix.TCP_KEEPCNT, count},
+	} {
+		if opt.value <= 0 {
+			continue
+		}
+		if err := unix.SetsockoptInt(fd, unix.IPPROTO_TCP, opt.opt, opt.value); err != nil {
+			return fmt.Errorf("could not set keepalive %s: %w", opt.name, err)
+		}
+	}
+	return nil
+}
+
+func roundUpSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
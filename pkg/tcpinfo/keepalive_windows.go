@@ -0,0 +1,59 @@
+//go:build windows
+
+package tcpinfo
+
+import (
+	"fmt"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// defaultKeepAlive is used for the idle time and interval when they are not set, since
+// SIO_KEEPALIVE_VALS always sets both. It matches the defaults of the net package.
+const defaultKeepAlive = 15 * time.Second
+
+// SetKeepAlive enables or disables keepalive on the given socket using SIO_KEEPALIVE_VALS. When enabling,
+// idle sets the time the connection must be idle before the first probe and interval the time between
+// probes; zero values use 15 seconds. SIO_KEEPALIVE_VALS has no probe count, so a non-zero count returns
+// an error wrapping ErrUnsupportedPlatform without changing the socket.
+func SetKeepAlive(fds uintptr, enable bool, idle, interval time.Duration, count int) error {
+	if !enable {
+		return setKeepAliveVals(fds, &windows.TCPKeepalive{})
+	}
+	if count > 0 {
+		return fmt.Errorf("could not set keepalive count: %w", ErrUnsupportedPlatform)
+	}
+	if idle <= 0 {
+		idle = defaultKeepAlive
+	}
+	if interval <= 0 {
+		interval = defaultKeepAlive
+	}
+	return setKeepAliveVals(fds, &windows.TCPKeepalive{
+		OnOff:    1,
+		Time:     uint32(idle.Milliseconds()),
+		Interval: uint32(interval.Milliseconds()),
+	})
+}
+
+func setKeepAliveVals(fds uintptr, ka *windows.TCPKeepalive) error {
+	var ret uint32
+	err := syscall.WSAIoctl(
+		syscall.Handle(fds),
+		windows.SIO_KEEPALIVE_VALS,
+		(*byte)(unsafe.Pointer(ka)),
+		uint32(unsafe.Sizeof(*ka)),
+		nil,
+		0,
+		&ret,
+		nil,
+		0,
+	)
+	if err != nil {
+		return fmt.Errorf("could not set keepalive: %w", err)
+	}
+	return nil
+}
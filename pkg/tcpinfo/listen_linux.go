@@ -0,0 +1,79 @@
+//go:build linux
+
+package tcpinfo
+
+import (
+	"errors"
+	"net"
+	"syscall"
+)
+
+// ErrNotListening is returned by GetListenInfo when the socket is not in the LISTEN state.
+var ErrNotListening = errors.New("socket is not listening")
+
+// ListenInfo describes the accept queue of a listening TCP socket.
+type ListenInfo struct {
+	AcceptQueue   uint32 `json:"acceptQueue"`   // Established connections waiting to be accepted
+	AcceptBacklog uint32 `json:"acceptBacklog"` // Maximum accept queue length, the listen(2) backlog capped at net.core.somaxconn
+}
+
+// Overflowing reports whether the accept queue is full, so the kernel is dropping or refusing new
+// connections until the application accepts some.
+func (l *ListenInfo) Overflowing() bool {
+	return l.AcceptBacklog > 0 && l.AcceptQueue >= l.AcceptBacklog
+}
+
+// ToMap converts the ListenInfo to a map[string]any.
+func (l *ListenInfo) ToMap() map[string]any {
+	return map[string]any{
+		"acceptQueue":   l.AcceptQueue,
+		"acceptBacklog": l.AcceptBacklog,
+	}
+}
+
+// GetListenInfo retrieves the accept queue of the given listening socket. For sockets in the LISTEN
+// state the kernel reports the current accept queue length in tcpi_unacked and the backlog in
+// tcpi_sacked. ErrNotListening is returned for sockets in any other state.
+func GetListenInfo(fd uintptr) (*ListenInfo, error) {
+	if !kernelVersionIsAtLeast_2_6_2 {
+		return nil, ErrKernelTooOld
+	}
+	raw, _, err := getRawTCPInfoWithRetries(fd)
+	if err != nil {
+		return nil, err
+	}
+	if raw.state != TCP_LISTEN {
+		return nil, ErrNotListening
+	}
+	return &ListenInfo{AcceptQueue: raw.unacked, AcceptBacklog: raw.sacked}, nil
+}
+
+// ListenInfoFromListener retrieves the accept queue of l, which must be a *net.TCPListener or
+// another listener implementing syscall.Conn over a TCP socket.
+func ListenInfoFromListener(l net.Listener) (*ListenInfo, error) {
+	var sc syscall.Conn
+	switch ln := l.(type) {
+	case *net.TCPListener:
+		sc = ln
+	case *net.UnixListener:
+		return nil, ErrNotTCP
+	case syscall.Conn:
+		sc = ln
+	default:
+		return nil, ErrNotTCP
+	}
+
+	rawConn, err := sc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+	var info *ListenInfo
+	var listenErr error
+	err = rawConn.Control(func(fd uintptr) {
+		info, listenErr = GetListenInfo(fd)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return info, listenErr
+}
@@ -0,0 +1,90 @@
+//go:build linux
+
+package tcpinfo
+
+import (
+	"errors"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestListenInfoFromListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	info, err := ListenInfoFromListener(ln)
+	if err != nil {
+		t.Fatalf("ListenInfoFromListener: %v", err)
+	}
+	if info.AcceptQueue != 0 || info.AcceptBacklog == 0 {
+		t.Errorf("idle listener queue=%d backlog=%d, want 0 and non-zero", info.AcceptQueue, info.AcceptBacklog)
+	}
+	// Go listens with a backlog of net.core.somaxconn, which is also the kernel cap
+	if b, err := os.ReadFile("/proc/sys/net/core/somaxconn"); err == nil {
+		if n, err := strconv.Atoi(strings.TrimSpace(string(b))); err == nil && n < 1<<16 && info.AcceptBacklog != uint32(n) {
+			t.Errorf("AcceptBacklog = %d, want somaxconn %d", info.AcceptBacklog, n)
+		}
+	}
+
+	// Connections complete the handshake without being accepted, filling the queue
+	for range 3 {
+		conn, err := net.Dial("tcp", ln.Addr().String())
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer conn.Close()
+	}
+	deadline := time.Now().Add(time.Second)
+	for info.AcceptQueue < 3 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+		if info, err = ListenInfoFromListener(ln); err != nil {
+			t.Fatalf("ListenInfoFromListener: %v", err)
+		}
+	}
+	if info.AcceptQueue != 3 {
+		t.Errorf("AcceptQueue = %d, want 3", info.AcceptQueue)
+	}
+	if info.Overflowing() {
+		t.Errorf("Overflowing() = true with %d of %d queued", info.AcceptQueue, info.AcceptBacklog)
+	}
+}
+
+func TestListenInfoNotListening(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	rawConn, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("SyscallConn: %v", err)
+	}
+	_ = rawConn.Control(func(fd uintptr) {
+		_, err = GetListenInfo(fd)
+	})
+	if !errors.Is(err, ErrNotListening) {
+		t.Errorf("GetListenInfo on a connected socket = %v, want ErrNotListening", err)
+	}
+
+	uln, err := net.Listen("unix", t.TempDir()+"/sock")
+	if err != nil {
+		t.Fatalf("listen unix: %v", err)
+	}
+	defer uln.Close()
+	if _, err := ListenInfoFromListener(uln); !errors.Is(err, ErrNotTCP) {
+		t.Errorf("ListenInfoFromListener on a unix listener = %v, want ErrNotTCP", err)
+	}
+}
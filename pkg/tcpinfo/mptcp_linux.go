@@ -0,0 +1,222 @@
+//go:build linux
+
+package tcpinfo
+
+import (
+	"fmt"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// MPTCP socket options from linux/mptcp.h, read at level unix.SOL_MPTCP.
+const (
+	MPTCP_INFO          = 1
+	MPTCP_TCPINFO       = 2
+	MPTCP_SUBFLOW_ADDRS = 3
+)
+
+// mptcpMaxSubflows bounds the subflows requested with MPTCP_TCPINFO. The kernel path manager
+// allows at most 8 additional subflows, plus the initial one.
+const mptcpMaxSubflows = 9
+
+// RawMPTCPInfo mirrors struct mptcp_info from linux/mptcp.h. Fields after CsumEnabled were added
+// in later kernels and are only valid when getsockopt(2) wrote them.
+type RawMPTCPInfo struct {
+	Subflows           uint8
+	AddAddrSignal      uint8
+	AddAddrAccepted    uint8
+	SubflowsMax        uint8
+	AddAddrSignalMax   uint8
+	AddAddrAcceptedMax uint8
+	Flags              uint32
+	Token              uint32
+	WriteSeq           uint64
+	SndUna             uint64
+	RcvNxt             uint64
+	LocalAddrUsed      uint8
+	LocalAddrMax       uint8
+	CsumEnabled        uint8
+	Retransmits        uint32
+	BytesRetrans       uint64
+	BytesSent          uint64
+	BytesReceived      uint64
+	BytesAcked         uint64
+	SubflowsTotal      uint8
+	Reserved           [3]uint8
+}
+
+// rawMPTCPSubflowData mirrors struct mptcp_subflow_data, the header of the MPTCP_TCPINFO result.
+type rawMPTCPSubflowData struct {
+	SizeSubflowData uint32
+	NumSubflows     uint32
+	SizeKernel      uint32
+	SizeUser        uint32
+}
+
+// MPTCPInfo describes a Multipath TCP connection and each of its TCP subflows.
+type MPTCPInfo struct {
+	Subflows           uint8          `json:"subflows"`                // Additional subflows, not counting the initial one
+	SubflowsMax        uint8          `json:"subflowsMax"`             // Limit on additional subflows
+	AddAddrSignal      uint8          `json:"addAddrSignal"`           // ADD_ADDR announcements sent
+	AddAddrAccepted    uint8          `json:"addAddrAccepted"`         // ADD_ADDR announcements accepted from the peer
+	AddAddrSignalMax   uint8          `json:"addAddrSignalMax"`        // Limit on ADD_ADDR announcements sent
+	AddAddrAcceptedMax uint8          `json:"addAddrAcceptedMax"`      // Limit on ADD_ADDR announcements accepted
+	Flags              uint32         `json:"flags"`                   // MPTCP_INFO_FLAG_* bits
+	Token              uint32         `json:"token"`                   // Local connection token
+	WriteSeq           uint64         `json:"writeSeq"`                // Next data sequence number to write
+	SndUna             uint64         `json:"sndUna"`                  // Oldest unacknowledged data sequence number
+	RcvNxt             uint64         `json:"rcvNxt"`                  // Next data sequence number expected
+	LocalAddrUsed      uint8          `json:"localAddrUsed"`           // Local addresses in use
+	LocalAddrMax       uint8          `json:"localAddrMax"`            // Limit on local addresses
+	CsumEnabled        bool           `json:"csumEnabled"`             // Whether DSS checksums are in use
+	Retransmits        NullableUint32 `json:"retransmits,omitempty"`   // Data retransmissions across subflows
+	BytesRetrans       NullableUint64 `json:"bytesRetrans,omitempty"`  // Bytes retransmitted across subflows
+	BytesSent          NullableUint64 `json:"bytesSent,omitempty"`     // Bytes sent across subflows
+	BytesReceived      NullableUint64 `json:"bytesReceived,omitempty"` // Bytes received across subflows
+	BytesAcked         NullableUint64 `json:"bytesAcked,omitempty"`    // Bytes acknowledged by the peer
+	SubflowsTotal      NullableUint8  `json:"subflowsTotal,omitempty"` // All subflows, including the initial one
+	SubflowInfo        []*SysInfo     `json:"subflowInfo,omitempty"`   // The tcp_info of each subflow
+}
+
+// Unpack converts the RawMPTCPInfo to an MPTCPInfo, marking fields beyond n, the number of bytes
+// written by the kernel, as null.
+func (packed *RawMPTCPInfo) Unpack(n int) *MPTCPInfo {
+	avail := func(end uintptr) bool { return end <= uintptr(n) }
+	info := &MPTCPInfo{
+		Subflows:           packed.Subflows,
+		SubflowsMax:        packed.SubflowsMax,
+		AddAddrSignal:      packed.AddAddrSignal,
+		AddAddrAccepted:    packed.AddAddrAccepted,
+		AddAddrSignalMax:   packed.AddAddrSignalMax,
+		AddAddrAcceptedMax: packed.AddAddrAcceptedMax,
+		Flags:              packed.Flags,
+		Token:              packed.Token,
+		WriteSeq:           packed.WriteSeq,
+		SndUna:             packed.SndUna,
+		RcvNxt:             packed.RcvNxt,
+		LocalAddrUsed:      packed.LocalAddrUsed,
+		LocalAddrMax:       packed.LocalAddrMax,
+		CsumEnabled:        packed.CsumEnabled != 0,
+	}
+	if avail(unsafe.Offsetof(packed.Retransmits) + 4) {
+		info.Retransmits = NullableUint32{Valid: true, Value: packed.Retransmits}
+	}
+	if avail(unsafe.Offsetof(packed.BytesAcked) + 8) {
+		info.BytesRetrans = NullableUint64{Valid: true, Value: packed.BytesRetrans}
+		info.BytesSent = NullableUint64{Valid: true, Value: packed.BytesSent}
+		info.BytesReceived = NullableUint64{Valid: true, Value: packed.BytesReceived}
+		info.BytesAcked = NullableUint64{Valid: true, Value: packed.BytesAcked}
+	}
+	if avail(unsafe.Offsetof(packed.SubflowsTotal) + 1) {
+		info.SubflowsTotal = NullableUint8{Valid: true, Value: packed.SubflowsTotal}
+	}
+	return info
+}
+
+// ToMap converts the MPTCPInfo to a map[string]any, leaving out fields the kernel did not report.
+func (m *MPTCPInfo) ToMap() map[string]any {
+	r := map[string]any{
+		"subflows":           m.Subflows,
+		"subflowsMax":        m.SubflowsMax,
+		"addAddrSignal":      m.AddAddrSignal,
+		"addAddrAccepted":    m.AddAddrAccepted,
+		"addAddrSignalMax":   m.AddAddrSignalMax,
+		"addAddrAcceptedMax": m.AddAddrAcceptedMax,
+		"flags":              m.Flags,
+		"token":              m.Token,
+		"writeSeq":           m.WriteSeq,
+		"sndUna":             m.SndUna,
+		"rcvNxt":             m.RcvNxt,
+		"localAddrUsed":      m.LocalAddrUsed,
+		"localAddrMax":       m.LocalAddrMax,
+		"csumEnabled":        m.CsumEnabled,
+	}
+	if m.Retransmits.Valid {
+		r["retransmits"] = m.Retransmits.Value
+	}
+	if m.BytesRetrans.Valid {
+		r["bytesRetrans"] = m.BytesRetrans.Value
+	}
+	if m.BytesSent.Valid {
+		r["bytesSent"] = m.BytesSent.Value
+	}
+	if m.BytesReceived.Valid {
+		r["bytesReceived"] = m.BytesReceived.Value
+	}
+	if m.BytesAcked.Valid {
+		r["bytesAcked"] = m.BytesAcked.Value
+	}
+	if m.SubflowsTotal.Valid {
+		r["subflowsTotal"] = m.SubflowsTotal.Value
+	}
+	if len(m.SubflowInfo) > 0 {
+		subflows := make([]map[string]any, len(m.SubflowInfo))
+		for i, s := range m.SubflowInfo {
+			subflows[i] = s.ToMap()
+		}
+		r["subflowInfo"] = subflows
+	}
+	return r
+}
+
+// IsMPTCP reports whether the given socket was created with IPPROTO_MPTCP.
+func IsMPTCP(fds uintptr) (bool, error) {
+	proto, err := unix.GetsockoptInt(int(fds), unix.SOL_SOCKET, unix.SO_PROTOCOL)
+	if err != nil {
+		return false, err
+	}
+	return proto == unix.IPPROTO_MPTCP, nil
+}
+
+// GetMPTCPInfo retrieves MPTCP_INFO for the given MPTCP socket along with the tcp_info of each
+// subflow from MPTCP_TCPINFO. The SOL_MPTCP socket options require kernel 5.16; older kernels
+// return an error wrapping ENOPROTOOPT.
+func GetMPTCPInfo(fds uintptr) (*MPTCPInfo, error) {
+	if !kernelVersionIsAtLeast_5_16 {
+		return nil, mptcpInfoError(ENOPROTOOPT)
+	}
+
+	var raw RawMPTCPInfo
+	length := uint32(unsafe.Sizeof(raw))
+	if errNo := getsockopt(fds, unix.SOL_MPTCP, MPTCP_INFO, unsafe.Pointer(&raw), &length); errNo != 0 {
+		return nil, mptcpInfoError(errNo)
+	}
+	info := raw.Unpack(int(length))
+
+	subflows, err := getMPTCPSubflowInfo(fds)
+	if err != nil {
+		return info, err
+	}
+	info.SubflowInfo = subflows
+	return info, nil
+}
+
+// getMPTCPSubflowInfo reads MPTCP_TCPINFO, a rawMPTCPSubflowData header followed by one tcp_info
+// per subflow, and unpacks each tcp_info.
+func getMPTCPSubflowInfo(fds uintptr) ([]*SysInfo, error) {
+	type result struct {
+		header rawMPTCPSubflowData
+		data   [mptcpMaxSubflows]RawTCPInfo
+	}
+	var res result
+	res.header.SizeSubflowData = uint32(unsafe.Sizeof(res.header))
+	res.header.SizeUser = uint32(unsafe.Sizeof(RawTCPInfo{}))
+	length := uint32(unsafe.Sizeof(res))
+	if errNo := getsockopt(fds, unix.SOL_MPTCP, MPTCP_TCPINFO, unsafe.Pointer(&res), &length); errNo != 0 {
+		return nil, mptcpInfoError(errNo)
+	}
+
+	n := min(int(res.header.NumSubflows), mptcpMaxSubflows)
+	size := int(min(res.header.SizeKernel, res.header.SizeUser))
+	subflows := make([]*SysInfo, n)
+	for i := range subflows {
+		subflows[i] = res.data[i].UnpackWithLen(size)
+	}
+	return subflows, nil
+}
+
+// mptcpInfoError wraps an error from retrieving MPTCP details so it can be matched with errors.Is.
+func mptcpInfoError(err error) error {
+	return fmt.Errorf("tcp_info: mptcp: %w", err)
+}
@@ -0,0 +1,141 @@
+//go:build linux
+
+package tcpinfo
+
+import (
+	"context"
+	"io"
+	"net"
+	"os"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+// mptcpPair returns a connected MPTCP client and server, skipping the test if MPTCP is unavailable.
+func mptcpPair(t *testing.T) (client, server net.Conn) {
+	t.Helper()
+	if b, err := os.ReadFile("/proc/sys/net/mptcp/enabled"); err != nil || strings.TrimSpace(string(b)) != "1" {
+		t.Skip("MPTCP is not enabled")
+	}
+	if !kernelVersionIsAtLeast_5_16 {
+		t.Skip("MPTCP socket options require kernel 5.16")
+	}
+
+	var lc net.ListenConfig
+	lc.SetMultipathTCP(true)
+	ln, err := lc.Listen(context.Background(), "tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	var d net.Dialer
+	d.SetMultipathTCP(true)
+	client, err = d.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { _ = client.Close() })
+	server, err = ln.Accept()
+	if err != nil {
+		t.Fatalf("accept: %v", err)
+	}
+	t.Cleanup(func() { _ = server.Close() })
+
+	// The net package silently falls back to plain TCP when MPTCP sockets cannot be created
+	if ok, _ := client.(*net.TCPConn).MultipathTCP(); !ok {
+		t.Skip("MPTCP was not negotiated")
+	}
+	return client, server
+}
+
+func TestGetMPTCPInfo(t *testing.T) {
+	client, server := mptcpPair(t)
+	if _, err := client.Write([]byte("hello")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if _, err := io.ReadFull(server, make([]byte, 5)); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	raw, err := client.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("syscall conn: %v", err)
+	}
+	var (
+		isMPTCP  bool
+		info     *MPTCPInfo
+		mptcpErr error
+		sysInfo  *SysInfo
+	)
+	if err := raw.Control(func(fd uintptr) {
+		isMPTCP, _ = IsMPTCP(fd)
+		info, mptcpErr = GetMPTCPInfo(fd)
+		// GetTCPInfo may return partial results with an error for missing congestion control details
+		sysInfo, _ = GetTCPInfo(fd)
+	}); err != nil {
+		t.Fatalf("control: %v", err)
+	}
+	if !isMPTCP {
+		t.Fatalf("IsMPTCP = false for an MPTCP socket")
+	}
+	if mptcpErr != nil {
+		t.Fatalf("GetMPTCPInfo: %v", mptcpErr)
+	}
+	if len(info.SubflowInfo) != 1 || info.SubflowInfo[0].StateName != "ESTABLISHED" {
+		t.Fatalf("expected one established subflow, got %+v", info.SubflowInfo)
+	}
+	if info.BytesSent.Valid && info.BytesSent.Value != 5 {
+		t.Errorf("BytesSent = %d, want 5", info.BytesSent.Value)
+	}
+	if info.SubflowInfo[0].BytesSent.Valid && info.SubflowInfo[0].BytesSent.Value < 5 {
+		t.Errorf("subflow BytesSent = %d, want at least 5", info.SubflowInfo[0].BytesSent.Value)
+	}
+	if sysInfo == nil || sysInfo.MPTCP == nil {
+		t.Fatalf("GetTCPInfo did not attach MPTCP info: %+v", sysInfo)
+	}
+	if _, ok := sysInfo.ToMap()["mptcp"].(map[string]any); !ok {
+		t.Errorf("missing mptcp in ToMap")
+	}
+}
+
+func TestGetTCPInfoNotMPTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("syscall conn: %v", err)
+	}
+	var sysInfo *SysInfo
+	if err := raw.Control(func(fd uintptr) {
+		sysInfo, _ = GetTCPInfo(fd)
+	}); err != nil {
+		t.Fatalf("control: %v", err)
+	}
+	if sysInfo == nil || sysInfo.MPTCP != nil {
+		t.Fatalf("unexpected MPTCP info for a plain TCP socket: %+v", sysInfo)
+	}
+}
+
+func TestRawMPTCPInfoUnpackLen(t *testing.T) {
+	raw := RawMPTCPInfo{Subflows: 1, BytesSent: 10, SubflowsTotal: 2}
+	// A 5.16 kernel stops after csum_enabled
+	old := raw.Unpack(int(unsafe.Offsetof(raw.CsumEnabled)) + 1)
+	if old.Subflows != 1 || old.BytesSent.Valid || old.SubflowsTotal.Valid {
+		t.Errorf("unexpected fields for a short mptcp_info: %+v", old)
+	}
+	full := raw.Unpack(int(unsafe.Sizeof(raw)))
+	if !full.BytesSent.Valid || full.BytesSent.Value != 10 || !full.SubflowsTotal.Valid || full.SubflowsTotal.Value != 2 {
+		t.Errorf("unexpected fields for a full mptcp_info: %+v", full)
+	}
+}
@@ -0,0 +1,50 @@
+package tcpinfo
+
+import (
+	"bytes"
+	"encoding/json"
+	"time"
+)
+
+// Nullable holds a value that may not be reported by the running kernel. Valid is
+// only set to true when the value was actually provided.
+type Nullable[T any] struct {
+	Valid bool
+	Value T
+}
+
+// Get returns the value and whether it is valid.
+func (n Nullable[T]) Get() (T, bool) {
+	return n.Value, n.Valid
+}
+
+// MarshalJSON encodes an invalid value as null and a valid value as the bare value.
+func (n Nullable[T]) MarshalJSON() ([]byte, error) {
+	if !n.Valid {
+		return []byte("null"), nil
+	}
+	return json.Marshal(n.Value)
+}
+
+// UnmarshalJSON is the inverse of MarshalJSON; null decodes to an invalid zero value.
+func (n *Nullable[T]) UnmarshalJSON(data []byte) error {
+	if bytes.Equal(bytes.TrimSpace(data), []byte("null")) {
+		var zero T
+		n.Value, n.Valid = zero, false
+		return nil
+	}
+	if err := json.Unmarshal(data, &n.Value); err != nil {
+		return err
+	}
+	n.Valid = true
+	return nil
+}
+
+type (
+	NullableBool     = Nullable[bool]
+	NullableUint8    = Nullable[uint8]
+	NullableUint16   = Nullable[uint16]
+	NullableUint32   = Nullable[uint32]
+	NullableUint64   = Nullable[uint64]
+	NullableDuration = Nullable[time.Duration]
+)
@@ -0,0 +1,72 @@
+package tcpinfo
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestNullableGet(t *testing.T) {
+	v, ok := NullableUint32{Valid: true, Value: 7}.Get()
+	if !ok || v != 7 {
+		t.Fatalf("unexpected Get result: %d, %v", v, ok)
+	}
+	if _, ok := (NullableUint32{}).Get(); ok {
+		t.Fatal("expected zero value to be invalid")
+	}
+}
+
+func TestNullableJSON(t *testing.T) {
+	type sample struct {
+		Bytes  NullableUint64   `json:"bytes"`
+		MinRTT NullableDuration `json:"minRTT"`
+	}
+
+	tests := []struct {
+		name string
+		in   sample
+		want string
+	}{
+		{
+			name: "invalid",
+			in:   sample{},
+			want: `{"bytes":null,"minRTT":null}`,
+		},
+		{
+			name: "valid",
+			in: sample{
+				Bytes:  NullableUint64{Valid: true, Value: 1234},
+				MinRTT: NullableDuration{Valid: true, Value: 5 * time.Millisecond},
+			},
+			want: `{"bytes":1234,"minRTT":5000000}`,
+		},
+		{
+			name: "valid zero",
+			in: sample{
+				Bytes:  NullableUint64{Valid: true},
+				MinRTT: NullableDuration{Valid: true},
+			},
+			want: `{"bytes":0,"minRTT":0}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data, err := json.Marshal(tt.in)
+			if err != nil {
+				t.Fatalf("marshal: %v", err)
+			}
+			if string(data) != tt.want {
+				t.Fatalf("got %s, want %s", data, tt.want)
+			}
+
+			var out sample
+			if err := json.Unmarshal(data, &out); err != nil {
+				t.Fatalf("unmarshal: %v", err)
+			}
+			if out != tt.in {
+				t.Fatalf("round trip mismatch: got %+v, want %+v", out, tt.in)
+			}
+		})
+	}
+}
@@ -0,0 +1,162 @@
+//go:build linux
+
+package tcpinfo
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// procNetTCPFiles are searched in order by TCPInfoFromProc. IPv4 connections on dual-stack sockets
+// are listed in tcp6 with IPv4-mapped addresses.
+var procNetTCPFiles = []string{"/proc/net/tcp", "/proc/net/tcp6"}
+
+// procClockTick is the unit of the rto and ato columns, which the kernel reports in USER_HZ ticks.
+const procClockTick = time.Second / 100
+
+// tcpInfiniteSSThresh is reported by TCP_INFO for a connection still in its initial slow start,
+// which /proc/net/tcp shows as -1.
+const tcpInfiniteSSThresh = 0x7fffffff
+
+// TCPInfoFromProc looks up the connection with the given local and remote address in /proc/net/tcp
+// and /proc/net/tcp6, for environments where getsockopt(TCP_INFO) is blocked but procfs is readable.
+// Only State, StateName, Retransmits, Probes, RTO, ATO, TxCWindow, and TxSSThreshold are filled in;
+// procfs does not report RTT or the other TCP_INFO fields, so they stay zero and Nullable fields stay
+// invalid. The returned error wraps ENOENT if no matching connection is found.
+func TCPInfoFromProc(local, remote netip.AddrPort) (*SysInfo, error) {
+	for _, name := range procNetTCPFiles {
+		info, err := tcpInfoFromProcFile(name, local, remote)
+		if errors.Is(err, fs.ErrNotExist) {
+			// tcp6 is missing when IPv6 is disabled
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if info != nil {
+			return info, nil
+		}
+	}
+	return nil, fmt.Errorf("tcp_info: no procfs entry for %s -> %s: %w", local, remote, ENOENT)
+}
+
+func tcpInfoFromProcFile(name string, local, remote netip.AddrPort) (*SysInfo, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	info, err := parseProcNetTCP(f, local, remote)
+	if err != nil {
+		return nil, fmt.Errorf("tcp_info: %s: %w", name, err)
+	}
+	return info, nil
+}
+
+// parseProcNetTCP returns the entry matching local and remote, or nil if there is none.
+func parseProcNetTCP(r io.Reader, local, remote netip.AddrPort) (*SysInfo, error) {
+	local = netip.AddrPortFrom(local.Addr().Unmap(), local.Port())
+	remote = netip.AddrPortFrom(remote.Addr().Unmap(), remote.Port())
+
+	sc := bufio.NewScanner(r)
+	// Skip the header line
+	sc.Scan()
+	for sc.Scan() {
+		fields := strings.Fields(sc.Text())
+		if len(fields) < 12 {
+			continue
+		}
+		l, err := parseProcAddr(fields[1])
+		if err != nil {
+			return nil, err
+		}
+		if l != local {
+			continue
+		}
+		rem, err := parseProcAddr(fields[2])
+		if err != nil {
+			return nil, err
+		}
+		if rem != remote {
+			continue
+		}
+		return parseProcEntry(fields)
+	}
+	return nil, sc.Err()
+}
+
+// parseProcEntry converts the columns of a /proc/net/tcp line into a SysInfo. TIME_WAIT and
+// SYN_RECV entries stop after the socket pointer and only report the state.
+func parseProcEntry(fields []string) (*SysInfo, error) {
+	state, err := strconv.ParseUint(fields[3], 16, 8)
+	if err != nil {
+		return nil, fmt.Errorf("bad state %q: %w", fields[3], err)
+	}
+	info := &SysInfo{
+		State:     uint8(state),
+		StateName: tcpStateMap[uint8(state)],
+		sampledAt: time.Now(),
+	}
+	if len(fields) < 17 {
+		return info, nil
+	}
+
+	// The retransmit count is printed as hex, like the columns before it; the rest are decimal.
+	var cols [17]int64
+	for _, i := range []int{6, 8, 12, 13, 15, 16} {
+		base := 10
+		if i == 6 {
+			base = 16
+		}
+		if cols[i], err = strconv.ParseInt(fields[i], base, 32); err != nil {
+			return nil, fmt.Errorf("bad column %d %q: %w", i, fields[i], err)
+		}
+	}
+	info.Retransmits = uint8(cols[6])
+	info.Probes = uint8(cols[8])
+	info.RTO = time.Duration(cols[12]) * procClockTick
+	info.ATO = time.Duration(cols[13]) * procClockTick
+	info.TxCWindow = uint32(cols[15])
+	switch ssthresh := cols[16]; {
+	case ssthresh == -1:
+		// Printed for a connection still in its initial slow start
+		info.TxSSThreshold = tcpInfiniteSSThresh
+	case ssthresh < 0:
+		return nil, fmt.Errorf("bad column 16 %q: negative ssthresh", fields[16])
+	default:
+		info.TxSSThreshold = uint32(ssthresh)
+	}
+	return info, nil
+}
+
+// parseProcAddr parses an address such as 0100007F:1F90. The kernel prints each 32-bit word of the
+// address in host byte order, and the port in hex.
+func parseProcAddr(s string) (netip.AddrPort, error) {
+	hexAddr, hexPort, ok := strings.Cut(s, ":")
+	if !ok {
+		return netip.AddrPort{}, fmt.Errorf("bad address %q", s)
+	}
+	raw, err := hex.DecodeString(hexAddr)
+	if err != nil || (len(raw) != 4 && len(raw) != 16) {
+		return netip.AddrPort{}, fmt.Errorf("bad address %q", s)
+	}
+	port, err := strconv.ParseUint(hexPort, 16, 16)
+	if err != nil {
+		return netip.AddrPort{}, fmt.Errorf("bad port %q", s)
+	}
+	for i := 0; i < len(raw); i += 4 {
+		binary.NativeEndian.PutUint32(raw[i:], binary.BigEndian.Uint32(raw[i:]))
+	}
+	addr, _ := netip.AddrFromSlice(raw)
+	return netip.AddrPortFrom(addr.Unmap(), uint16(port)), nil
+}
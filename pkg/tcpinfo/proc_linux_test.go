@@ -0,0 +1,108 @@
+//go:build linux
+
+package tcpinfo
+
+import (
+	"encoding/binary"
+	"errors"
+	"net"
+	"net/netip"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// useProcFixtures points TCPInfoFromProc at the testdata files, which were captured on a
+// little-endian host.
+func useProcFixtures(t *testing.T) {
+	if binary.NativeEndian.Uint16([]byte{1, 0}) != 1 {
+		t.Skip("procfs fixtures use little-endian addresses")
+	}
+	orig := procNetTCPFiles
+	t.Cleanup(func() { procNetTCPFiles = orig })
+	procNetTCPFiles = []string{"testdata/proc_net_tcp", "testdata/proc_net_tcp6", "testdata/missing"}
+}
+
+func TestTCPInfoFromProcFixture(t *testing.T) {
+	useProcFixtures(t)
+
+	info, err := TCPInfoFromProc(netip.MustParseAddrPort("127.0.0.1:8080"), netip.MustParseAddrPort("127.0.0.1:54321"))
+	if err != nil {
+		t.Fatalf("TCPInfoFromProc: %v", err)
+	}
+	if info.StateName != "ESTABLISHED" || info.Retransmits != 2 || info.Probes != 1 {
+		t.Errorf("state=%s retransmits=%d probes=%d, want ESTABLISHED/2/1", info.StateName, info.Retransmits, info.Probes)
+	}
+	if info.RTO != 240*time.Millisecond || info.ATO != 40*time.Millisecond {
+		t.Errorf("rto=%v ato=%v, want 240ms/40ms", info.RTO, info.ATO)
+	}
+	if info.TxCWindow != 10 || info.TxSSThreshold != tcpInfiniteSSThresh {
+		t.Errorf("cwnd=%d ssthresh=%d, want 10/%d", info.TxCWindow, info.TxSSThreshold, tcpInfiniteSSThresh)
+	}
+	if info.RTT != 0 || info.MinRTT.Valid {
+		t.Errorf("unexpected RTT fields: rtt=%v minRTT=%+v", info.RTT, info.MinRTT)
+	}
+
+	// The retransmit count is hex, so 12 retransmits reads 0000000C
+	info, err = TCPInfoFromProc(netip.MustParseAddrPort("127.0.0.1:8080"), netip.MustParseAddrPort("127.0.0.1:54323"))
+	if err != nil {
+		t.Fatalf("TCPInfoFromProc hex retransmits: %v", err)
+	}
+	if info.Retransmits != 12 || info.TxSSThreshold != 2 {
+		t.Errorf("retransmits=%d ssthresh=%d, want 12/2", info.Retransmits, info.TxSSThreshold)
+	}
+
+	info, err = TCPInfoFromProc(netip.MustParseAddrPort("127.0.0.1:8080"), netip.MustParseAddrPort("127.0.0.1:54322"))
+	if err != nil {
+		t.Fatalf("TCPInfoFromProc TIME_WAIT: %v", err)
+	}
+	if info.StateName != "TIME_WAIT" {
+		t.Errorf("state=%s, want TIME_WAIT", info.StateName)
+	}
+
+	// IPv4 connections on dual-stack sockets are listed in tcp6 with mapped addresses
+	info, err = TCPInfoFromProc(netip.MustParseAddrPort("127.0.0.1:8081"), netip.MustParseAddrPort("127.0.0.1:54323"))
+	if err != nil {
+		t.Fatalf("TCPInfoFromProc tcp6: %v", err)
+	}
+	if info.StateName != "ESTABLISHED" || info.TxCWindow != 14 || info.TxSSThreshold != 7 {
+		t.Errorf("state=%s cwnd=%d ssthresh=%d, want ESTABLISHED/14/7", info.StateName, info.TxCWindow, info.TxSSThreshold)
+	}
+
+	fields := strings.Fields("3: 0100007F:1F90 0100007F:D433 01 00000000:00000000 00:00000000 00000000 0 0 1 1 0 20 4 0 10 -2")
+	if _, err := parseProcEntry(fields); err == nil {
+		t.Errorf("expected an error for a negative ssthresh other than -1")
+	}
+
+	_, err = TCPInfoFromProc(netip.MustParseAddrPort("127.0.0.1:8080"), netip.MustParseAddrPort("127.0.0.1:1"))
+	if !errors.Is(err, ENOENT) {
+		t.Fatalf("expected ENOENT for an unknown connection, got %v", err)
+	}
+}
+
+func TestTCPInfoFromProcLive(t *testing.T) {
+	if _, err := os.Stat(procNetTCPFiles[0]); err != nil {
+		t.Skipf("procfs not available: %v", err)
+	}
+	ln, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	conn, err := net.Dial("tcp4", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	local := netip.MustParseAddrPort(conn.LocalAddr().String())
+	remote := netip.MustParseAddrPort(conn.RemoteAddr().String())
+	info, err := TCPInfoFromProc(local, remote)
+	if err != nil {
+		t.Fatalf("TCPInfoFromProc: %v", err)
+	}
+	if info.StateName != "ESTABLISHED" || info.TxCWindow == 0 {
+		t.Fatalf("state=%s cwnd=%d, want ESTABLISHED and a congestion window", info.StateName, info.TxCWindow)
+	}
+}
@@ -0,0 +1,26 @@
+package tcpinfo
+
+import "time"
+
+// SocketOpts holds the socket-level options in effect on a TCP socket, as read by GetSocketOpts.
+type SocketOpts struct {
+	NoDelay  bool           `json:"noDelay"`          // TCP_NODELAY, Nagle's algorithm is disabled
+	QuickAck bool           `json:"quickAck"`         // TCP_QUICKACK, delayed acknowledgements are disabled [Linux only]
+	Linger   *time.Duration `json:"linger,omitempty"` // SO_LINGER timeout, nil when lingering is disabled
+	SendBuf  int            `json:"sendBuf"`          // SO_SNDBUF size in bytes
+	RecvBuf  int            `json:"recvBuf"`          // SO_RCVBUF size in bytes
+}
+
+// ToMap converts the SocketOpts to a map[string]any, leaving out linger when it is disabled.
+func (o *SocketOpts) ToMap() map[string]any {
+	m := map[string]any{
+		"noDelay":  o.NoDelay,
+		"quickAck": o.QuickAck,
+		"sendBuf":  o.SendBuf,
+		"recvBuf":  o.RecvBuf,
+	}
+	if o.Linger != nil {
+		m["linger"] = *o.Linger
+	}
+	return m
+}
@@ -0,0 +1,13 @@
+//go:build !(linux || darwin || windows || freebsd)
+
+package tcpinfo
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// GetSocketOpts is not implemented on this platform.
+func GetSocketOpts(fds uintptr) (SocketOpts, error) {
+	return SocketOpts{}, fmt.Errorf("getting socket options: %w: %s", ErrUnsupportedPlatform, runtime.GOOS)
+}
@@ -0,0 +1,40 @@
+//go:build linux || darwin || freebsd
+
+package tcpinfo
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// GetSocketOpts reads TCP_NODELAY, TCP_QUICKACK (Linux only), SO_LINGER, SO_SNDBUF, and SO_RCVBUF
+// from the given socket.
+func GetSocketOpts(fds uintptr) (SocketOpts, error) {
+	fd := int(fds)
+	var opts SocketOpts
+	noDelay, err := unix.GetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_NODELAY)
+	if err != nil {
+		return opts, fmt.Errorf("could not get nodelay: %w", err)
+	}
+	opts.NoDelay = noDelay != 0
+	if opts.QuickAck, err = getQuickAck(fd); err != nil {
+		return opts, fmt.Errorf("could not get quickack: %w", err)
+	}
+	linger, err := unix.GetsockoptLinger(fd, unix.SOL_SOCKET, soLinger)
+	if err != nil {
+		return opts, fmt.Errorf("could not get linger: %w", err)
+	}
+	if linger.Onoff != 0 {
+		d := time.Duration(linger.Linger) * time.Second
+		opts.Linger = &d
+	}
+	if opts.SendBuf, err = unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_SNDBUF); err != nil {
+		return opts, fmt.Errorf("could not get send buffer size: %w", err)
+	}
+	if opts.RecvBuf, err = unix.GetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_RCVBUF); err != nil {
+		return opts, fmt.Errorf("could not get receive buffer size: %w", err)
+	}
+	return opts, nil
+}
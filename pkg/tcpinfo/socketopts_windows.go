@@ -0,0 +1,40 @@
+//go:build windows
+
+package tcpinfo
+
+import (
+	"fmt"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// GetSocketOpts reads TCP_NODELAY, SO_LINGER, SO_SNDBUF, and SO_RCVBUF from the given socket.
+// QuickAck is always false, since Windows has no TCP_QUICKACK.
+func GetSocketOpts(fds uintptr) (SocketOpts, error) {
+	fd := windows.Handle(fds)
+	var opts SocketOpts
+	noDelay, err := windows.GetsockoptInt(fd, windows.IPPROTO_TCP, windows.TCP_NODELAY)
+	if err != nil {
+		return opts, fmt.Errorf("could not get nodelay: %w", err)
+	}
+	opts.NoDelay = noDelay != 0
+	// Winsock's LINGER uses u_short fields, unlike windows.Linger.
+	var linger struct{ Onoff, Linger uint16 }
+	size := int32(unsafe.Sizeof(linger))
+	if err := windows.Getsockopt(fd, windows.SOL_SOCKET, windows.SO_LINGER, (*byte)(unsafe.Pointer(&linger)), &size); err != nil {
+		return opts, fmt.Errorf("could not get linger: %w", err)
+	}
+	if linger.Onoff != 0 {
+		d := time.Duration(linger.Linger) * time.Second
+		opts.Linger = &d
+	}
+	if opts.SendBuf, err = windows.GetsockoptInt(fd, windows.SOL_SOCKET, windows.SO_SNDBUF); err != nil {
+		return opts, fmt.Errorf("could not get send buffer size: %w", err)
+	}
+	if opts.RecvBuf, err = windows.GetsockoptInt(fd, windows.SOL_SOCKET, windows.SO_RCVBUF); err != nil {
+		return opts, fmt.Errorf("could not get receive buffer size: %w", err)
+	}
+	return opts, nil
+}
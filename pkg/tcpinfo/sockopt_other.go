@@ -0,0 +1,28 @@
+//go:build !linux
+
+package tcpinfo
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// SetTCPCongestionAlgorithm is only supported on Linux.
+func SetTCPCongestionAlgorithm(fds uintptr, algo string) error {
+	return fmt.Errorf("setting the congestion control algorithm: %w: %s", ErrUnsupportedPlatform, runtime.GOOS)
+}
+
+// SetNotSentLowat is only supported on Linux.
+func SetNotSentLowat(fds uintptr, bytes uint32) error {
+	return fmt.Errorf("setting notsent lowat: %w: %s", ErrUnsupportedPlatform, runtime.GOOS)
+}
+
+// GetNotSentLowat is only supported on Linux.
+func GetNotSentLowat(fds uintptr) (uint32, error) {
+	return 0, fmt.Errorf("getting notsent lowat: %w: %s", ErrUnsupportedPlatform, runtime.GOOS)
+}
+
+// SetMaxPacingRate is only supported on Linux.
+func SetMaxPacingRate(fds uintptr, bytesPerSec uint64) error {
+	return fmt.Errorf("setting the max pacing rate: %w: %s", ErrUnsupportedPlatform, runtime.GOOS)
+}
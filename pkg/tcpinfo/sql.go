@@ -0,0 +1,45 @@
+package tcpinfo
+
+import (
+	"database/sql"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+var (
+	_ driver.Valuer = (*Info)(nil)
+	_ sql.Scanner   = (*Info)(nil)
+)
+
+// Value implements driver.Valuer, storing the Info as its JSON encoding so it can be written
+// to a JSON or JSONB column directly. A nil Info is stored as NULL.
+func (i *Info) Value() (driver.Value, error) {
+	if i == nil {
+		return nil, nil
+	}
+	return json.Marshal(i)
+}
+
+// Scan implements sql.Scanner, decoding an Info from the JSON written by Value. A NULL
+// column resets the Info to its zero value.
+func (i *Info) Scan(src any) error {
+	var data []byte
+	switch v := src.(type) {
+	case nil:
+		*i = Info{}
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("tcp_info: cannot scan %T into Info", src)
+	}
+	var info Info
+	if err := json.Unmarshal(data, &info); err != nil {
+		return fmt.Errorf("tcp_info: scan Info: %w", err)
+	}
+	*i = info
+	return nil
+}
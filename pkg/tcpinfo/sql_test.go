@@ -0,0 +1,54 @@
+package tcpinfo
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestInfoValueScan(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	want := &Info{
+		State:         "ESTABLISHED",
+		LocalAddr:     "127.0.0.1:40000",
+		RemoteAddr:    "127.0.0.1:443",
+		TxOptions:     []Option{{Kind: "SACK"}, {Kind: "WindowScale", Value: 7}},
+		RxOptions:     []Option{{Kind: "Timestamps"}},
+		TxMSS:         1448,
+		RTT:           12 * time.Millisecond,
+		RTTVar:        3 * time.Millisecond,
+		TxWindowSegs:  10,
+		Retransmits:   2,
+		ECN:           ECNInfo{Negotiated: true, CEMarkedSegments: 1},
+		SampledAt:     at,
+		LastTxTime:    at.Add(-time.Second),
+		LastRxAckTime: at.Add(-time.Millisecond),
+	}
+
+	v, err := want.Value()
+	if err != nil {
+		t.Fatalf("Value: %v", err)
+	}
+	for name, src := range map[string]any{"bytes": v, "string": string(v.([]byte))} {
+		var got Info
+		if err := got.Scan(src); err != nil {
+			t.Fatalf("Scan(%s): %v", name, err)
+		}
+		if !reflect.DeepEqual(&got, want) {
+			t.Errorf("Scan(%s) = %+v, want %+v", name, &got, want)
+		}
+	}
+}
+
+func TestInfoValueScanNull(t *testing.T) {
+	if v, err := (*Info)(nil).Value(); v != nil || err != nil {
+		t.Errorf("nil Info Value() = %v, %v, want nil, nil", v, err)
+	}
+	info := &Info{State: "ESTABLISHED"}
+	if err := info.Scan(nil); err != nil || !reflect.DeepEqual(info, &Info{}) {
+		t.Errorf("Scan(nil) = %v, left %+v", err, info)
+	}
+	if err := info.Scan(42); err == nil {
+		t.Errorf("Scan(int) succeeded, want an error")
+	}
+}
@@ -0,0 +1,209 @@
+package tcpinfo
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ErrMalformedTag is returned by ParseTag for tags that cannot be parsed.
+var ErrMalformedTag = errors.New("malformed tcpi tag")
+
+// ParseTag parses the value of a `tcpi` struct tag, such as
+// "name=rtt,prom_type=gauge,prom_help='Smoothed RTT, in nanoseconds.'", into a map
+// of keys to values. Values may be wrapped in single quotes to include commas.
+func ParseTag(tag string) (map[string]string, error) {
+	res := map[string]string{}
+	for len(tag) > 0 {
+		eq := strings.IndexByte(tag, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("%w: missing '=' in %q", ErrMalformedTag, tag)
+		}
+		key := strings.TrimSpace(tag[:eq])
+		if key == "" || strings.ContainsRune(key, ',') {
+			return nil, fmt.Errorf("%w: invalid key %q", ErrMalformedTag, tag[:eq])
+		}
+		tag = tag[eq+1:]
+
+		var value string
+		if strings.HasPrefix(tag, "'") {
+			end := strings.IndexByte(tag[1:], '\'')
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated quote for %q", ErrMalformedTag, key)
+			}
+			value = tag[1 : end+1]
+			tag = tag[end+2:]
+			if len(tag) > 0 && tag[0] != ',' {
+				return nil, fmt.Errorf("%w: unexpected %q after quoted value for %q", ErrMalformedTag, tag, key)
+			}
+		} else {
+			end := strings.IndexByte(tag, ',')
+			if end < 0 {
+				end = len(tag)
+			}
+			value = tag[:end]
+			tag = tag[end:]
+		}
+		res[key] = value
+		tag = strings.TrimPrefix(tag, ",")
+	}
+	return res, nil
+}
+
+// Field describes a tcpi-tagged field of the platform SysInfo, as returned by FieldMetadata.
+type Field struct {
+	GoName   string // SysInfo field name, such as "RTT"
+	Key      string // SysInfo.ToMap key, such as "rtt"
+	PromName string // The name key of the tcpi tag, such as "rtt"; exporters add a prefix like "tcpinfo_"
+	Help     string // The prom_help key of the tcpi tag
+	PromType string // The prom_type key of the tcpi tag, "gauge" or "counter"
+	Unit     string // "seconds", "bytes", "bytes_per_second", or empty when unknown or unitless
+	Numeric  bool   // Whether the field holds a number or bool, or a Nullable of one, that exporters can report
+}
+
+var (
+	durationType         = reflect.TypeOf(time.Duration(0))
+	nullableDurationType = reflect.TypeOf(NullableDuration{})
+)
+
+// fieldUnit guesses the unit of a field from its type and metric name. Durations are reported
+// in seconds by the exporters.
+func fieldUnit(t reflect.Type, name string) string {
+	switch {
+	case t == durationType || t == nullableDurationType:
+		return "seconds"
+	case strings.HasSuffix(name, "_rate"):
+		return "bytes_per_second"
+	case strings.Contains(name, "bytes"):
+		return "bytes"
+	}
+	return ""
+}
+
+// mapKey returns the SysInfo.ToMap key of f: the key option of its tcpi tag, for the few fields
+// whose JSON name predates their ToMap key, or else its JSON name. It returns an empty string for
+// fields without a json tag or left out of JSON.
+func mapKey(f reflect.StructField, opts map[string]string) string {
+	if key := opts["key"]; key != "" {
+		return key
+	}
+	key, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	if key == "-" {
+		return ""
+	}
+	return key
+}
+
+// isNumeric reports whether t, or the Value of a Nullable, is a number or bool.
+func isNumeric(t reflect.Type) bool {
+	if t.Kind() == reflect.Struct {
+		f, ok := t.FieldByName("Value")
+		if !ok {
+			return false
+		}
+		t = f.Type
+	}
+	switch t.Kind() {
+	case reflect.Bool, reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Float32, reflect.Float64:
+		return true
+	}
+	return false
+}
+
+var fieldMetadata = sync.OnceValue(func() []Field {
+	var fields []Field
+	t := reflect.TypeOf(SysInfo{})
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, ok := f.Tag.Lookup("tcpi")
+		if !ok {
+			continue
+		}
+		opts, err := ParseTag(tag)
+		if err != nil {
+			continue
+		}
+		fields = append(fields, Field{
+			GoName:   f.Name,
+			Key:      mapKey(f, opts),
+			PromName: opts["name"],
+			Help:     opts["prom_help"],
+			PromType: opts["prom_type"],
+			Unit:     fieldUnit(f.Type, opts["name"]),
+			Numeric:  isNumeric(f.Type),
+		})
+	}
+	return fields
+})
+
+// FieldMetadata returns a description of every tcpi-tagged field of the platform SysInfo, in
+// declaration order. The tags are parsed once; each call returns a new slice.
+func FieldMetadata() []Field {
+	return slices.Clone(fieldMetadata())
+}
+
+// MetricValue converts the value of a numeric field, either from SysInfo itself or from its ToMap,
+// to a float64 for exporters. Bools become 0 or 1 and durations are converted to seconds. Invalid
+// Nullables and values that are not numeric are not reported.
+func MetricValue(v any) (float64, bool) {
+	if d, ok := v.(time.Duration); ok {
+		return d.Seconds(), true
+	}
+	rv, ok := nullableValue(reflect.ValueOf(v))
+	if !ok {
+		return 0, false
+	}
+	if rv.Type() == durationType {
+		return time.Duration(rv.Int()).Seconds(), true
+	}
+	switch rv.Kind() {
+	case reflect.Bool:
+		if rv.Bool() {
+			return 1, true
+		}
+		return 0, true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(rv.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(rv.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return rv.Float(), true
+	}
+	return 0, false
+}
+
+// CounterValue converts the value of an integer field, such as one tagged prom_type=counter, to an
+// int64 for exporters, in the same way as MetricValue. Durations are left in nanoseconds.
+func CounterValue(v any) (int64, bool) {
+	rv, ok := nullableValue(reflect.ValueOf(v))
+	if !ok {
+		return 0, false
+	}
+	switch rv.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return rv.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(rv.Uint()), true
+	}
+	return 0, false
+}
+
+// nullableValue returns the Value of a valid Nullable, false for an invalid one, and v itself otherwise.
+func nullableValue(v reflect.Value) (reflect.Value, bool) {
+	if !v.IsValid() {
+		return v, false
+	}
+	if v.Kind() == reflect.Struct {
+		valid, value := v.FieldByName("Valid"), v.FieldByName("Value")
+		if !valid.IsValid() || !value.IsValid() || !valid.Bool() {
+			return v, false
+		}
+		return value, true
+	}
+	return v, true
+}
@@ -0,0 +1,139 @@
+package tcpinfo
+
+import (
+	"errors"
+	"maps"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTag(t *testing.T) {
+	got, err := ParseTag("name=rtt,prom_type=gauge,prom_help='Smoothed RTT, in nanoseconds.'")
+	if err != nil {
+		t.Fatalf("ParseTag: %v", err)
+	}
+	want := map[string]string{"name": "rtt", "prom_type": "gauge", "prom_help": "Smoothed RTT, in nanoseconds."}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("ParseTag() = %v, want %v", got, want)
+	}
+
+	for tag, want := range map[string]map[string]string{
+		"":                   {},
+		"name=rtt,":          {"name": "rtt"},
+		"name=,prom_type=''": {"name": "", "prom_type": ""},
+		"prom_help='it''s'":  nil,
+		"name=it's":          {"name": "it's"},
+		" name =rtt":         {"name": "rtt"},
+	} {
+		got, err := ParseTag(tag)
+		if want == nil {
+			if !errors.Is(err, ErrMalformedTag) {
+				t.Errorf("ParseTag(%q) error = %v, want ErrMalformedTag", tag, err)
+			}
+			continue
+		}
+		if err != nil || !reflect.DeepEqual(got, want) {
+			t.Errorf("ParseTag(%q) = %v, %v, want %v", tag, got, err, want)
+		}
+	}
+
+	for _, tag := range []string{"name", "name=rtt,prom_help='unterminated", "=rtt", "name='a'b", "name=rtt,,prom_type=gauge"} {
+		if _, err := ParseTag(tag); !errors.Is(err, ErrMalformedTag) {
+			t.Errorf("ParseTag(%q) error = %v, want ErrMalformedTag", tag, err)
+		}
+	}
+}
+
+func FuzzParseTag(f *testing.F) {
+	for _, tag := range []string{
+		"name=rtt,prom_type=gauge,prom_help='Smoothed RTT, in nanoseconds.'",
+		"name='a'b",
+		"name=rtt,",
+		"=",
+		"",
+	} {
+		f.Add(tag)
+	}
+	f.Fuzz(func(t *testing.T, tag string) {
+		got, err := ParseTag(tag)
+		if err != nil {
+			if !errors.Is(err, ErrMalformedTag) {
+				t.Fatalf("ParseTag(%q) error = %v, want ErrMalformedTag", tag, err)
+			}
+			return
+		}
+
+		// Encoding the result again and parsing it must give the same map.
+		keys := slices.Sorted(maps.Keys(got))
+		parts := make([]string, len(keys))
+		for i, key := range keys {
+			if key == "" || strings.ContainsAny(key, ",=") {
+				t.Fatalf("ParseTag(%q) returned invalid key %q", tag, key)
+			}
+			value := got[key]
+			if strings.Contains(value, ",") || strings.HasPrefix(value, "'") {
+				if strings.Contains(value, "'") {
+					// Only reachable for unquoted values, which never contain commas.
+					t.Fatalf("ParseTag(%q) returned unencodable value %q", tag, value)
+				}
+				value = "'" + value + "'"
+			}
+			parts[i] = key + "=" + value
+		}
+		again, err := ParseTag(strings.Join(parts, ","))
+		if err != nil || !reflect.DeepEqual(again, got) {
+			t.Fatalf("ParseTag(%q) = %v, but re-encoded it gives %v, %v", tag, got, again, err)
+		}
+	})
+}
+
+func TestFieldMetadata(t *testing.T) {
+	fields := FieldMetadata()
+	if Supported() && len(fields) == 0 {
+		t.Fatal("no fields on a supported platform")
+	}
+	for _, f := range fields {
+		if f.GoName == "" || f.PromName == "" {
+			t.Errorf("field without a name: %+v", f)
+		}
+		if f.PromType != "gauge" && f.PromType != "counter" {
+			t.Errorf("%s: unexpected type %q", f.GoName, f.PromType)
+		}
+	}
+	if len(fields) > 0 {
+		fields[0].GoName = "changed"
+		if FieldMetadata()[0].GoName == "changed" {
+			t.Errorf("FieldMetadata returned a shared slice")
+		}
+	}
+}
+
+func TestMetricValue(t *testing.T) {
+	for _, tc := range []struct {
+		v       any
+		want    float64
+		ok      bool
+		counter int64
+		isCount bool
+	}{
+		{v: uint32(7), want: 7, ok: true, counter: 7, isCount: true},
+		{v: int64(-2), want: -2, ok: true, counter: -2, isCount: true},
+		{v: true, want: 1, ok: true},
+		{v: 1500 * time.Millisecond, want: 1.5, ok: true, counter: int64(1500 * time.Millisecond), isCount: true},
+		{v: NullableUint64{Valid: true, Value: 9}, want: 9, ok: true, counter: 9, isCount: true},
+		{v: NullableDuration{Valid: true, Value: time.Second}, want: 1, ok: true, counter: int64(time.Second), isCount: true},
+		{v: NullableUint64{Value: 9}},
+		{v: "reno"},
+		{v: nil},
+	} {
+		if got, ok := MetricValue(tc.v); got != tc.want || ok != tc.ok {
+			t.Errorf("MetricValue(%#v) = %v, %v, want %v, %v", tc.v, got, ok, tc.want, tc.ok)
+		}
+		if got, ok := CounterValue(tc.v); got != tc.counter || ok != tc.isCount {
+			t.Errorf("CounterValue(%#v) = %v, %v, want %v, %v", tc.v, got, ok, tc.counter, tc.isCount)
+		}
+	}
+}
@@ -1,13 +1,43 @@
 package tcpinfo
 
 import (
+	"encoding/json"
 	"fmt"
+	"maps"
+	"slices"
 	"strconv"
+	"strings"
 	"time"
 )
 
+// PlatformInfo is implemented by the platform-specific SysInfo on every platform, allowing
+// portable code to work with TCP info without build tags.
+type PlatformInfo interface {
+	ToInfo() *Info
+	Warnings() []string
+	ToMap() map[string]any
+}
+
+var _ PlatformInfo = (*SysInfo)(nil)
+
+// GetPlatformInfo is like GetTCPInfo, but returns the platform-specific details as a PlatformInfo.
+// As with GetTCPInfo, partial information may be returned along with an error.
+func GetPlatformInfo(fd uintptr) (PlatformInfo, error) {
+	sysInfo, err := GetTCPInfo(fd)
+	if sysInfo == nil {
+		return nil, err
+	}
+	return sysInfo, err
+}
+
+// Info holds the TCP information common to every platform, converted from SysInfo by ToInfo.
+// Fields a platform does not report are left zero, as noted for each field. In particular, the
+// sender congestion window is reported in exactly one unit per platform: TxWindowSegs on Linux
+// and TxWindowBytes everywhere else. Use CongestionWindow to compare it across platforms.
 type Info struct {
 	State         string        `json:"state,omitempty"`          // Connection state
+	LocalAddr     string        `json:"localAddr,omitempty"`      // Local address, only set by GetTCPInfoFromConn
+	RemoteAddr    string        `json:"remoteAddr,omitempty"`     // Remote address, only set by GetTCPInfoFromConn
 	TxOptions     []Option      `json:"txOptions,omitempty"`      // Requesting options
 	RxOptions     []Option      `json:"rxOptions,omitempty"`      // Options requested from peer
 	TxMSS         uint64        `json:"txMSS,omitempty"`          // Maximum segment size for sender in bytes
@@ -21,12 +51,146 @@ type Info struct {
 	LastTxAckAt   time.Duration `json:"lastTxAckAt,omitempty"`    // Nanoseconds since last ack sent [Linux only]
 	LastRxAckAt   time.Duration `json:"lastRxAckAt,omitempty"`    // Nanoseconds since last ack received [Linux only]
 	RxWindow      uint64        `json:"rxWindow,omitempty"`       // Advertised receiver window in bytes
-	TxSSThreshold uint64        `json:"txSSThreshold,omitempty"`  // Slow start threshold for sender in bytes or # of segments
+	TxSSThreshold uint64        `json:"txSSThreshold,omitempty"`  // Slow start threshold for sender in # of segments on Linux, bytes on Darwin and FreeBSD
 	RxSSThreshold uint64        `json:"rxSSThreshold,omitempty"`  // Slow start threshold for receiver in bytes [Linux only]
-	TxWindowBytes uint64        `json:"txCWindowBytes,omitempty"` // Congestion window for sender in bytes [Darwin and FreeBSD]
-	TxWindowSegs  uint64        `json:"txCWindowSegs,omitempty"`  // Congestion window for sender in # of segments [Linux and NetBSD]
+	TxWindowBytes uint64        `json:"txCWindowBytes,omitempty"` // Congestion window for sender in bytes [Darwin, FreeBSD, and Windows]
+	TxWindowSegs  uint64        `json:"txCWindowSegs,omitempty"`  // Congestion window for sender in # of segments [Linux only]
 	Retransmits   uint64        `json:"retransmits,omitempty"`    // Number of retransmissions (segments or packets)
+	ECN           ECNInfo       `json:"ecn"`                      // Explicit Congestion Notification state
+	FlowLabel     uint32        `json:"flowLabel,omitempty"`      // IPv6 flow label set on the socket, from GetTCPInfoFromConn [Linux only]
+	TrafficClass  uint8         `json:"trafficClass,omitempty"`   // IPv6 traffic class set on the socket, from GetTCPInfoFromConn [Linux only]
 	Sys           *SysInfo      `json:"sysInfo,omitempty"`        // Platform-specific information
+
+	// The delivery rate of the most recently acknowledged data, used by EstimatedBandwidth.
+	DeliveryRate           uint64 `json:"deliveryRate,omitempty"`           // Delivery rate in bytes per second [Linux only]
+	DeliveryRateAppLimited bool   `json:"deliveryRateAppLimited,omitempty"` // The rate was limited by the application rather than the network [Linux only]
+
+	// Absolute times derived from the relative fields above at the moment the information was
+	// sampled. They are only set when the corresponding duration is reported and non-zero.
+	SampledAt     time.Time `json:"sampledAt,omitzero"`     // When the information was retrieved from the kernel
+	LastTxTime    time.Time `json:"lastTxTime,omitzero"`    // Time of last data sent [Linux only]
+	LastRxTime    time.Time `json:"lastRxTime,omitzero"`    // Time of last data received [FreeBSD and Linux]
+	LastTxAckTime time.Time `json:"lastTxAckTime,omitzero"` // Time of last ack sent [Linux only]
+	LastRxAckTime time.Time `json:"lastRxAckTime,omitzero"` // Time of last ack received [Linux only]
+}
+
+// ECNInfo normalizes the Explicit Congestion Notification state reported by each platform.
+type ECNInfo struct {
+	Negotiated       bool   `json:"negotiated"`       // ECN was negotiated during the handshake
+	Seen             bool   `json:"seen"`             // At least one ECT packet was received [Linux only]
+	CEMarkedSegments uint64 `json:"ceMarkedSegments"` // Segments (ACKs on Windows) echoing a CE mark from the peer [Linux and Windows]
+}
+
+// ToMap converts the ECNInfo to a map[string]any.
+func (e ECNInfo) ToMap() map[string]any {
+	return map[string]any{
+		"negotiated":       e.Negotiated,
+		"seen":             e.Seen,
+		"ceMarkedSegments": e.CEMarkedSegments,
+	}
+}
+
+// BufferbloatRTTMultiplier is how many times the minimum RTT the RTT must exceed for SysInfo.Warnings
+// to report bufferbloat on platforms that track the minimum RTT (Linux and Windows).
+var BufferbloatRTTMultiplier = 4.0
+
+// bufferbloatWarning returns a warning such as "bufferbloat rtt=80ms minRtt=12ms" when rtt is
+// more than BufferbloatRTTMultiplier times minRTT.
+func bufferbloatWarning(rtt, minRTT time.Duration) (string, bool) {
+	if minRTT <= 0 || float64(rtt) <= BufferbloatRTTMultiplier*float64(minRTT) {
+		return "", false
+	}
+	return "bufferbloat rtt=" + rtt.String() + " minRtt=" + minRTT.String(), true
+}
+
+// hasOption reports whether opts includes an option of the given kind.
+func hasOption(opts []Option, kind string) bool {
+	for _, o := range opts {
+		if o.Kind == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// setSampledAt records when the information was sampled and derives the absolute times
+// of the last events from their relative durations.
+func (i *Info) setSampledAt(at time.Time) {
+	if at.IsZero() {
+		return
+	}
+	i.SampledAt = at
+	since := func(d time.Duration) time.Time {
+		if d == 0 {
+			return time.Time{}
+		}
+		return at.Add(-d)
+	}
+	i.LastTxTime = since(i.LastTxAt)
+	i.LastRxTime = since(i.LastRxAt)
+	i.LastTxAckTime = since(i.LastTxAckAt)
+	i.LastRxAckTime = since(i.LastRxAckAt)
+}
+
+// CongestionWindow returns the sender congestion window in segments, converting from bytes using
+// the sender MSS on platforms that report it in bytes. It returns false if neither is reported.
+func (i *Info) CongestionWindow() (uint64, bool) {
+	if i.TxWindowSegs > 0 {
+		return i.TxWindowSegs, true
+	}
+	if i.TxWindowBytes > 0 && i.TxMSS > 0 {
+		return i.TxWindowBytes / i.TxMSS, true
+	}
+	return 0, false
+}
+
+// RetransmitRatio returns the fraction of sent bytes that were retransmitted, from the
+// platform counters in Sys (BytesRetrans and BytesSent on Linux, TxRetransmitBytes and
+// TxBytes on Darwin and Windows). It returns 0 when the platform does not report both
+// counters or nothing has been sent.
+func (i *Info) RetransmitRatio() float64 {
+	ratio, _ := i.retransmitRatio()
+	return ratio
+}
+
+// retransmitRatio is like RetransmitRatio, but also reports whether the ratio is available.
+func (i *Info) retransmitRatio() (float64, bool) {
+	if i == nil || i.Sys == nil {
+		return 0, false
+	}
+	retrans, ok := i.Sys.RetransmittedBytes()
+	if !ok {
+		return 0, false
+	}
+	sent, ok := i.Sys.SentBytes()
+	if !ok || sent == 0 {
+		return 0, false
+	}
+	return float64(retrans) / float64(sent), true
+}
+
+// EstimatedBandwidth returns an estimate of the bandwidth available to the connection in bytes per
+// second, and whether the estimate was limited by the application rather than the network. Linux
+// reports the kernel delivery rate; on Windows the average send rate over the life of the connection
+// is used instead, which includes idle time and is never marked application-limited. It returns
+// false when neither is available.
+func (i *Info) EstimatedBandwidth() (bytesPerSec uint64, appLimited bool, ok bool) {
+	if i == nil {
+		return 0, false, false
+	}
+	if i.DeliveryRate > 0 {
+		return i.DeliveryRate, i.DeliveryRateAppLimited, true
+	}
+	if i.Sys == nil {
+		return 0, false, false
+	}
+	rate, ok := i.Sys.AverageSendRate()
+	return rate, false, ok
+}
+
+// IsLossy reports whether RetransmitRatio is above threshold, such as 0.01 for 1%.
+func (i *Info) IsLossy(threshold float64) bool {
+	return i.RetransmitRatio() > threshold
 }
 
 // ToMap converts the Info struct to a map[string]any for easier serialization
@@ -51,6 +215,34 @@ func (i *Info) ToMap() map[string]any {
 		"txCWindowBytes": i.TxWindowBytes,
 		"txCWindowSegs":  i.TxWindowSegs,
 		"retransmits":    i.Retransmits,
+		"ecn":            i.ECN.ToMap(),
+	}
+	for k, t := range map[string]time.Time{
+		"sampledAt":     i.SampledAt,
+		"lastTxTime":    i.LastTxTime,
+		"lastRxTime":    i.LastRxTime,
+		"lastTxAckTime": i.LastTxAckTime,
+		"lastRxAckTime": i.LastRxAckTime,
+	} {
+		if !t.IsZero() {
+			m[k] = t
+		}
+	}
+	if i.LocalAddr != "" {
+		m["localAddr"] = i.LocalAddr
+	}
+	if i.RemoteAddr != "" {
+		m["remoteAddr"] = i.RemoteAddr
+	}
+	if i.FlowLabel != 0 {
+		m["flowLabel"] = i.FlowLabel
+	}
+	if i.TrafficClass != 0 {
+		m["trafficClass"] = i.TrafficClass
+	}
+	if i.DeliveryRate != 0 {
+		m["deliveryRate"] = i.DeliveryRate
+		m["deliveryRateAppLimited"] = i.DeliveryRateAppLimited
 	}
 	if i.Sys != nil {
 		m["sysInfo"] = i.Sys.ToMap()
@@ -58,6 +250,126 @@ func (i *Info) ToMap() map[string]any {
 	return m
 }
 
+// String returns a compact one-line summary of the Info, such as
+// "state=ESTABLISHED rtt=12ms rttvar=3ms cwnd=10 mss=1448 retrans=0". The cwnd is in segments on
+// every platform (see CongestionWindow), followed by cwndBytes where the platform reports bytes.
+func (i *Info) String() string {
+	var b strings.Builder
+	b.WriteString("state=")
+	b.WriteString(i.State)
+	b.WriteString(" rtt=")
+	b.WriteString(i.RTT.String())
+	b.WriteString(" rttvar=")
+	b.WriteString(i.RTTVar.String())
+	cwnd, _ := i.CongestionWindow()
+	b.WriteString(" cwnd=")
+	b.WriteString(strconv.FormatUint(cwnd, 10))
+	if i.TxWindowBytes > 0 {
+		b.WriteString(" cwndBytes=")
+		b.WriteString(strconv.FormatUint(i.TxWindowBytes, 10))
+	}
+	b.WriteString(" mss=")
+	b.WriteString(strconv.FormatUint(i.TxMSS, 10))
+	b.WriteString(" retrans=")
+	b.WriteString(strconv.FormatUint(i.Retransmits, 10))
+	return b.String()
+}
+
+// AppendText implements encoding.TextAppender, appending a compact logfmt representation of the
+// Info to b, such as "state=ESTABLISHED rtt=12.5ms rttvar=3ms rto=204ms cwnd=10 mss=1448 rwnd=65535
+// retrans=0". It uses the keys of String plus rto and rwnd, with the addresses first when set and
+// the delivery rate last when reported. Durations are written in milliseconds. Nothing is allocated
+// when b has enough capacity, so a buffer can be reused for high-volume logging. The error is
+// always nil.
+func (i *Info) AppendText(b []byte) ([]byte, error) {
+	if i.LocalAddr != "" {
+		b = append(b, "local="...)
+		b = append(b, i.LocalAddr...)
+		b = append(b, ' ')
+	}
+	if i.RemoteAddr != "" {
+		b = append(b, "remote="...)
+		b = append(b, i.RemoteAddr...)
+		b = append(b, ' ')
+	}
+	b = append(b, "state="...)
+	b = append(b, i.State...)
+	b = appendMillis(append(b, " rtt="...), i.RTT)
+	b = appendMillis(append(b, " rttvar="...), i.RTTVar)
+	b = appendMillis(append(b, " rto="...), i.RTO)
+	cwnd, _ := i.CongestionWindow()
+	b = strconv.AppendUint(append(b, " cwnd="...), cwnd, 10)
+	if i.TxWindowBytes > 0 {
+		b = strconv.AppendUint(append(b, " cwndBytes="...), i.TxWindowBytes, 10)
+	}
+	b = strconv.AppendUint(append(b, " mss="...), i.TxMSS, 10)
+	b = strconv.AppendUint(append(b, " rwnd="...), i.RxWindow, 10)
+	b = strconv.AppendUint(append(b, " retrans="...), i.Retransmits, 10)
+	if i.DeliveryRate > 0 {
+		b = strconv.AppendUint(append(b, " deliveryRate="...), i.DeliveryRate, 10)
+	}
+	return b, nil
+}
+
+// MarshalText implements encoding.TextMarshaler using AppendText.
+func (i *Info) MarshalText() ([]byte, error) {
+	return i.AppendText(make([]byte, 0, 128))
+}
+
+// appendMillis appends d in milliseconds with the "ms" suffix, in a form time.ParseDuration accepts.
+func appendMillis(b []byte, d time.Duration) []byte {
+	b = strconv.AppendFloat(b, float64(d)/float64(time.Millisecond), 'f', -1, 64)
+	return append(b, "ms"...)
+}
+
+// formatFields renders a SysInfo.ToMap result as space-separated key=value pairs sorted by key.
+// Durations are printed with their units and options as their string form.
+func formatFields(m map[string]any) string {
+	var b strings.Builder
+	for _, k := range slices.Sorted(maps.Keys(m)) {
+		if b.Len() > 0 {
+			b.WriteByte(' ')
+		}
+		b.WriteString(k)
+		b.WriteByte('=')
+		switch v := m[k].(type) {
+		case string:
+			b.WriteString(v)
+		case time.Duration:
+			b.WriteString(v.String())
+		case []Option:
+			for n := range v {
+				if n > 0 {
+					b.WriteByte(',')
+				}
+				b.WriteString(v[n].String())
+			}
+		default:
+			fmt.Fprint(&b, v)
+		}
+	}
+	return b.String()
+}
+
+// MarshalJSON serializes the Info using ToMap, so that the nested platform-specific
+// SysInfo is always encoded through its own ToMap.
+func (i *Info) MarshalJSON() ([]byte, error) {
+	return json.Marshal(i.ToMap())
+}
+
+// UnmarshalJSON is the inverse of MarshalJSON. Missing fields are left at their zero value, and
+// sysInfo is decoded into the SysInfo of the current platform, ignoring fields it does not have.
+func (i *Info) UnmarshalJSON(data []byte) error {
+	// The alias drops the methods of Info to avoid recursing into UnmarshalJSON
+	type info Info
+	var v info
+	if err := json.Unmarshal(data, &v); err != nil {
+		return fmt.Errorf("tcp_info: invalid Info: %w", err)
+	}
+	*i = Info(v)
+	return nil
+}
+
 type Option struct {
 	Kind  string `json:"kind"`
 	Value uint64 `json:"value"`
@@ -73,3 +385,28 @@ func (o *Option) String() string {
 func (o *Option) MarshalJSON() ([]byte, error) {
 	return []byte(strconv.Quote(o.String())), nil
 }
+
+// UnmarshalJSON parses the string form written by MarshalJSON, such as "SACK" or "WindowScale:07".
+// The object form written when an Option value is marshaled directly, such as
+// {"kind":"WindowScale","value":7}, is also accepted.
+func (o *Option) UnmarshalJSON(data []byte) error {
+	if len(data) > 0 && data[0] == '{' {
+		type option Option
+		return json.Unmarshal(data, (*option)(o))
+	}
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	kind, value, found := strings.Cut(s, ":")
+	opt := Option{Kind: kind}
+	if found {
+		v, err := strconv.ParseUint(value, 16, 64)
+		if err != nil {
+			return fmt.Errorf("tcp_info: invalid option %q: %w", s, err)
+		}
+		opt.Value = v
+	}
+	*o = opt
+	return nil
+}
@@ -30,7 +30,7 @@ type RawInfo struct {
 	RecvWnd             uint32 // tcpi_rcv_wnd: receive window in bytes
 	RTTCur              uint32 // tcpi_rttcur: most recent RTT in ms
 	SRTT                uint32 // tcpi_srtt: average RTT in ms
-	RTTVar              uint32 // tcpi_rttvar: RTT variance
+	RTTVar              uint32 // tcpi_rttvar: RTT variance in ms
 	TFOFlags            uint32 // tcpi_tfo_flags: TCP Fast Open flags
 	TxPackets           uint64 // tcpi_txpackets: number of packets sent
 	TxBytes             uint64 // tcpi_txbytes: number of bytes sent
@@ -96,8 +96,8 @@ type RawInfo struct {
 type SysInfo struct {
 	State               uint8         `tcpi:"name=state,prom_type=gauge,prom_help='Connection state, see bsd/netinet/tcp_fsm.h'" json:"-"`
 	StateName           string        `tcpi:"name=state_name,prom_type=gauge,prom_help='Connection state name, see bsd/netinet/tcp_fsm.h'" json:"state,omitempty"`
-	TxWindowScale       uint8         `tcpi:"name=snd_wscale,prom_type=gauge,prom_help='Window scaling of send-half of connection.'" json:"txWScale,omitempty"`
-	RxWindowScale       uint8         `tcpi:"name=rcv_wscale,prom_type=gauge,prom_help='Window scaling of receive-half of connection.'" json:"rxWScale,omitempty"`
+	TxWindowScale       uint8         `tcpi:"name=snd_wscale,prom_type=gauge,prom_help='Window scaling of send-half of connection.'" json:"txWindowScale,omitempty"`
+	RxWindowScale       uint8         `tcpi:"name=rcv_wscale,prom_type=gauge,prom_help='Window scaling of receive-half of connection.'" json:"rxWindowScale,omitempty"`
 	TxOptions           []Option      `tcpi:"name=options,prom_type=gauge,prom_help='TCP options supported.'" json:"txOptions,omitempty"`
 	RxOptions           []Option      `tcpi:"name=peer_options,prom_type=gauge,prom_help='TCP options supported.'" json:"rxOptions,omitempty"`
 	Flags               string        `tcpi:"name=flags,prom_type=gauge,prom_help='TCP flags.'" json:"flags,omitempty"`
@@ -112,13 +112,18 @@ type SysInfo struct {
 	SRTT                time.Duration `tcpi:"name=srtt,prom_type=gauge,prom_help='Average RTT in nanoseconds.'" json:"rttSmoothed,omitempty"`
 	RTTVar              time.Duration `tcpi:"name=rtt_var,prom_type=gauge,prom_help='RTT variance in nanoseconds.'" json:"rttVar,omitempty"`
 	TFOFlags            uint32        `tcpi:"name=tfo_flags,prom_type=gauge,prom_help='TCP Fast Open flags.'" json:"tfoFlags,omitempty"`
-	TxPackets           uint64        `tcpi:"name=tx_packets,prom_type=gauge,prom_help='Number of packets sent.'" json:"txPackets,omitempty"`
-	TxBytes             uint64        `tcpi:"name=tx_bytes,prom_type=gauge,prom_help='Number of bytes sent.'" json:"txBytes,omitempty"`
-	TxRetransmitBytes   uint64        `tcpi:"name=tx_retransmit_bytes,prom_type=gauge,prom_help='Number of retransmitted bytes.'" json:"txRetransmitBytes,omitempty"`
-	RxPackets           uint64        `tcpi:"name=rx_packets,prom_type=gauge,prom_help='Number of packets received.'" json:"rxPackets,omitempty"`
-	RxBytes             uint64        `tcpi:"name=rx_bytes,prom_type=gauge,prom_help='Number of bytes received.'" json:"rxBytes,omitempty"`
-	RxOutOfOrderBytes   uint64        `tcpi:"name=rx_out_of_order_bytes,prom_type=gauge,prom_help='Number of out-of-order bytes received.'" json:"rxOutOfOrderBytes,omitempty"`
-	TxRetransmitPackets uint64        `tcpi:"name=tx_retransmit_packets,prom_type=gauge,prom_help='Number of retransmitted packets.'" json:"txRetransmitPackets,omitempty"`
+	TxPackets           uint64        `tcpi:"name=tx_packets,prom_type=counter,prom_help='Number of packets sent.'" json:"txPackets,omitempty"`
+	TxBytes             uint64        `tcpi:"name=tx_bytes,prom_type=counter,prom_help='Number of bytes sent.'" json:"txBytes,omitempty"`
+	TxRetransmitBytes   uint64        `tcpi:"name=tx_retransmit_bytes,prom_type=counter,prom_help='Number of retransmitted bytes.'" json:"txRetransmitBytes,omitempty"`
+	RxPackets           uint64        `tcpi:"name=rx_packets,prom_type=counter,prom_help='Number of packets received.'" json:"rxPackets,omitempty"`
+	RxBytes             uint64        `tcpi:"name=rx_bytes,prom_type=counter,prom_help='Number of bytes received.'" json:"rxBytes,omitempty"`
+	RxOutOfOrderBytes   uint64        `tcpi:"name=rx_out_of_order_bytes,prom_type=counter,prom_help='Number of out-of-order bytes received.'" json:"rxOutOfOrderBytes,omitempty"`
+	TxRetransmitPackets uint64        `tcpi:"name=tx_retransmit_packets,prom_type=counter,prom_help='Number of retransmitted packets.'" json:"txRetransmitPackets,omitempty"`
+
+	sampledAt time.Time // When GetTCPInfo retrieved this information
+
+	// The connection addresses, set by GetTCPInfoFromConn
+	localAddr, remoteAddr string
 }
 
 func (s *SysInfo) ToMap() map[string]any {
@@ -150,11 +155,21 @@ func (s *SysInfo) ToMap() map[string]any {
 	}
 }
 
+// String returns all fields of the SysInfo as key=value pairs, with durations in their units.
+func (s *SysInfo) String() string {
+	return formatFields(s.ToMap())
+}
+
 func (s *SysInfo) MarshalJSON() ([]byte, error) {
 	return json.Marshal(s.ToMap())
 }
 
 // timeFieldMultiplier is used to convert fields representing time in milliseconds to time.Duration (nanoseconds).
+//
+// xnu keeps t_srtt and t_rttvar in fixed point (scaled by TCP_RTT_SCALE and TCP_RTTVAR_SCALE) in
+// units of TCP_RETRANSHZ (1000 Hz) ticks, but tcp_connection_fill_info in bsd/netinet/tcp_usrreq.c
+// shifts them down by TCP_RTT_SHIFT and TCP_RTTVAR_SHIFT before copying them out. tcpi_rttcur
+// (t_rttcur) and tcpi_rto (t_rxtcur) are never scaled, so every time field arrives in plain ms.
 var timeFieldMultiplier = time.Millisecond
 
 // Unpack converts fields from RawInfo to SysInfo
@@ -215,10 +230,12 @@ func (s *SysInfo) ToInfo() *Info {
 		RxWindow:      uint64(s.RxWindow),
 		TxSSThreshold: uint64(s.TxSSThreshold),
 		TxWindowBytes: uint64(s.TxCWindow),
-		TxWindowSegs:  uint64(s.TxWindow),
 		Retransmits:   s.TxRetransmitPackets,
+		ECN:           ECNInfo{Negotiated: hasOption(s.TxOptions, tcpOptionsMap[TCPCI_OPT_ECN])},
 		Sys:           s,
 	}
+	info.setSampledAt(s.sampledAt)
+	info.LocalAddr, info.RemoteAddr = s.localAddr, s.remoteAddr
 	return info
 }
 
@@ -303,13 +320,6 @@ var tcpOptions = []uint32{
 
 // ================================================================================================================== //
 
-// Errors from syscall package are private, so we define our own to match the errno.
-var (
-	EAGAIN error = syscall.EAGAIN
-	EINVAL error = syscall.EINVAL
-	ENOENT error = syscall.ENOENT
-)
-
 // GetTCPInfo calls getsockopt(2) on Linux to retrieve tcp_info and unpacks that into the golang-friendly TCPInfo.
 func GetTCPInfo(fds uintptr) (*SysInfo, error) {
 	fd := int(fds)
@@ -329,22 +339,57 @@ func GetTCPInfo(fds uintptr) (*SysInfo, error) {
 		0,
 	)
 	if errno != 0 {
-		switch errno {
-		case syscall.EAGAIN:
-			return nil, EAGAIN
-		case syscall.EINVAL:
-			return nil, EINVAL
-		case syscall.ENOENT:
-			return nil, ENOENT
-		}
-		return nil, errno
+		return nil, tcpInfoError(errno)
 	}
 
-	return value.Unpack(), nil
+	sysInfo := value.Unpack()
+	sysInfo.sampledAt = time.Now()
+	return sysInfo, nil
 }
 
+// Supported reports whether TCP info is available on this platform.
 func Supported() bool {
-	return true
+	ok, _ := SupportedReason()
+	return ok
+}
+
+// SupportedReason is like Supported, but also explains why TCP info is unavailable. The reason is empty when it is available.
+func SupportedReason() (bool, string) {
+	return true, ""
+}
+
+// RetransmittedBytes returns the number of bytes retransmitted.
+func (s *SysInfo) RetransmittedBytes() (uint64, bool) {
+	return s.TxRetransmitBytes, true
+}
+
+// SentBytes returns the number of bytes sent, including retransmissions.
+func (s *SysInfo) SentBytes() (uint64, bool) {
+	return s.TxBytes, true
+}
+
+// BaseRTT returns the minimum RTT observed on the connection. macOS does not report it, so this
+// is never available.
+func (s *SysInfo) BaseRTT() (time.Duration, bool) {
+	return 0, false
+}
+
+// LimitedFraction returns the fraction of time sending was limited by the receive window or send
+// buffer. macOS does not report it, so this is never available.
+func (s *SysInfo) LimitedFraction() (float64, bool) {
+	return 0, false
+}
+
+// AverageSendRate returns the bytes sent per second over the life of the connection. macOS does not
+// report how long the connection has been open, so this is never available.
+func (s *SysInfo) AverageSendRate() (uint64, bool) {
+	return 0, false
+}
+
+// SendProgress returns the bytes acknowledged by the peer and the bytes queued but not yet sent.
+// macOS only reports the send buffer including in-flight data, so this is never available.
+func (s *SysInfo) SendProgress() (acked, notSent uint64, ok bool) {
+	return 0, 0, false
 }
 
 func (s *SysInfo) Warnings() []string {
@@ -358,5 +403,25 @@ func (s *SysInfo) Warnings() []string {
 	if s.RxOutOfOrderBytes > 0 {
 		warns = append(warns, "outOfOrderBytes="+strconv.FormatUint(s.RxOutOfOrderBytes, 10))
 	}
+	for _, flag := range strings.Split(s.Flags, ",") {
+		switch flag {
+		case tcpFlagsMap[SysFlagLossRecovery]:
+			warns = append(warns, "lossRecovery")
+		case tcpFlagsMap[SysFlagReorderingDetected]:
+			warns = append(warns, "reorderingDetected")
+		}
+	}
 	return warns
 }
+
+// soLinger is the socket option GetSocketOpts uses for the linger timeout. SO_LINGER on macOS
+// is measured in clock ticks, so SO_LINGER_SEC is used to get seconds.
+const soLinger = unix.SO_LINGER_SEC
+
+// getQuickAck always reports false, since macOS has no TCP_QUICKACK.
+func getQuickAck(fd int) (bool, error) {
+	return false, nil
+}
+
+// tcpInfoOp names the platform and call that retrieves TCP info in errors from GetTCPInfo.
+const tcpInfoOp = "darwin getsockopt(TCP_CONNECTION_INFO)"
@@ -1,3 +1,183 @@
 //go:build darwin
 
 package tcpinfo
+
+import (
+	"encoding/json"
+	"net"
+	"reflect"
+	"testing"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestSysInfoWarnings(t *testing.T) {
+	if warns := (&SysInfo{}).Warnings(); len(warns) != 0 {
+		t.Fatalf("unexpected warnings for empty SysInfo: %v", warns)
+	}
+
+	raw := RawInfo{
+		Flags:               SysFlagLossRecovery | SysFlagReorderingDetected,
+		TxRetransmitBytes:   1448,
+		TxRetransmitPackets: 1,
+		RxOutOfOrderBytes:   512,
+	}
+	got := raw.Unpack().Warnings()
+	want := []string{
+		"retransmitBytes=1448",
+		"retransmitPackets=1",
+		"outOfOrderBytes=512",
+		"lossRecovery",
+		"reorderingDetected",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("Warnings() = %v, want %v", got, want)
+	}
+}
+
+func TestSysInfoToMap(t *testing.T) {
+	raw := RawInfo{State: TCPS_ESTABLISHED, SendWscale: 6, RecvWscale: 7, TxRetransmitPackets: 2}
+	m := raw.Unpack().ToMap()
+	for key, want := range map[string]any{
+		"state":               "ESTABLISHED",
+		"txWindowScale":       uint8(6),
+		"rxWindowScale":       uint8(7),
+		"txRetransmitPackets": uint64(2),
+	} {
+		if m[key] != want {
+			t.Errorf("ToMap()[%q] = %#v, want %#v", key, m[key], want)
+		}
+	}
+}
+
+func TestSetKeepAlive(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("syscall conn: %v", err)
+	}
+	var setErr error
+	var idle, enabled int
+	if err := raw.Control(func(fd uintptr) {
+		if setErr = SetKeepAlive(fd, true, 42*time.Second, 0, 0); setErr != nil {
+			return
+		}
+		idle, _ = unix.GetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_KEEPALIVE)
+		enabled, _ = unix.GetsockoptInt(int(fd), unix.SOL_SOCKET, unix.SO_KEEPALIVE)
+	}); err != nil {
+		t.Fatalf("control: %v", err)
+	}
+	if setErr != nil {
+		t.Fatalf("SetKeepAlive: %v", setErr)
+	}
+	if idle != 42 || enabled == 0 {
+		t.Fatalf("TCP_KEEPALIVE = %d, SO_KEEPALIVE = %d, want 42 and enabled", idle, enabled)
+	}
+}
+
+func TestInfoRetransmitRatio(t *testing.T) {
+	info := (&SysInfo{TxBytes: 2000, TxRetransmitBytes: 500}).ToInfo()
+	if got := info.RetransmitRatio(); got != 0.25 {
+		t.Errorf("RetransmitRatio() = %v, want 0.25", got)
+	}
+	if !info.IsLossy(0.1) || info.IsLossy(0.5) {
+		t.Errorf("IsLossy gave unexpected results for a ratio of 0.25")
+	}
+	if got := (&SysInfo{}).ToInfo().RetransmitRatio(); got != 0 {
+		t.Errorf("RetransmitRatio() with nothing sent = %v, want 0", got)
+	}
+}
+
+func TestInfoECN(t *testing.T) {
+	if got := (&RawInfo{Options: TCPCI_OPT_SACK | TCPCI_OPT_ECN}).Unpack().ToInfo().ECN; !got.Negotiated || got.Seen {
+		t.Errorf("ECN = %+v, want negotiated only", got)
+	}
+	if got := (&RawInfo{Options: TCPCI_OPT_SACK}).Unpack().ToInfo().ECN; got.Negotiated {
+		t.Errorf("ECN without the ECN option = %+v, want not negotiated", got)
+	}
+}
+
+func TestRawInfoUnpackTimes(t *testing.T) {
+	info := (&RawInfo{RTO: 230, RTTCur: 31, SRTT: 25, RTTVar: 6}).Unpack()
+	if info.RTO != 230*time.Millisecond || info.RTTCur != 31*time.Millisecond ||
+		info.SRTT != 25*time.Millisecond || info.RTTVar != 6*time.Millisecond {
+		t.Errorf("rto=%v rttcur=%v srtt=%v rttvar=%v, want 230ms/31ms/25ms/6ms", info.RTO, info.RTTCur, info.SRTT, info.RTTVar)
+	}
+	if got := info.ToInfo(); got.RTT != 25*time.Millisecond || got.RTTVar != 6*time.Millisecond || got.RTO != 230*time.Millisecond {
+		t.Errorf("ToInfo rtt=%v rttVar=%v rto=%v, want 25ms/6ms/230ms", got.RTT, got.RTTVar, got.RTO)
+	}
+}
+
+func TestSysInfoEqualDiff(t *testing.T) {
+	a := &SysInfo{StateName: "ESTABLISHED", SRTT: 10 * time.Millisecond, TxCWindow: 14480, TxOptions: []Option{{Kind: "SACK"}}, sampledAt: time.Now()}
+	b := *a
+	b.sampledAt = a.sampledAt.Add(time.Second)
+	if !a.Equal(&b) || len(a.Diff(&b)) != 0 {
+		t.Errorf("identical SysInfos compared as different: %v", a.Diff(&b))
+	}
+
+	b.SRTT = 20 * time.Millisecond
+	b.TxOptions = []Option{{Kind: "SACK"}, {Kind: "TIMESTAMPS"}}
+	if a.Equal(&b) {
+		t.Errorf("Equal() = true for differing SysInfos")
+	}
+	want := map[string][2]any{
+		"rttSmoothed": {10 * time.Millisecond, 20 * time.Millisecond},
+		"txOptions":   {a.TxOptions, b.TxOptions},
+	}
+	if got := a.Diff(&b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %v, want %v", got, want)
+	}
+}
+
+func TestToInfoWindowFields(t *testing.T) {
+	info := (&SysInfo{MaxSeg: 1448, TxCWindow: 14480, TxWindow: 131072, TxSSThreshold: 28960}).ToInfo()
+	if info.TxWindowBytes != 14480 || info.TxSSThreshold != 28960 {
+		t.Errorf("TxWindowBytes=%d TxSSThreshold=%d, want 14480/28960", info.TxWindowBytes, info.TxSSThreshold)
+	}
+	// macOS reports the congestion window in bytes only, and no receiver slow start threshold
+	if info.TxWindowSegs != 0 || info.RxSSThreshold != 0 {
+		t.Errorf("TxWindowSegs=%d RxSSThreshold=%d, want 0/0", info.TxWindowSegs, info.RxSSThreshold)
+	}
+}
+
+func TestInfoMarshalJSON_Darwin(t *testing.T) {
+	sys := &SysInfo{
+		State:             TCPS_ESTABLISHED,
+		StateName:         "ESTABLISHED",
+		TxWindowScale:     6,
+		RxWindowScale:     6,
+		TxOptions:         []Option{{Kind: "SACK"}, {Kind: "WindowScale", Value: 6}},
+		RxOptions:         []Option{{Kind: "SACK"}, {Kind: "WindowScale", Value: 6}},
+		RTO:               230 * time.Millisecond,
+		MaxSeg:            16344,
+		TxSSThreshold:     1073725440,
+		TxCWindow:         163440,
+		TxWindow:          407744,
+		TxSendBufferBytes: 1024,
+		RxWindow:          408256,
+		RTTCur:            2 * time.Millisecond,
+		SRTT:              3 * time.Millisecond,
+		RTTVar:            time.Millisecond,
+		TxPackets:         12,
+		TxBytes:           4096,
+		RxPackets:         10,
+		RxBytes:           2048,
+	}
+	data, err := json.Marshal(sys.ToInfo())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	checkGolden(t, "info_darwin.golden.json", data)
+}
@@ -0,0 +1,350 @@
+//go:build freebsd
+
+package tcpinfo
+
+import (
+	"encoding/json"
+	"strconv"
+	"syscall"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+// RawInfo mirrors the tcp_info structure from FreeBSD's sys/netinet/tcp.h
+type RawInfo struct {
+	State             uint8      // tcpi_state: TCP FSM state
+	_                 uint8      // __tcpi_ca_state: unused
+	_                 uint8      // __tcpi_retransmits: unused
+	_                 uint8      // __tcpi_probes: unused
+	_                 uint8      // __tcpi_backoff: unused
+	Options           uint8      // tcpi_options: options enabled on the connection
+	Wscale            uint8      // tcpi_snd_wscale:4, tcpi_rcv_wscale:4
+	RTO               uint32     // tcpi_rto: retransmission timeout in usec
+	_                 uint32     // __tcpi_ato: unused
+	SendMSS           uint32     // tcpi_snd_mss: max segment size for send
+	RecvMSS           uint32     // tcpi_rcv_mss: max segment size for receive
+	_                 [5]uint32  // __tcpi_unacked, __tcpi_sacked, __tcpi_lost, __tcpi_retrans, __tcpi_fackets: unused
+	_                 uint32     // __tcpi_last_data_sent: unused
+	_                 uint32     // __tcpi_last_ack_sent: unused
+	LastDataRecv      uint32     // tcpi_last_data_recv: time since last data received in usec
+	_                 uint32     // __tcpi_last_ack_recv: unused
+	_                 uint32     // __tcpi_pmtu: unused
+	_                 uint32     // __tcpi_rcv_ssthresh: unused
+	RTT               uint32     // tcpi_rtt: smoothed RTT in usec
+	RTTVar            uint32     // tcpi_rttvar: RTT variance in usec
+	SendSSThresh      uint32     // tcpi_snd_ssthresh: slow start threshold in bytes
+	SendCwnd          uint32     // tcpi_snd_cwnd: send congestion window in bytes
+	_                 uint32     // __tcpi_advmss: unused
+	_                 uint32     // __tcpi_reordering: unused
+	_                 uint32     // __tcpi_rcv_rtt: unused
+	RecvSpace         uint32     // tcpi_rcv_space: advertised receive window in bytes
+	SendWnd           uint32     // tcpi_snd_wnd: advertised send window in bytes
+	_                 uint32     // tcpi_snd_bwnd: no longer used
+	SendNxt           uint32     // tcpi_snd_nxt: next egress sequence number
+	RecvNxt           uint32     // tcpi_rcv_nxt: next ingress sequence number
+	TOETid            uint32     // tcpi_toe_tid: HWTID for TOE endpoints
+	SendRexmitPack    uint32     // tcpi_snd_rexmitpack: retransmitted packets
+	RecvOutOfOrderPkt uint32     // tcpi_rcv_ooopack: out-of-order packets
+	SendZeroWin       uint32     // tcpi_snd_zerowin: zero-sized windows sent
+	_                 [26]uint32 // __tcpi_pad: padding, partially used by newer releases for accurate ECN counters
+}
+
+// SysInfo is a gopher-style unpacked representation of RawInfo.
+type SysInfo struct {
+	State               uint8         `tcpi:"name=state,prom_type=gauge,prom_help='Connection state, see sys/netinet/tcp_fsm.h'" json:"-"`
+	StateName           string        `tcpi:"name=state_name,prom_type=gauge,prom_help='Connection state name, see sys/netinet/tcp_fsm.h'" json:"state,omitempty"`
+	TxWindowScale       uint8         `tcpi:"name=snd_wscale,prom_type=gauge,prom_help='Window scaling of send-half of connection.'" json:"txWindowScale,omitempty"`
+	RxWindowScale       uint8         `tcpi:"name=rcv_wscale,prom_type=gauge,prom_help='Window scaling of receive-half of connection.'" json:"rxWindowScale,omitempty"`
+	TxOptions           []Option      `tcpi:"name=options,prom_type=gauge,prom_help='TCP options enabled on the connection.'" json:"txOptions,omitempty"`
+	RxOptions           []Option      `tcpi:"name=peer_options,prom_type=gauge,prom_help='TCP options enabled on the connection.'" json:"rxOptions,omitempty"`
+	RTO                 time.Duration `tcpi:"name=rto,prom_type=gauge,prom_help='Retransmission timeout in nanoseconds.'" json:"rto,omitempty"`
+	TxMSS               uint32        `tcpi:"name=snd_mss,prom_type=gauge,prom_help='Max segment size for send in bytes.'" json:"txMSS,omitempty"`
+	RxMSS               uint32        `tcpi:"name=rcv_mss,prom_type=gauge,prom_help='Max segment size for receive in bytes.'" json:"rxMSS,omitempty"`
+	LastRxAt            time.Duration `tcpi:"name=last_data_recv,prom_type=gauge,prom_help='Time since last data received in nanoseconds.'" json:"lastRxAt,omitempty"`
+	RTT                 time.Duration `tcpi:"name=rtt,prom_type=gauge,prom_help='Smoothed RTT in nanoseconds.'" json:"rtt,omitempty"`
+	RTTVar              time.Duration `tcpi:"name=rttvar,prom_type=gauge,prom_help='RTT variance in nanoseconds.'" json:"rttVar,omitempty"`
+	TxSSThreshold       uint32        `tcpi:"name=snd_ssthresh,prom_type=gauge,prom_help='Slow start threshold in bytes.'" json:"txSSThreshold,omitempty"`
+	TxCWindow           uint32        `tcpi:"name=snd_cwnd,prom_type=gauge,prom_help='Send congestion window in bytes.'" json:"txCWindowBytes,omitempty"`
+	RxWindow            uint32        `tcpi:"name=rcv_space,prom_type=gauge,prom_help='Advertised receive window in bytes.'" json:"rxWindow,omitempty"`
+	TxWindow            uint32        `tcpi:"name=snd_wnd,prom_type=gauge,prom_help='Advertised send window in bytes.'" json:"txWindow,omitempty"`
+	TxNext              uint32        `tcpi:"name=snd_nxt,prom_type=gauge,prom_help='Next egress sequence number.'" json:"txNext,omitempty"`
+	RxNext              uint32        `tcpi:"name=rcv_nxt,prom_type=gauge,prom_help='Next ingress sequence number.'" json:"rxNext,omitempty"`
+	TOETid              uint32        `tcpi:"name=toe_tid,prom_type=gauge,prom_help='HWTID for TOE endpoints.'" json:"toeTid,omitempty"`
+	TxRetransmitPackets uint32        `tcpi:"name=snd_rexmitpack,prom_type=counter,prom_help='Number of retransmitted packets.'" json:"txRetransmitPackets,omitempty"`
+	RxOutOfOrderPackets uint32        `tcpi:"name=rcv_ooopack,prom_type=counter,prom_help='Number of out-of-order packets received.'" json:"rxOutOfOrderPackets,omitempty"`
+	TxZeroWindows       uint32        `tcpi:"name=snd_zerowin,prom_type=counter,prom_help='Number of zero-sized windows sent.'" json:"txZeroWindows,omitempty"`
+
+	sampledAt time.Time // When GetTCPInfo retrieved this information
+
+	// The connection addresses, set by GetTCPInfoFromConn
+	localAddr, remoteAddr string
+}
+
+func (s *SysInfo) ToMap() map[string]any {
+	return map[string]any{
+		"state":               s.StateName,
+		"txWindowScale":       s.TxWindowScale,
+		"rxWindowScale":       s.RxWindowScale,
+		"txOptions":           s.TxOptions,
+		"rxOptions":           s.RxOptions,
+		"rto":                 s.RTO,
+		"txMSS":               s.TxMSS,
+		"rxMSS":               s.RxMSS,
+		"lastRxAt":            s.LastRxAt,
+		"rtt":                 s.RTT,
+		"rttVar":              s.RTTVar,
+		"txSSThreshold":       s.TxSSThreshold,
+		"txCWindowBytes":      s.TxCWindow,
+		"rxWindow":            s.RxWindow,
+		"txWindow":            s.TxWindow,
+		"txNext":              s.TxNext,
+		"rxNext":              s.RxNext,
+		"toeTid":              s.TOETid,
+		"txRetransmitPackets": s.TxRetransmitPackets,
+		"rxOutOfOrderPackets": s.RxOutOfOrderPackets,
+		"txZeroWindows":       s.TxZeroWindows,
+	}
+}
+
+// String returns all fields of the SysInfo as key=value pairs, with durations in their units.
+func (s *SysInfo) String() string {
+	return formatFields(s.ToMap())
+}
+
+func (s *SysInfo) MarshalJSON() ([]byte, error) {
+	return json.Marshal(s.ToMap())
+}
+
+// timeFieldMultiplier is used to convert fields representing time in microseconds to time.Duration (nanoseconds).
+var timeFieldMultiplier = time.Microsecond
+
+// Unpack converts fields from RawInfo to SysInfo
+func (packed *RawInfo) Unpack() *SysInfo {
+	var unpacked SysInfo
+	unpacked.State = packed.State
+	unpacked.StateName = tcpStateMap[packed.State]
+	unpacked.TxWindowScale = packed.Wscale & 0x0f
+	unpacked.RxWindowScale = packed.Wscale >> 4
+	unpacked.RTO = time.Duration(packed.RTO) * timeFieldMultiplier
+	unpacked.TxMSS = packed.SendMSS
+	unpacked.RxMSS = packed.RecvMSS
+	unpacked.LastRxAt = time.Duration(packed.LastDataRecv) * timeFieldMultiplier
+	unpacked.RTT = time.Duration(packed.RTT) * timeFieldMultiplier
+	unpacked.RTTVar = time.Duration(packed.RTTVar) * timeFieldMultiplier
+	unpacked.TxSSThreshold = packed.SendSSThresh
+	unpacked.TxCWindow = packed.SendCwnd
+	unpacked.RxWindow = packed.RecvSpace
+	unpacked.TxWindow = packed.SendWnd
+	unpacked.TxNext = packed.SendNxt
+	unpacked.RxNext = packed.RecvNxt
+	unpacked.TOETid = packed.TOETid
+	unpacked.TxRetransmitPackets = packed.SendRexmitPack
+	unpacked.RxOutOfOrderPackets = packed.RecvOutOfOrderPkt
+	unpacked.TxZeroWindows = packed.SendZeroWin
+
+	unpacked.TxOptions = []Option{}
+	for _, flag := range tcpOptions {
+		if packed.Options&flag == 0 {
+			continue
+		}
+		switch flag {
+		case TCPI_OPT_TIMESTAMPS, TCPI_OPT_SACK, TCPI_OPT_ECN, TCPI_OPT_TOE, TCPI_OPT_TFO, TCPI_OPT_ACE:
+			unpacked.TxOptions = append(unpacked.TxOptions, Option{Kind: tcpOptionsMap[flag], Value: 0})
+			unpacked.RxOptions = append(unpacked.RxOptions, Option{Kind: tcpOptionsMap[flag], Value: 0})
+		case TCPI_OPT_WSCALE:
+			unpacked.TxOptions = append(unpacked.TxOptions, Option{Kind: tcpOptionsMap[flag], Value: uint64(unpacked.TxWindowScale)})
+			unpacked.RxOptions = append(unpacked.RxOptions, Option{Kind: tcpOptionsMap[flag], Value: uint64(unpacked.RxWindowScale)})
+		}
+	}
+
+	return &unpacked
+}
+
+func (s *SysInfo) ToInfo() *Info {
+	info := &Info{
+		State:         s.StateName,
+		TxOptions:     s.TxOptions,
+		RxOptions:     s.RxOptions,
+		TxMSS:         uint64(s.TxMSS),
+		RxMSS:         uint64(s.RxMSS),
+		RTT:           s.RTT,
+		RTTVar:        s.RTTVar,
+		RTO:           s.RTO,
+		LastRxAt:      s.LastRxAt,
+		RxWindow:      uint64(s.RxWindow),
+		TxSSThreshold: uint64(s.TxSSThreshold),
+		TxWindowBytes: uint64(s.TxCWindow),
+		Retransmits:   uint64(s.TxRetransmitPackets),
+		ECN: ECNInfo{
+			Negotiated: hasOption(s.TxOptions, tcpOptionsMap[TCPI_OPT_ECN]) || hasOption(s.TxOptions, tcpOptionsMap[TCPI_OPT_ACE]),
+		},
+		Sys: s,
+	}
+	info.setSampledAt(s.sampledAt)
+	info.LocalAddr, info.RemoteAddr = s.localAddr, s.remoteAddr
+	return info
+}
+
+// TCP state constants from FreeBSD sys/netinet/tcp_fsm.h
+const (
+	TCPS_CLOSED       = 0 /* closed */
+	TCPS_LISTEN       = 1 /* listening for connection */
+	TCPS_SYN_SENT     = 2 /* active, have sent syn */
+	TCPS_SYN_RECEIVED = 3 /* have sent and received syn */
+	/* states < TCPS_ESTABLISHED are those where connections not established */
+	TCPS_ESTABLISHED = 4 /* established */
+	TCPS_CLOSE_WAIT  = 5 /* rcvd fin, waiting for close */
+	/* states > TCPS_CLOSE_WAIT are those where user has closed */
+	TCPS_FIN_WAIT_1 = 6 /* have closed, sent fin */
+	TCPS_CLOSING    = 7 /* closed xchd FIN; await FIN ACK */
+	TCPS_LAST_ACK   = 8 /* had fin and close; await FIN ACK */
+	/* states > TCPS_CLOSE_WAIT && < TCPS_FIN_WAIT_2 await ACK of FIN */
+	TCPS_FIN_WAIT_2 = 9  /* have closed, fin is acked */
+	TCPS_TIME_WAIT  = 10 /* in 2*msl quiet wait after close */
+)
+
+var tcpStateMap = map[uint8]string{
+	TCPS_ESTABLISHED:  "ESTABLISHED",
+	TCPS_SYN_SENT:     "SYN_SENT",
+	TCPS_SYN_RECEIVED: "SYN_RECV",
+	TCPS_FIN_WAIT_1:   "FIN_WAIT1",
+	TCPS_FIN_WAIT_2:   "FIN_WAIT2",
+	TCPS_TIME_WAIT:    "TIME_WAIT",
+	TCPS_CLOSED:       "CLOSE",
+	TCPS_CLOSE_WAIT:   "CLOSE_WAIT",
+	TCPS_LAST_ACK:     "LAST_ACK",
+	TCPS_LISTEN:       "LISTEN",
+	TCPS_CLOSING:      "CLOSING",
+}
+
+// TCP option flags from FreeBSD sys/netinet/tcp.h
+const (
+	TCPI_OPT_TIMESTAMPS = 0x01 /* Timestamps enabled */
+	TCPI_OPT_SACK       = 0x02 /* SACK enabled */
+	TCPI_OPT_WSCALE     = 0x04 /* Window scaling enabled */
+	TCPI_OPT_ECN        = 0x08 /* ECN enabled */
+	TCPI_OPT_TOE        = 0x10 /* TCP offload enabled */
+	TCPI_OPT_TFO        = 0x20 /* TCP Fast Open enabled */
+	TCPI_OPT_ACE        = 0x40 /* Accurate ECN enabled */
+)
+
+var tcpOptionsMap = map[uint8]string{
+	TCPI_OPT_TIMESTAMPS: "Timestamps",
+	TCPI_OPT_SACK:       "SACK",
+	TCPI_OPT_WSCALE:     "WindowScale",
+	TCPI_OPT_ECN:        "ECN",
+	TCPI_OPT_TOE:        "TOE",
+	TCPI_OPT_TFO:        "TFO",
+	TCPI_OPT_ACE:        "AccECN",
+}
+
+var tcpOptions = []uint8{
+	TCPI_OPT_TIMESTAMPS,
+	TCPI_OPT_SACK,
+	TCPI_OPT_WSCALE,
+	TCPI_OPT_ECN,
+	TCPI_OPT_TOE,
+	TCPI_OPT_TFO,
+	TCPI_OPT_ACE,
+}
+
+// ================================================================================================================== //
+
+// GetTCPInfo calls getsockopt(2) on FreeBSD to retrieve tcp_info and unpacks that into the golang-friendly SysInfo.
+func GetTCPInfo(fds uintptr) (*SysInfo, error) {
+	fd := int(fds)
+	var value RawInfo
+	length := uint32(unsafe.Sizeof(value))
+	var errno syscall.Errno
+
+	_, _, errno = syscall.Syscall6(
+		syscall.SYS_GETSOCKOPT,
+		uintptr(fd),
+		syscall.IPPROTO_TCP,
+		unix.TCP_INFO,
+		uintptr(unsafe.Pointer(&value)),
+		uintptr(unsafe.Pointer(&length)),
+		0,
+	)
+	if errno != 0 {
+		return nil, tcpInfoError(errno)
+	}
+
+	sysInfo := value.Unpack()
+	sysInfo.sampledAt = time.Now()
+	return sysInfo, nil
+}
+
+// Supported reports whether TCP info is available on this platform.
+func Supported() bool {
+	ok, _ := SupportedReason()
+	return ok
+}
+
+// SupportedReason is like Supported, but also explains why TCP info is unavailable. The reason is empty when it is available.
+func SupportedReason() (bool, string) {
+	return true, ""
+}
+
+// RetransmittedBytes returns the number of bytes retransmitted. FreeBSD only reports
+// retransmitted packets, so this is never available.
+func (s *SysInfo) RetransmittedBytes() (uint64, bool) {
+	return 0, false
+}
+
+// SentBytes returns the number of bytes sent. FreeBSD does not report a byte count, so this
+// is never available.
+func (s *SysInfo) SentBytes() (uint64, bool) {
+	return 0, false
+}
+
+// BaseRTT returns the minimum RTT observed on the connection. FreeBSD does not report it, so this
+// is never available.
+func (s *SysInfo) BaseRTT() (time.Duration, bool) {
+	return 0, false
+}
+
+// LimitedFraction returns the fraction of time sending was limited by the receive window or send
+// buffer. FreeBSD does not report it, so this is never available.
+func (s *SysInfo) LimitedFraction() (float64, bool) {
+	return 0, false
+}
+
+// AverageSendRate returns the bytes sent per second over the life of the connection. FreeBSD does
+// not report how long the connection has been open, so this is never available.
+func (s *SysInfo) AverageSendRate() (uint64, bool) {
+	return 0, false
+}
+
+// SendProgress returns the bytes acknowledged by the peer and the bytes queued but not yet sent.
+// FreeBSD reports neither, so this is never available.
+func (s *SysInfo) SendProgress() (acked, notSent uint64, ok bool) {
+	return 0, 0, false
+}
+
+func (s *SysInfo) Warnings() []string {
+	var warns []string
+	if s.TxRetransmitPackets > 0 {
+		warns = append(warns, "retransmitPackets="+strconv.FormatUint(uint64(s.TxRetransmitPackets), 10))
+	}
+	if s.RxOutOfOrderPackets > 0 {
+		warns = append(warns, "outOfOrderPackets="+strconv.FormatUint(uint64(s.RxOutOfOrderPackets), 10))
+	}
+	if s.TxZeroWindows > 0 {
+		warns = append(warns, "zeroWindows="+strconv.FormatUint(uint64(s.TxZeroWindows), 10))
+	}
+	return warns
+}
+
+// soLinger is the socket option GetSocketOpts uses for the linger timeout in seconds.
+const soLinger = unix.SO_LINGER
+
+// getQuickAck always reports false, since FreeBSD has no TCP_QUICKACK.
+func getQuickAck(fd int) (bool, error) {
+	return false, nil
+}
+
+// tcpInfoOp names the platform and call that retrieves TCP info in errors from GetTCPInfo.
+const tcpInfoOp = "freebsd getsockopt(TCP_INFO)"
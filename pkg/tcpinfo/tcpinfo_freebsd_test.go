@@ -0,0 +1,48 @@
+//go:build freebsd
+
+package tcpinfo
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func TestToInfoWindowFields(t *testing.T) {
+	info := (&SysInfo{TxMSS: 1448, TxCWindow: 14480, TxSSThreshold: 28960}).ToInfo()
+	if info.TxWindowBytes != 14480 || info.TxSSThreshold != 28960 {
+		t.Errorf("TxWindowBytes=%d TxSSThreshold=%d, want 14480/28960", info.TxWindowBytes, info.TxSSThreshold)
+	}
+	// FreeBSD reports the congestion window in bytes only, and no receiver slow start threshold
+	if info.TxWindowSegs != 0 || info.RxSSThreshold != 0 {
+		t.Errorf("TxWindowSegs=%d RxSSThreshold=%d, want 0/0", info.TxWindowSegs, info.RxSSThreshold)
+	}
+}
+
+func TestInfoMarshalJSON_FreeBSD(t *testing.T) {
+	sys := &SysInfo{
+		State:         TCPS_ESTABLISHED,
+		StateName:     "ESTABLISHED",
+		TxWindowScale: 6,
+		RxWindowScale: 6,
+		TxOptions:     []Option{{Kind: "SACK"}, {Kind: "WindowScale", Value: 6}},
+		RxOptions:     []Option{{Kind: "SACK"}, {Kind: "WindowScale", Value: 6}},
+		RTO:           201 * time.Millisecond,
+		TxMSS:         16344,
+		RxMSS:         16344,
+		LastRxAt:      12 * time.Millisecond,
+		RTT:           1500 * time.Microsecond,
+		RTTVar:        750 * time.Microsecond,
+		TxSSThreshold: 1073725440,
+		TxCWindow:     163440,
+		RxWindow:      65536,
+		TxWindow:      65536,
+		TxNext:        1001,
+		RxNext:        2001,
+	}
+	data, err := json.Marshal(sys.ToInfo())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	checkGolden(t, "info_freebsd.golden.json", data)
+}
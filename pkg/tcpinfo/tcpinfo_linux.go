@@ -5,10 +5,16 @@ package tcpinfo
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"reflect"
 	"strconv"
 	"syscall"
 	"time"
+	"unsafe"
 
+	"github.com/runZeroInc/conniver/pkg/kernel"
 	"golang.org/x/sys/unix"
 )
 
@@ -79,41 +85,12 @@ type RawTCPInfo struct { // struct tcp_info {
 	total_rto_time       uint32 // 248 __u32 tcpi_total_rto_time       /* Total time spent in RTO recoveries in milliseconds, including any unfinished recovery. */  // added via commit 3868ab0f192581eff978501a05f3dc2e01541d77 (v6.7-rc1~122^2~330^2)
 } //};
 
-type NullableBool struct {
-	Valid bool
-	Value bool
-}
-
-type NullableUint8 struct {
-	Valid bool
-	Value uint8
-}
-
-type NullableUint16 struct {
-	Valid bool
-	Value uint16
-}
-
-type NullableUint32 struct {
-	Valid bool
-	Value uint32
-}
-
-type NullableUint64 struct {
-	Valid bool
-	Value uint64
-}
-
-type NullableDuration struct {
-	Valid bool
-	Value time.Duration
-}
-
 // SysInfo is a gopher-style unpacked representation of RawTCPInfo.
 type SysInfo struct {
 	State                  uint8            `tcpi:"name=state,prom_type=gauge,prom_help='Connection state, see include/net/tcp_states.h.'" json:"-"`
 	StateName              string           `tcpi:"name=state_name,prom_type=gauge,prom_help='Connection state name, see include/net/tcp_states.h.'" json:"state"`
 	CAState                uint8            `tcpi:"name=ca_state,prom_type=gauge,prom_help='Loss recovery state machine, see include/net/tcp.h.'" json:"caState,omitempty"`
+	CAStateName            string           `tcpi:"name=ca_state_name,prom_type=gauge,prom_help='Loss recovery state name, see include/net/tcp.h.'" json:"caStateName"`
 	Retransmits            uint8            `tcpi:"name=retransmits,prom_type=gauge,prom_help='Number of timeouts (RTO based retransmissions) at this sequence (reset to zero on forward progress).'" json:"retransmits,omitempty"`
 	Probes                 uint8            `tcpi:"name=probes,prom_type=gauge,prom_help='Consecutive zero window probes that have gone unanswered.'" json:"probes,omitempty"`
 	Backoff                uint8            `tcpi:"name=backoff,prom_type=gauge,prom_help='Exponential timeout backoff counter. Increment on RTO, reset on successful RTT measurements.'" json:"backoff,omitempty"`
@@ -123,19 +100,19 @@ type SysInfo struct {
 	RxWindowScale          uint8            `tcpi:"name=rcv_wscale,prom_type=gauge,prom_help='Window scaling of receive-half of connection (bit shift).'" json:"rxWindowScale,omitempty"`
 	DeliveryRateAppLimited NullableBool     `tcpi:"name=delivery_rate_app_limited,prom_type=gauge,prom_help='Flag indicating that rate measurements reflect non-network bottlenecks (1.0 = true, 0.0 = false).'" json:"deliveryRateAppLimited,omitempty"`
 	FastOpenClientFail     NullableUint8    `tcpi:"name=fastopen_client_fail,prom_type=gauge,prom_help='The reason why TCP fastopen failed. 0x0: unspecified; 0x1: no cookie sent; 0x2: SYN-ACK did not ack SYN data; 0x3: SYN-ACK did not ack SYN data after timeout (-1.0 if unavailable).'" json:"fastOpenClientFail,omitempty"`
-	RTO                    time.Duration    `tcpi:"name=rto,prom_type=gauge,prom_help='Retransmission Timeout. Quantized to system jiffies.'" json:"rto,omitempty"`
-	ATO                    time.Duration    `tcpi:"name=ato,prom_type=gauge,prom_help='Delayed ACK Timeout. Quantized to system jiffies.'" json:"ato,omitempty"`
+	RTO                    time.Duration    `tcpi:"name=rto,prom_type=gauge,prom_help='Retransmission Timeout in nanoseconds. Quantized to system jiffies.'" json:"rto,omitempty"`
+	ATO                    time.Duration    `tcpi:"name=ato,prom_type=gauge,prom_help='Delayed ACK Timeout in nanoseconds. Quantized to system jiffies.'" json:"ato,omitempty"`
 	TxMSS                  uint32           `tcpi:"name=snd_mss,prom_type=gauge,prom_help='Current Maximum Segment Size. Note that this can be smaller than the negotiated MSS for various reasons.'" json:"txMSS,omitempty"`
 	RxMSS                  uint32           `tcpi:"name=rcv_mss,prom_type=gauge,prom_help='Maximum observed segment size from the remote host. Used to trigger delayed ACKs.'" json:"rxMSS,omitempty"`
-	UnAcked                uint32           `tcpi:"name=unacked,prom_type=gauge,prom_help='Number of segments between snd.nxt and snd.una. Accounting for the Pipe algorithm.'" json:"unacked,omitempty"`
+	UnAcked                uint32           `tcpi:"name=unacked,key=unAcked,prom_type=gauge,prom_help='Number of segments between snd.nxt and snd.una. Accounting for the Pipe algorithm.'" json:"unacked,omitempty"`
 	Sacked                 uint32           `tcpi:"name=sacked,prom_type=gauge,prom_help='Scoreboard segment marked SACKED by sack blocks. Accounting for the Pipe algorithm.'" json:"sacked,omitempty"`
 	Lost                   uint32           `tcpi:"name=lost,prom_type=gauge,prom_help='Scoreboard segments marked lost by loss detection heuristics. Accounting for the Pipe algorithm.'" json:"lost,omitempty"`
 	Retrans                uint32           `tcpi:"name=retrans,prom_type=gauge,prom_help='Scoreboard segments marked retransmitted. Accounting for the Pipe algorithm.'" json:"retrans,omitempty"`
-	Fackets                uint32           `tcpi:"name=fackets,prom_type=counter,prom_help='Some counter in Forward Acknowledgment (FACK) TCP congestion control. M-Lab says this is unused?.'" json:"fackets,omitempty"`
-	LastTxAt               time.Duration    `tcpi:"name=last_data_sent,prom_type=gauge,prom_help='Time since last data segment was sent. Quantized to jiffies.'" json:"lastTxAt,omitempty"`
+	Fackets                uint32           `tcpi:"name=fackets,prom_type=gauge,prom_help='Some counter in Forward Acknowledgment (FACK) TCP congestion control. M-Lab says this is unused?.'" json:"fackets,omitempty"`
+	LastTxAt               time.Duration    `tcpi:"name=last_data_sent,prom_type=gauge,prom_help='Time since last data segment was sent in nanoseconds. Millisecond resolution.'" json:"lastTxAt,omitempty"`
 	LastTxAckAt            time.Duration    `tcpi:"name=last_ack_sent,prom_type=gauge,prom_help='Time since last ACK was sent. Not implemented!.'" json:"lastTxAckAt,omitempty"`
-	LastRxAt               time.Duration    `tcpi:"name=last_data_recv,prom_type=gauge,prom_help='Time since last data segment was received. Quantized to jiffies.'" json:"lastRxAt,omitempty"`
-	LastRxAckAt            time.Duration    `tcpi:"name=last_ack_recv,prom_type=gauge,prom_help='Time since last ACK was received. Quantized to jiffies.'" json:"lastRxAckAt,omitempty"`
+	LastRxAt               time.Duration    `tcpi:"name=last_data_recv,prom_type=gauge,prom_help='Time since last data segment was received in nanoseconds. Millisecond resolution.'" json:"lastRxAt,omitempty"`
+	LastRxAckAt            time.Duration    `tcpi:"name=last_ack_recv,prom_type=gauge,prom_help='Time since last ACK was received in nanoseconds. Millisecond resolution.'" json:"lastRxAckAt,omitempty"`
 	PMTU                   uint32           `tcpi:"name=pmtu,prom_type=gauge,prom_help='Maximum IP Transmission Unit for this path.'" json:"pmtu,omitempty"`
 	RxSSThreshold          uint32           `tcpi:"name=rcv_ssthresh,prom_type=gauge,prom_help='Current Window Clamp. Receiver algorithm to avoid allocating excessive receive buffers.'" json:"rxSSThreshold,omitempty"`
 	RTT                    time.Duration    `tcpi:"name=rtt,prom_type=gauge,prom_help='Smoothed Round Trip Time (RTT). The Linux implementation differs from the standard.'" json:"rtt,omitempty"`
@@ -146,34 +123,34 @@ type SysInfo struct {
 	Reordering             uint32           `tcpi:"name=reordering,prom_type=gauge,prom_help='Maximum observed reordering distance.'" json:"reordering,omitempty"`
 	RxRTT                  time.Duration    `tcpi:"name=rcv_rtt,prom_type=gauge,prom_help='Receiver Side RTT estimate.'" json:"rxRTT,omitempty"`
 	RxSpace                uint32           `tcpi:"name=rcv_space,prom_type=gauge,prom_help='Space reserved for the receive queue. Typically updated by receiver side auto-tuning.'" json:"rxSpace,omitempty"`
-	TotalRetrans           uint32           `tcpi:"name=total_retrans,prom_type=gauge,prom_help='Total number of segments containing retransmitted data.'" json:"totalRetrans,omitempty"`
+	TotalRetrans           uint32           `tcpi:"name=total_retrans,prom_type=counter,prom_help='Total number of segments containing retransmitted data.'" json:"totalRetrans,omitempty"`
 	PacingRate             NullableUint64   `tcpi:"name=pacing_rate,prom_type=gauge,prom_help='Current Pacing Rate, nominally updated by congestion control.'" json:"pacingRate,omitempty"`
 	MaxPacingRate          NullableUint64   `tcpi:"name=max_pacing_rate,prom_type=gauge,prom_help='Settable pacing rate clamp. Set with setsockopt( ..SO_MAX_PACING_RATE.. ).'" json:"maxPacingRate,omitempty"`
-	BytesAcked             NullableUint64   `tcpi:"name=bytes_acked,prom_type=gauge,prom_help='The number of data bytes for which cumulative acknowledgments have been received | RFC4898 tcpEStatsAppHCThruOctetsAcked.'" json:"bytesAcked,omitempty"`
+	BytesAcked             NullableUint64   `tcpi:"name=bytes_acked,prom_type=counter,prom_help='The number of data bytes for which cumulative acknowledgments have been received | RFC4898 tcpEStatsAppHCThruOctetsAcked.'" json:"bytesAcked,omitempty"`
 	BytesReceived          NullableUint64   `tcpi:"name=bytes_received,prom_type=counter,prom_help='The number of data bytes for which cumulative acknowledgments have been sent | RFC4898 tcpEStatsAppHCThruOctetsReceived.'" json:"bytesReceived,omitempty"`
-	SegsOut                NullableUint32   `tcpi:"name=segs_out,prom_type=gauge,prom_help='The number of segments transmitted. Includes data and pure ACKs | RFC4898 tcpEStatsPerfSegsOut.'" json:"segsOut,omitempty"`
-	SegsIn                 NullableUint32   `tcpi:"name=segs_in,prom_type=gauge,prom_help='The number of segments received. Includes data and pure ACKs | RFC4898 tcpEStatsPerfSegsIn.'" json:"segsIn,omitempty"`
+	SegsOut                NullableUint32   `tcpi:"name=segs_out,prom_type=counter,prom_help='The number of segments transmitted. Includes data and pure ACKs | RFC4898 tcpEStatsPerfSegsOut.'" json:"segsOut,omitempty"`
+	SegsIn                 NullableUint32   `tcpi:"name=segs_in,prom_type=counter,prom_help='The number of segments received. Includes data and pure ACKs | RFC4898 tcpEStatsPerfSegsIn.'" json:"segsIn,omitempty"`
 	NotSentBytes           NullableUint32   `tcpi:"name=notsent_bytes,prom_type=gauge,prom_help='Number of bytes queued in the send buffer that have not been sent.'" json:"notSentBytes,omitempty"`
 	MinRTT                 NullableDuration `tcpi:"name=min_rtt,prom_type=gauge,prom_help='Minimum RTT. From an older, pre-BBR algorithm.'" json:"minRTT,omitempty"`
-	DataSegsIn             NullableUint32   `tcpi:"name=data_segs_in,prom_type=gauge,prom_help='Input segments carrying data (len>0) | RFC4898 tcpEStatsDataSegsIn (actually tcpEStatsPerfDataSegsIn).'" json:"dataSegsIn,omitempty"`
-	DataSegsOut            NullableUint32   `tcpi:"name=data_segs_out,prom_type=gauge,prom_help='Transmitted segments carrying data (len>0) | RFC4898 tcpEStatsDataSegsOut (actually tcpEStatsPerfDataSegsOut).'" json:"dataSegsOut,omitempty"`
+	DataSegsIn             NullableUint32   `tcpi:"name=data_segs_in,prom_type=counter,prom_help='Input segments carrying data (len>0) | RFC4898 tcpEStatsDataSegsIn (actually tcpEStatsPerfDataSegsIn).'" json:"dataSegsIn,omitempty"`
+	DataSegsOut            NullableUint32   `tcpi:"name=data_segs_out,prom_type=counter,prom_help='Transmitted segments carrying data (len>0) | RFC4898 tcpEStatsDataSegsOut (actually tcpEStatsPerfDataSegsOut).'" json:"dataSegsOut,omitempty"`
 	DeliveryRate           NullableUint64   `tcpi:"name=delivery_rate,prom_type=gauge,prom_help='Observed Maximum Delivery Rate.'" json:"deliveryRate,omitempty"`
-	BusyTime               NullableUint64   `tcpi:"name=busy_time,prom_type=gauge,prom_help='Time in usecs with outstanding (unacknowledged) data. Time when snd.una not equal to snd.next.'" json:"busyTime,omitempty"`
-	RxWindowLimited        NullableUint64   `tcpi:"name=rwnd_limited,prom_type=gauge,prom_help='Time in usecs spent limited by/waiting for receiver window.'" json:"rwndLimited,omitempty"`
-	TxBufferLimited        NullableUint64   `tcpi:"name=sndbuf_limited,prom_type=gauge,prom_help='Time in usecs spent limited by/waiting for sender buffer space. This only includes the time when TCP transmissions are starved for data, but the application has been stopped because the buffer is full and can not be grown for some reason.'" json:"sndbufLimited,omitempty"`
-	Delivered              NullableUint32   `tcpi:"name=delivered,prom_type=gauge,prom_help='Data segments delivered to the receiver including retransmits. As reported by returning ACKs, used by ECN.'" json:"delivered,omitempty"`
-	DeliveredCE            NullableUint32   `tcpi:"name=delivered_ce,prom_type=gauge,prom_help='ECE marked data segments delivered to the receiver including retransmits. As reported by returning ACKs, used by ECN.'" json:"deliveredCE,omitempty"`
-	BytesSent              NullableUint64   `tcpi:"name=bytes_sent,prom_type=gauge,prom_help='Payload bytes sent (excludes headers, includes retransmissions) | RFC4898 tcpEStatsPerfHCDataOctetsOut.'" json:"bytesSent,omitempty"`
-	BytesRetrans           NullableUint64   `tcpi:"name=bytes_retrans,prom_type=gauge,prom_help='Bytes retransmitted. May include headers and new data carried with a retransmission (for thin flows) | RFC4898 tcpEStatsPerfOctetsRetrans.'" json:"bytesRetrans,omitempty"`
-	DSACKDups              NullableUint32   `tcpi:"name=dsack_dups,prom_type=gauge,prom_help='Duplicate segments reported by DSACK | RFC4898 tcpEStatsStackDSACKDups.'" json:"dsackDups,omitempty"`
+	BusyTime               NullableUint64   `tcpi:"name=busy_time,prom_type=counter,prom_help='Time in usecs with outstanding (unacknowledged) data. Time when snd.una not equal to snd.next.'" json:"busyTime,omitempty"`
+	RxWindowLimited        NullableUint64   `tcpi:"name=rwnd_limited,key=rxWindowLimited,prom_type=counter,prom_help='Time in usecs spent limited by/waiting for receiver window.'" json:"rwndLimited,omitempty"`
+	TxBufferLimited        NullableUint64   `tcpi:"name=sndbuf_limited,key=txBufferLimited,prom_type=counter,prom_help='Time in usecs spent limited by/waiting for sender buffer space. This only includes the time when TCP transmissions are starved for data, but the application has been stopped because the buffer is full and can not be grown for some reason.'" json:"sndbufLimited,omitempty"`
+	Delivered              NullableUint32   `tcpi:"name=delivered,prom_type=counter,prom_help='Data segments delivered to the receiver including retransmits. As reported by returning ACKs, used by ECN.'" json:"delivered,omitempty"`
+	DeliveredCE            NullableUint32   `tcpi:"name=delivered_ce,prom_type=counter,prom_help='ECE marked data segments delivered to the receiver including retransmits. As reported by returning ACKs, used by ECN.'" json:"deliveredCE,omitempty"`
+	BytesSent              NullableUint64   `tcpi:"name=bytes_sent,prom_type=counter,prom_help='Payload bytes sent (excludes headers, includes retransmissions) | RFC4898 tcpEStatsPerfHCDataOctetsOut.'" json:"bytesSent,omitempty"`
+	BytesRetrans           NullableUint64   `tcpi:"name=bytes_retrans,prom_type=counter,prom_help='Bytes retransmitted. May include headers and new data carried with a retransmission (for thin flows) | RFC4898 tcpEStatsPerfOctetsRetrans.'" json:"bytesRetrans,omitempty"`
+	DSACKDups              NullableUint32   `tcpi:"name=dsack_dups,prom_type=counter,prom_help='Duplicate segments reported by DSACK | RFC4898 tcpEStatsStackDSACKDups.'" json:"dsackDups,omitempty"`
 	ReordSeen              NullableUint32   `tcpi:"name=reord_seen,prom_type=counter,prom_help='Received ACKs that were out of order. Estimates reordering on the return path.'" json:"reordSeen,omitempty"`
 	RxOutOfOrder           NullableUint32   `tcpi:"name=rcv_ooopack,prom_type=counter,prom_help='Out-of-order packets received.'" json:"rxOutOfOrder,omitempty"`
 	TxWindow               NullableUint32   `tcpi:"name=snd_wnd,prom_type=gauge,prom_help='Peers advertised receive window after scaling (bytes).'" json:"txWindow,omitempty"`
 	RxWindow               NullableUint32   `tcpi:"name=rcv_wnd,prom_type=gauge,prom_help='local advertised receive window after scaling (bytes).'" json:"rxWindow,omitempty"`
-	Rehash                 NullableUint32   `tcpi:"name=rehash,prom_type=gauge,prom_help='PLB or timeout triggered rehash attempts.'" json:"rehash,omitempty"`
+	Rehash                 NullableUint32   `tcpi:"name=rehash,prom_type=counter,prom_help='PLB or timeout triggered rehash attempts.'" json:"rehash,omitempty"`
 	TotalRTO               NullableUint16   `tcpi:"name=total_rto,prom_type=counter,prom_help='Total number of RTO timeouts, including SYN/SYN-ACK and recurring timeouts.'" json:"totalRTO,omitempty"`
 	TotalRTORecoveries     NullableUint16   `tcpi:"name=total_rto_recoveries,prom_type=counter,prom_help='Total number of RTO recoveries, including any unfinished recovery.'" json:"totalRTORecoveries,omitempty"`
-	TotalRTOTime           NullableUint32   `tcpi:"name=total_rto_time,prom_type=counter,prom_help='Total time spent in RTO recoveries in nanoseconds, including any unfinished recovery.'" json:"totalRTOTime,omitempty"`
+	TotalRTOTime           NullableUint32   `tcpi:"name=total_rto_time,prom_type=counter,prom_help='Total time spent in RTO recoveries in milliseconds, including any unfinished recovery.'" json:"totalRTOTime,omitempty"`
 	CCAlgorithm            string           `tcpi:"name=cc_algorithm,prom_type=gauge,prom_help='Congestion control algorithm in use for this connection.'" json:"ccAlgorithm,omitempty"`
 	// Vegas
 	CCVegasEnabled NullableUint32   `tcpi:"name=cc_vegas_enabled,prom_type=gauge,prom_help='Whether TCP Vegas is enabled system-wide (true/false).'" json:"ccVegasEnabled,omitempty"`
@@ -192,12 +169,24 @@ type SysInfo struct {
 	CCDCTCPAlpha   NullableUint32 `tcpi:"name=cc_dctcp_alpha,prom_type=gauge,prom_help='DCTCP alpha parameter.'" json:"ccDCTCPAlpha,omitempty"`
 	CCDCTCPABECN   NullableUint32 `tcpi:"name=cc_dctcp_ab_ecn,prom_type=gauge,prom_help='DCTCP AB ECN count.'" json:"ccDCTCPABECN,omitempty"`
 	CCDCTCPABTOT   NullableUint32 `tcpi:"name=cc_dctcp_ab_tot,prom_type=gauge,prom_help='DCTCP AB total count.'" json:"ccDCTCPABTOT,omitempty"`
+	// MPTCP, set for IPPROTO_MPTCP sockets where the fields above describe a single subflow
+	MPTCP *MPTCPInfo `json:"mptcp,omitempty"`
+
+	sampledAt time.Time // When GetTCPInfo retrieved this information
+
+	// The connection addresses, set by GetTCPInfoFromConn
+	localAddr, remoteAddr string
+
+	// The IPv6 flow label and traffic class, set by GetTCPInfo for IPv6 sockets
+	flowLabel    uint32
+	trafficClass uint8
 }
 
 func (s *SysInfo) ToMap() map[string]any {
 	r := map[string]any{
 		"state":         s.StateName,
 		"caState":       s.CAState,
+		"caStateName":   s.CAStateName,
 		"retransmits":   s.Retransmits,
 		"probes":        s.Probes,
 		"backoff":       s.Backoff,
@@ -360,32 +349,113 @@ func (s *SysInfo) ToMap() map[string]any {
 	if s.CCDCTCPABTOT.Valid {
 		r["ccDCTCPABTOT"] = s.CCDCTCPABTOT.Value
 	}
+	if s.MPTCP != nil {
+		r["mptcp"] = s.MPTCP.ToMap()
+	}
 	return r
 }
 
+// String returns all fields of the SysInfo as key=value pairs, with durations in their units.
+func (s *SysInfo) String() string {
+	return formatFields(s.ToMap())
+}
+
 func (s *SysInfo) MarshalJSON() ([]byte, error) {
 	return json.Marshal(s.ToMap())
 }
 
 // timeFieldMultiplier is used to convert fields representing time in microseconds to time.Duration (nanoseconds).
+// The kernel converts rto, ato, rtt, rttvar, rcv_rtt and min_rtt to microseconds before copying them out, whether
+// or not TCPI_OPT_USEC_TS is set; that option only describes the resolution of the TCP timestamp option on the wire.
 var timeFieldMultiplier = time.Microsecond
 
+// lastTimeFieldMultiplier is used to convert the last_* fields, which the kernel reports in milliseconds.
+var lastTimeFieldMultiplier = time.Millisecond
+
+// rawTimeToDuration converts a raw tcp_info time field to a time.Duration. Every time conversion in Unpack goes
+// through here: usec selects timeFieldMultiplier for the fields the kernel reports in microseconds (rto, ato, rtt,
+// rttvar, rcv_rtt, min_rtt and the congestion control RTTs), otherwise lastTimeFieldMultiplier is used for the
+// last_* fields in milliseconds. The unit depends only on the field, never on TCPI_OPT_USEC_TS.
+func rawTimeToDuration(raw uint32, usec bool) time.Duration {
+	if usec {
+		return time.Duration(raw) * timeFieldMultiplier
+	}
+	return time.Duration(raw) * lastTimeFieldMultiplier
+}
+
+// fieldsAvailable reports whether a group of fields was provided by the kernel, given the kernel version flag
+// that introduced the group and the offset just past the last field of the group.
+type fieldsAvailable func(flag bool, end uintptr) bool
+
 // Unpack copies fields from RawTCPInfo to TCPInfo, taking care of the bitfields and marking fields not provided
-// by older kernel versions as null. In the future it may deal with varying lengths of the struct returned by the
-// system call (i.e., kernels older than 5.4.0).
+// by older kernel versions as null. Field availability is inferred from the detected kernel version; use
+// UnpackWithLen when the length returned by getsockopt(2) is known.
 func (packed *RawTCPInfo) Unpack() *SysInfo {
 	var unpacked SysInfo
+	packed.UnpackInto(&unpacked)
+	return &unpacked
+}
+
+// UnpackInto is like Unpack, but writes into dst instead of allocating a new SysInfo. The TxOptions and
+// RxOptions slices of dst are reused when large enough, so the caller must not retain them (or an Info
+// returned by ToInfo) across calls. This avoids all allocations when unpacking many sockets in a loop.
+func (packed *RawTCPInfo) UnpackInto(dst *SysInfo) {
+	packed.unpackInto(dst, func(flag bool, _ uintptr) bool { return flag })
+}
+
+// UnpackWithLen is like Unpack, but marks fields as null based on n, the number of bytes actually written by
+// the kernel, instead of the detected kernel version. Fields beyond n are never read.
+func (packed *RawTCPInfo) UnpackWithLen(n int) *SysInfo {
+	var unpacked SysInfo
+	packed.unpackInto(&unpacked, func(_ bool, end uintptr) bool { return end <= uintptr(n) })
+	return &unpacked
+}
+
+// UnpackBytes decodes a tcp_info struct from b, such as the INET_DIAG_INFO attribute of a sock_diag
+// response, using ParseRawTCPInfo. Fields beyond len(b) are marked as null, as is every field when b is
+// shorter than the original 2.6.2 struct.
+func UnpackBytes(b []byte) *SysInfo {
+	raw, n, err := ParseRawTCPInfo(b)
+	if err != nil {
+		raw, n = &RawTCPInfo{}, 0
+	}
+	return raw.UnpackWithLen(n)
+}
+
+// minRawTCPInfoLen is the size of the original tcp_info struct, ending with tcpi_total_retrans, which every
+// kernel since 2.6.2 provides.
+var minRawTCPInfoLen = int(unsafe.Offsetof(RawTCPInfo{}.total_retrans) + unsafe.Sizeof(RawTCPInfo{}.total_retrans))
+
+// ParseRawTCPInfo decodes a tcp_info struct from b, such as a buffer saved from getsockopt(2) or read
+// from netlink, and returns it along with the number of bytes consumed. Like the kernel, it copies at
+// most the size of RawTCPInfo, ignoring any trailing bytes from a newer kernel, and leaves fields beyond
+// len(b) zeroed; pass the returned length to UnpackWithLen to mark them as null. An error is returned
+// if b is shorter than the original 2.6.2 struct.
+func ParseRawTCPInfo(b []byte) (*RawTCPInfo, int, error) {
+	if len(b) < minRawTCPInfoLen {
+		return nil, 0, fmt.Errorf("tcp_info: buffer of %d bytes is shorter than the minimum of %d", len(b), minRawTCPInfoLen)
+	}
+	var raw RawTCPInfo
+	n := copy(unsafe.Slice((*byte)(unsafe.Pointer(&raw)), unsafe.Sizeof(raw)), b)
+	return &raw, n, nil
+}
+
+func (packed *RawTCPInfo) unpackInto(unpacked *SysInfo, available fieldsAvailable) {
+	txOptions, rxOptions := unpacked.TxOptions, unpacked.RxOptions
+	*unpacked = SysInfo{}
 
 	unpacked.State = packed.state
 	unpacked.StateName = tcpStateMap[packed.state]
 
 	unpacked.CAState = packed.ca_state
+	unpacked.CAStateName = tcpCAStateString(packed.ca_state)
 	unpacked.Retransmits = packed.retransmits
 	unpacked.Probes = packed.probes
 	unpacked.Backoff = packed.backoff
 	unpacked.TxWindowScale = packed.bitfield0 & 0x0f
 	unpacked.RxWindowScale = packed.bitfield0 >> 4
 
+	// bitfield1 is always within the returned length, so only the kernel version can tell whether these are set.
 	unpacked.DeliveryRateAppLimited = NullableBool{Valid: false}
 	if kernelVersionIsAtLeast_4_9 {
 		unpacked.DeliveryRateAppLimited.Valid = true
@@ -398,8 +468,8 @@ func (packed *RawTCPInfo) Unpack() *SysInfo {
 		unpacked.FastOpenClientFail.Value = (packed.bitfield1 >> 1) & 0x3
 	}
 
-	unpacked.RTO = time.Duration(packed.rto) * timeFieldMultiplier
-	unpacked.ATO = time.Duration(packed.ato) * timeFieldMultiplier
+	unpacked.RTO = rawTimeToDuration(packed.rto, true)
+	unpacked.ATO = rawTimeToDuration(packed.ato, true)
 	unpacked.TxMSS = packed.snd_mss
 	unpacked.RxMSS = packed.rcv_mss
 	unpacked.UnAcked = packed.unacked
@@ -407,24 +477,24 @@ func (packed *RawTCPInfo) Unpack() *SysInfo {
 	unpacked.Lost = packed.lost
 	unpacked.Retrans = packed.retrans
 	unpacked.Fackets = packed.fackets
-	unpacked.LastTxAt = time.Duration(packed.last_data_sent) * timeFieldMultiplier
-	unpacked.LastTxAckAt = time.Duration(packed.last_ack_sent) * timeFieldMultiplier
-	unpacked.LastRxAt = time.Duration(packed.last_data_recv) * timeFieldMultiplier
-	unpacked.LastRxAckAt = time.Duration(packed.last_ack_recv) * timeFieldMultiplier
+	unpacked.LastTxAt = rawTimeToDuration(packed.last_data_sent, false)
+	unpacked.LastTxAckAt = rawTimeToDuration(packed.last_ack_sent, false)
+	unpacked.LastRxAt = rawTimeToDuration(packed.last_data_recv, false)
+	unpacked.LastRxAckAt = rawTimeToDuration(packed.last_ack_recv, false)
 	unpacked.PMTU = packed.pmtu
 	unpacked.RxSSThreshold = packed.rcv_ssthresh
-	unpacked.RTT = time.Duration(packed.rtt) * timeFieldMultiplier
-	unpacked.RTTVar = time.Duration(packed.rttvar) * timeFieldMultiplier
+	unpacked.RTT = rawTimeToDuration(packed.rtt, true)
+	unpacked.RTTVar = rawTimeToDuration(packed.rttvar, true)
 	unpacked.TxSSThreshold = packed.snd_ssthresh
 	unpacked.TxCWindow = packed.snd_cwnd
 	unpacked.AdvMSS = packed.advmss
 	unpacked.Reordering = packed.reordering
-	unpacked.RxRTT = time.Duration(packed.rcv_rtt) * timeFieldMultiplier
+	unpacked.RxRTT = rawTimeToDuration(packed.rcv_rtt, true)
 	unpacked.RxSpace = packed.rcv_space
 	unpacked.TotalRetrans = packed.total_retrans
 	unpacked.PacingRate = NullableUint64{Valid: false}
 	unpacked.MaxPacingRate = NullableUint64{Valid: false}
-	if kernelVersionIsAtLeast_3_15 {
+	if available(kernelVersionIsAtLeast_3_15, unsafe.Offsetof(packed.max_pacing_rate)+unsafe.Sizeof(packed.max_pacing_rate)) {
 		unpacked.PacingRate.Valid = true
 		unpacked.PacingRate.Value = packed.pacing_rate
 		unpacked.MaxPacingRate.Valid = true
@@ -433,7 +503,7 @@ func (packed *RawTCPInfo) Unpack() *SysInfo {
 
 	unpacked.BytesAcked = NullableUint64{Valid: false}
 	unpacked.BytesReceived = NullableUint64{Valid: false}
-	if kernelVersionIsAtLeast_4_1 {
+	if available(kernelVersionIsAtLeast_4_1, unsafe.Offsetof(packed.bytes_received)+unsafe.Sizeof(packed.bytes_received)) {
 		unpacked.BytesAcked.Valid = true
 		unpacked.BytesAcked.Value = packed.bytes_acked
 		unpacked.BytesReceived.Valid = true
@@ -442,7 +512,7 @@ func (packed *RawTCPInfo) Unpack() *SysInfo {
 
 	unpacked.SegsOut = NullableUint32{Valid: false}
 	unpacked.SegsIn = NullableUint32{Valid: false}
-	if kernelVersionIsAtLeast_4_2 {
+	if available(kernelVersionIsAtLeast_4_2, unsafe.Offsetof(packed.segs_in)+unsafe.Sizeof(packed.segs_in)) {
 		unpacked.SegsOut.Valid = true
 		unpacked.SegsOut.Value = packed.segs_out
 		unpacked.SegsIn.Valid = true
@@ -453,11 +523,11 @@ func (packed *RawTCPInfo) Unpack() *SysInfo {
 	unpacked.MinRTT = NullableDuration{Valid: false}
 	unpacked.DataSegsIn = NullableUint32{Valid: false}
 	unpacked.DataSegsOut = NullableUint32{Valid: false}
-	if kernelVersionIsAtLeast_4_6 {
+	if available(kernelVersionIsAtLeast_4_6, unsafe.Offsetof(packed.data_segs_out)+unsafe.Sizeof(packed.data_segs_out)) {
 		unpacked.NotSentBytes.Valid = true
 		unpacked.NotSentBytes.Value = packed.notsent_bytes
 		unpacked.MinRTT.Valid = true
-		unpacked.MinRTT.Value = time.Duration(packed.min_rtt) * timeFieldMultiplier
+		unpacked.MinRTT.Value = rawTimeToDuration(packed.min_rtt, true)
 		unpacked.DataSegsIn.Valid = true
 		unpacked.DataSegsIn.Value = packed.data_segs_in
 		unpacked.DataSegsOut.Valid = true
@@ -465,7 +535,7 @@ func (packed *RawTCPInfo) Unpack() *SysInfo {
 	}
 
 	unpacked.DeliveryRate = NullableUint64{Valid: false}
-	if kernelVersionIsAtLeast_4_9 {
+	if available(kernelVersionIsAtLeast_4_9, unsafe.Offsetof(packed.delivery_rate)+unsafe.Sizeof(packed.delivery_rate)) {
 		unpacked.DeliveryRate.Valid = true
 		unpacked.DeliveryRate.Value = packed.delivery_rate
 	}
@@ -473,7 +543,7 @@ func (packed *RawTCPInfo) Unpack() *SysInfo {
 	unpacked.BusyTime = NullableUint64{Valid: false}
 	unpacked.RxWindowLimited = NullableUint64{Valid: false}
 	unpacked.TxBufferLimited = NullableUint64{Valid: false}
-	if kernelVersionIsAtLeast_4_10 {
+	if available(kernelVersionIsAtLeast_4_10, unsafe.Offsetof(packed.sndbuf_limited)+unsafe.Sizeof(packed.sndbuf_limited)) {
 		unpacked.BusyTime.Valid = true
 		unpacked.BusyTime.Value = packed.busy_time
 		unpacked.RxWindowLimited.Valid = true
@@ -484,7 +554,7 @@ func (packed *RawTCPInfo) Unpack() *SysInfo {
 
 	unpacked.Delivered = NullableUint32{Valid: false}
 	unpacked.DeliveredCE = NullableUint32{Valid: false}
-	if kernelVersionIsAtLeast_4_18 {
+	if available(kernelVersionIsAtLeast_4_18, unsafe.Offsetof(packed.delivered_ce)+unsafe.Sizeof(packed.delivered_ce)) {
 		unpacked.Delivered.Valid = true
 		unpacked.Delivered.Value = packed.delivered
 		unpacked.DeliveredCE.Valid = true
@@ -495,7 +565,7 @@ func (packed *RawTCPInfo) Unpack() *SysInfo {
 	unpacked.BytesRetrans = NullableUint64{Valid: false}
 	unpacked.DSACKDups = NullableUint32{Valid: false}
 	unpacked.ReordSeen = NullableUint32{Valid: false}
-	if kernelVersionIsAtLeast_4_19 {
+	if available(kernelVersionIsAtLeast_4_19, unsafe.Offsetof(packed.reord_seen)+unsafe.Sizeof(packed.reord_seen)) {
 		unpacked.BytesSent.Valid = true
 		unpacked.BytesSent.Value = packed.bytes_sent
 		unpacked.BytesRetrans.Valid = true
@@ -508,7 +578,7 @@ func (packed *RawTCPInfo) Unpack() *SysInfo {
 
 	unpacked.RxOutOfOrder = NullableUint32{Valid: false}
 	unpacked.TxWindow = NullableUint32{Valid: false}
-	if kernelVersionIsAtLeast_5_4 {
+	if available(kernelVersionIsAtLeast_5_4, unsafe.Offsetof(packed.snd_wnd)+unsafe.Sizeof(packed.snd_wnd)) {
 		unpacked.RxOutOfOrder.Valid = true
 		unpacked.RxOutOfOrder.Value = packed.rcv_ooopack
 		unpacked.TxWindow.Valid = true
@@ -517,14 +587,17 @@ func (packed *RawTCPInfo) Unpack() *SysInfo {
 
 	unpacked.RxWindow = NullableUint32{Valid: false}
 	unpacked.Rehash = NullableUint32{Valid: false}
-	unpacked.TotalRTO = NullableUint16{Valid: false}
-	unpacked.TotalRTORecoveries = NullableUint16{Valid: false}
-	unpacked.TotalRTOTime = NullableUint32{Valid: false}
-	if kernelVersionIsAtLeast_6_2 {
+	if available(kernelVersionIsAtLeast_6_2, unsafe.Offsetof(packed.rehash)+unsafe.Sizeof(packed.rehash)) {
 		unpacked.RxWindow.Valid = true
 		unpacked.RxWindow.Value = packed.rcv_wnd
 		unpacked.Rehash.Valid = true
 		unpacked.Rehash.Value = packed.rehash
+	}
+
+	unpacked.TotalRTO = NullableUint16{Valid: false}
+	unpacked.TotalRTORecoveries = NullableUint16{Valid: false}
+	unpacked.TotalRTOTime = NullableUint32{Valid: false}
+	if available(kernelVersionIsAtLeast_6_7, unsafe.Offsetof(packed.total_rto_time)+unsafe.Sizeof(packed.total_rto_time)) {
 		unpacked.TotalRTO.Valid = true
 		unpacked.TotalRTO.Value = packed.total_rto
 		unpacked.TotalRTORecoveries.Valid = true
@@ -533,22 +606,39 @@ func (packed *RawTCPInfo) Unpack() *SysInfo {
 		unpacked.TotalRTOTime.Value = packed.total_rto_time
 	}
 
-	unpacked.TxOptions = []Option{}
+	// Size both option slices up front, sharing one allocation when the previous ones are too small.
+	// The received options match the sent ones except for the window scale value.
+	n := bits.OnesCount8(packed.options)
+	if cap(txOptions) < n || cap(rxOptions) < n {
+		buf := make([]Option, 2*n)
+		txOptions, rxOptions = buf[:0:n], buf[n:n:2*n]
+	}
+	if txOptions == nil {
+		txOptions = []Option{}
+	}
+	txOptions = txOptions[:0]
+	wscale := -1
 	for _, flag := range tcpOptions {
 		if packed.options&flag == 0 {
 			continue
 		}
-		switch flag {
-		case TCPI_OPT_TIMESTAMPS, TCPI_OPT_SACK, TCPI_OPT_ECN, TCPI_OPT_ECN_SEEN, TCPI_OPT_SYN_DATA, TCPI_OPT_USEC_TS, TCPI_OPT_TFO_CHILD:
-			unpacked.TxOptions = append(unpacked.TxOptions, Option{Kind: tcpOptionsMap[flag], Value: 0})
-			unpacked.RxOptions = append(unpacked.RxOptions, Option{Kind: tcpOptionsMap[flag], Value: 0})
-		case TCPI_OPT_WSCALE:
-			unpacked.TxOptions = append(unpacked.TxOptions, Option{Kind: tcpOptionsMap[flag], Value: uint64(packed.snd_wnd)})
-			unpacked.RxOptions = append(unpacked.RxOptions, Option{Kind: tcpOptionsMap[flag], Value: uint64(packed.rcv_wnd)})
+		opt := Option{Kind: tcpOptionsMap[flag]}
+		if flag == TCPI_OPT_WSCALE {
+			opt.Value = uint64(unpacked.TxWindowScale)
+			wscale = len(txOptions)
 		}
+		txOptions = append(txOptions, opt)
 	}
-
-	return &unpacked
+	if len(txOptions) > 0 {
+		rxOptions = append(rxOptions[:0], txOptions...)
+		if wscale >= 0 {
+			rxOptions[wscale].Value = uint64(unpacked.RxWindowScale)
+		}
+	} else {
+		rxOptions = nil
+	}
+	unpacked.TxOptions = txOptions
+	unpacked.RxOptions = rxOptions
 }
 
 func (s *SysInfo) ToInfo() *Info {
@@ -571,9 +661,19 @@ func (s *SysInfo) ToInfo() *Info {
 		RxSSThreshold: uint64(s.RxSSThreshold),
 		TxWindowSegs:  uint64(s.TxCWindow),
 		Retransmits:   uint64(s.TotalRetrans),
-		Sys:           s,
+		DeliveryRate:  s.DeliveryRate.Value,
+		ECN: ECNInfo{
+			Negotiated:       hasOption(s.TxOptions, tcpOptionsMap[TCPI_OPT_ECN]),
+			Seen:             hasOption(s.TxOptions, tcpOptionsMap[TCPI_OPT_ECN_SEEN]),
+			CEMarkedSegments: uint64(s.DeliveredCE.Value),
+		},
+		Sys: s,
 	}
 
+	info.setSampledAt(s.sampledAt)
+	info.LocalAddr, info.RemoteAddr = s.localAddr, s.remoteAddr
+	info.FlowLabel, info.TrafficClass = s.flowLabel, s.trafficClass
+	info.DeliveryRateAppLimited = s.DeliveryRateAppLimited.Value
 	return info
 }
 
@@ -607,6 +707,30 @@ var tcpStateMap = map[uint8]string{
 	TCP_CLOSING:     "CLOSING",
 }
 
+// Congestion avoidance (loss recovery) states from linux include/net/tcp.h
+const (
+	TCP_CA_Open = iota
+	TCP_CA_Disorder
+	TCP_CA_CWR
+	TCP_CA_Recovery
+	TCP_CA_Loss
+)
+
+var caStateMap = map[uint8]string{
+	TCP_CA_Open:     "OPEN",
+	TCP_CA_Disorder: "DISORDER",
+	TCP_CA_CWR:      "CWR",
+	TCP_CA_Recovery: "RECOVERY",
+	TCP_CA_Loss:     "LOSS",
+}
+
+func tcpCAStateString(state uint8) string {
+	if s, ok := caStateMap[state]; ok {
+		return s
+	}
+	return fmt.Sprintf("UNKNOWN(%d)", state)
+}
+
 // TCP option flags from linux uapi/linux/tcp.h
 const (
 	TCPI_OPT_TIMESTAMPS = 1   /* Timestamps enabled */
@@ -641,15 +765,43 @@ var tcpOptions = []uint8{
 	TCPI_OPT_TFO_CHILD,
 }
 
-// Errors from syscall package are private, so we define our own to match the errno.
-var (
-	EAGAIN error = syscall.EAGAIN
-	EINVAL error = syscall.EINVAL
-	ENOENT error = syscall.ENOENT
-)
-
 var ErrKernelTooOld = errors.New("tcp_info is not available on Linux prior to kernel 2.6.2")
 
+// FieldRequiresKernelError is returned by FieldError for a field that the running kernel is too old to provide.
+type FieldRequiresKernelError struct {
+	Field string             // tcp_info field name, such as "busy_time"
+	Since kernel.VersionInfo // First kernel version providing the field
+	Have  kernel.VersionInfo // Kernel version in use, see KernelVersion
+}
+
+func (e *FieldRequiresKernelError) Error() string {
+	return fmt.Sprintf("tcp_info field %s requires kernel %d.%d, have %d.%d",
+		e.Field, e.Since.Kernel, e.Since.Major, e.Have.Kernel, e.Have.Major)
+}
+
+// FieldError explains why the named tcp_info field (the tcpi tag name, such as "busy_time") is not set.
+// It returns a *FieldRequiresKernelError if the field is unset because the kernel is too old, an error
+// if no field has that name, and nil otherwise.
+func (s *SysInfo) FieldError(name string) error {
+	v := reflect.ValueOf(s).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		opts, err := ParseTag(t.Field(i).Tag.Get("tcpi"))
+		if err != nil || opts["name"] != name {
+			continue
+		}
+		if f := v.Field(i); f.Kind() == reflect.Struct && f.FieldByName("Valid").Bool() {
+			return nil
+		}
+		since, ok := fieldSinceVersion(name)
+		if !ok || kernel.CompareKernelVersion(*linuxKernelVersion, since) >= 0 {
+			return nil
+		}
+		return &FieldRequiresKernelError{Field: name, Since: since, Have: *linuxKernelVersion}
+	}
+	return fmt.Errorf("unknown tcp_info field %q", name)
+}
+
 // GetTCPCongestionAlgorithm retrieves the TCP congestion control algorithm in use for the given socket.
 // The returned string is one of "vegas", "dctp", "bbr", "cubic", or newer algorithms.
 func GetTCPCongestionAlgorithm(fds uintptr) (string, error) {
@@ -660,29 +812,89 @@ func GetTCPCongestionAlgorithm(fds uintptr) (string, error) {
 	return algo, nil
 }
 
+// SetTCPCongestionAlgorithm sets the TCP congestion control algorithm for the given socket.
+// The returned error wraps syscall.EPERM when the algorithm is not in net.ipv4.tcp_allowed_congestion_control
+// for an unprivileged process, and syscall.ENOENT when the algorithm is not available in the kernel.
+func SetTCPCongestionAlgorithm(fds uintptr, algo string) error {
+	err := unix.SetsockoptString(int(fds), unix.IPPROTO_TCP, unix.TCP_CONGESTION, algo)
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, syscall.EPERM):
+		return fmt.Errorf("congestion control %q is not permitted: %w", algo, err)
+	case errors.Is(err, syscall.ENOENT):
+		return fmt.Errorf("congestion control %q is not available: %w", algo, err)
+	}
+	return fmt.Errorf("could not set congestion control %q: %w", algo, err)
+}
+
+// SetMaxPacingRate sets SO_MAX_PACING_RATE on the given socket, limiting the rate in bytes per second
+// at which the kernel paces outgoing data. The socket option is a 32-bit value where ^uint32(0) means
+// unlimited, so rates of math.MaxUint32 (about 34 Gbit/s) and above are clamped to unlimited.
+func SetMaxPacingRate(fds uintptr, bytesPerSec uint64) error {
+	rate := uint32(math.MaxUint32)
+	if bytesPerSec < math.MaxUint32 {
+		rate = uint32(bytesPerSec)
+	}
+	if err := unix.SetsockoptInt(int(fds), unix.SOL_SOCKET, unix.SO_MAX_PACING_RATE, int(rate)); err != nil {
+		return fmt.Errorf("could not set max pacing rate: %w", err)
+	}
+	return nil
+}
+
+// SetNotSentLowat sets TCP_NOTSENT_LOWAT on the given socket, limiting the bytes that may sit unsent
+// in the send buffer before the socket stops reporting itself as writable. Keeping this low bounds the
+// queueing delay reported by NotSentBytes.
+func SetNotSentLowat(fds uintptr, bytes uint32) error {
+	if err := unix.SetsockoptInt(int(fds), unix.IPPROTO_TCP, unix.TCP_NOTSENT_LOWAT, int(bytes)); err != nil {
+		return fmt.Errorf("could not set notsent lowat: %w", err)
+	}
+	return nil
+}
+
+// GetNotSentLowat returns TCP_NOTSENT_LOWAT for the given socket. The default of ^uint32(0) means
+// no limit beyond the send buffer size, unless net.ipv4.tcp_notsent_lowat says otherwise.
+func GetNotSentLowat(fds uintptr) (uint32, error) {
+	v, err := unix.GetsockoptInt(int(fds), unix.IPPROTO_TCP, unix.TCP_NOTSENT_LOWAT)
+	if err != nil {
+		return 0, fmt.Errorf("could not get notsent lowat: %w", err)
+	}
+	return uint32(v), nil
+}
+
 type TCPInfoPlusCC struct {
 	TCPInfo *RawTCPInfo
+	Length  int       // Bytes of TCPInfo written by the kernel, or zero if unknown
+	At      time.Time // When TCPInfo was retrieved, or zero if unknown
 	CCAlg   string
 	CCVegas *unix.TCPVegasInfo
 	CCBBR   *unix.TCPBBRInfo
 	CCDCTP  *unix.TCPDCTCPInfo
+	MPTCP   *MPTCPInfo
 }
 
 func (t *TCPInfoPlusCC) Unpack() *SysInfo {
-	sysInfo := t.TCPInfo.Unpack()
+	var sysInfo *SysInfo
+	if t.Length > 0 {
+		sysInfo = t.TCPInfo.UnpackWithLen(t.Length)
+	} else {
+		sysInfo = t.TCPInfo.Unpack()
+	}
 	sysInfo.CCAlgorithm = t.CCAlg
+	sysInfo.sampledAt = t.At
+	sysInfo.MPTCP = t.MPTCP
 
 	if t.CCAlg == "vegas" && t.CCVegas != nil {
 		sysInfo.CCVegasEnabled = NullableUint32{Valid: true, Value: t.CCVegas.Enabled}
 		sysInfo.CCVegasRTTCnt = NullableUint32{Valid: true, Value: t.CCVegas.Rttcnt}
-		sysInfo.CCVegasRTTMin = NullableDuration{Valid: true, Value: time.Duration(t.CCVegas.Minrtt) * time.Microsecond}
-		sysInfo.CCVegasRTT = NullableDuration{Valid: true, Value: time.Duration(t.CCVegas.Rtt) * time.Microsecond}
+		sysInfo.CCVegasRTTMin = NullableDuration{Valid: true, Value: rawTimeToDuration(t.CCVegas.Minrtt, true)}
+		sysInfo.CCVegasRTT = NullableDuration{Valid: true, Value: rawTimeToDuration(t.CCVegas.Rtt, true)}
 		return sysInfo
 	}
 	if t.CCAlg == "bbr" && t.CCBBR != nil {
 		sysInfo.CCBBRBwHi = NullableUint32{Valid: true, Value: t.CCBBR.Bw_hi}
 		sysInfo.CCBBRBwLo = NullableUint32{Valid: true, Value: t.CCBBR.Bw_lo}
-		sysInfo.CCBBRMinRTT = NullableDuration{Valid: true, Value: time.Duration(t.CCBBR.Min_rtt) * time.Microsecond}
+		sysInfo.CCBBRMinRTT = NullableDuration{Valid: true, Value: rawTimeToDuration(t.CCBBR.Min_rtt, true)}
 		sysInfo.CCBBRPacingGain = NullableUint32{Valid: true, Value: t.CCBBR.Pacing_gain}
 		sysInfo.CCBBRCWindowGain = NullableUint32{Valid: true, Value: t.CCBBR.Cwnd_gain}
 		return sysInfo
@@ -697,7 +909,42 @@ func (t *TCPInfoPlusCC) Unpack() *SysInfo {
 	return sysInfo
 }
 
+// GetRawTCPInfo retrieves the raw tcp_info struct for the socket. Fields not written by the kernel are left zeroed.
+func GetRawTCPInfo(fd uintptr) (*RawTCPInfo, error) {
+	value, _, err := getRawTCPInfo(fd)
+	return value, err
+}
+
+// congestionInfoError wraps an error from retrieving the congestion control details.
+func congestionInfoError(err error) error {
+	return fmt.Errorf("tcp_info: congestion control: %w", err)
+}
+
+// EAGAINRetries is how many more times GetTCPInfo calls getsockopt(TCP_INFO) when it fails with the
+// transient EAGAIN, waiting a few hundred microseconds between attempts. Zero disables retries.
+var EAGAINRetries = 2
+
+var (
+	// eagainBackoff is the delay before each retry of getsockopt(TCP_INFO) after EAGAIN.
+	eagainBackoff = 250 * time.Microsecond
+
+	// getRawTCPInfoFn is replaced in tests to simulate transient getsockopt failures.
+	getRawTCPInfoFn = getRawTCPInfo
+)
+
+// getRawTCPInfoWithRetries is like getRawTCPInfo, but retries up to EAGAINRetries times on EAGAIN.
+func getRawTCPInfoWithRetries(fds uintptr) (*RawTCPInfo, int, error) {
+	for attempt := 0; ; attempt++ {
+		tcpInfo, length, err := getRawTCPInfoFn(fds)
+		if err == nil || !errors.Is(err, EAGAIN) || attempt >= EAGAINRetries {
+			return tcpInfo, length, err
+		}
+		time.Sleep(eagainBackoff)
+	}
+}
+
 // GetTCPInfo retrieves the TCP_INFO struct along with the congestion control algorithm and algorithm-specific info.
+// A transient EAGAIN from getsockopt(TCP_INFO) is retried as configured by EAGAINRetries.
 func GetTCPInfo(fds uintptr) (*SysInfo, error) {
 	res := &TCPInfoPlusCC{}
 
@@ -706,16 +953,29 @@ func GetTCPInfo(fds uintptr) (*SysInfo, error) {
 		return nil, ErrKernelTooOld
 	}
 
-	tcpInfo, err := GetRawTCPInfo(fds)
+	tcpInfo, length, err := getRawTCPInfoWithRetries(fds)
 	if err != nil {
 		return nil, err
 	}
 	res.TCPInfo = tcpInfo
+	res.Length = length
+	res.At = time.Now()
+
+	// On MPTCP sockets tcp_info only describes one subflow, so gather the rest
+	if kernelVersionIsAtLeast_5_16 {
+		if ok, _ := IsMPTCP(fds); ok {
+			mptcp, err := GetMPTCPInfo(fds)
+			res.MPTCP = mptcp
+			if err != nil {
+				return res.Unpack(), err
+			}
+		}
+	}
 
 	// Now resolve the congestion control algorithm data
 	alg, err := GetTCPCongestionAlgorithm(fds)
 	if err != nil {
-		return res.Unpack(), err
+		return res.Unpack(), congestionInfoError(err)
 	}
 	res.CCAlg = alg
 
@@ -723,19 +983,19 @@ func GetTCPInfo(fds uintptr) (*SysInfo, error) {
 	case "vegas":
 		v, err := unix.GetsockoptTCPCCVegasInfo(fd, unix.IPPROTO_TCP, 0)
 		if err != nil {
-			return res.Unpack(), err
+			return res.Unpack(), congestionInfoError(err)
 		}
 		res.CCVegas = v
 	case "bbr":
 		v, err := unix.GetsockoptTCPCCBBRInfo(fd, unix.IPPROTO_TCP, 0)
 		if err != nil {
-			return res.Unpack(), err
+			return res.Unpack(), congestionInfoError(err)
 		}
 		res.CCBBR = v
 	case "dctcp":
 		v, err := unix.GetsockoptTCPCCDCTCPInfo(fd, unix.IPPROTO_TCP, 0)
 		if err != nil {
-			return res.Unpack(), err
+			return res.Unpack(), congestionInfoError(err)
 		}
 		res.CCDCTP = v
 	}
@@ -743,8 +1003,57 @@ func GetTCPInfo(fds uintptr) (*SysInfo, error) {
 	return res.Unpack(), nil
 }
 
+// Supported reports whether TCP info is available on this platform.
 func Supported() bool {
-	return kernelVersionIsAtLeast_2_6_2
+	ok, _ := SupportedReason()
+	return ok
+}
+
+// SupportedReason is like Supported, but also explains why TCP info is unavailable, such as
+// "linux kernel 2.4.0 < 2.6.2". The reason is empty when it is available.
+func SupportedReason() (bool, string) {
+	if !kernelVersionIsAtLeast_2_6_2 {
+		k, major, minor := KernelVersion()
+		return false, fmt.Sprintf("linux kernel %d.%d.%d < 2.6.2", k, major, minor)
+	}
+	return true, ""
+}
+
+// RetransmittedBytes returns the number of bytes retransmitted, if reported by the kernel (4.19+).
+func (s *SysInfo) RetransmittedBytes() (uint64, bool) {
+	return s.BytesRetrans.Value, s.BytesRetrans.Valid
+}
+
+// SentBytes returns the number of payload bytes sent, including retransmissions, if reported
+// by the kernel (4.19+).
+func (s *SysInfo) SentBytes() (uint64, bool) {
+	return s.BytesSent.Value, s.BytesSent.Valid
+}
+
+// BaseRTT returns the minimum RTT observed on the connection, if reported by the kernel (4.6+).
+func (s *SysInfo) BaseRTT() (time.Duration, bool) {
+	return s.MinRTT.Value, s.MinRTT.Valid && s.MinRTT.Value > 0
+}
+
+// LimitedFraction returns the fraction of the time with data outstanding that sending was limited
+// by the receive window or the send buffer, if reported by the kernel (4.9+).
+func (s *SysInfo) LimitedFraction() (float64, bool) {
+	if !s.BusyTime.Valid || s.BusyTime.Value == 0 || !s.RxWindowLimited.Valid || !s.TxBufferLimited.Valid {
+		return 0, false
+	}
+	return min(float64(s.RxWindowLimited.Value+s.TxBufferLimited.Value)/float64(s.BusyTime.Value), 1), true
+}
+
+// AverageSendRate returns the bytes sent per second over the life of the connection. Linux does not
+// report how long the connection has been open, so this is never available; use DeliveryRate instead.
+func (s *SysInfo) AverageSendRate() (uint64, bool) {
+	return 0, false
+}
+
+// SendProgress returns the bytes acknowledged by the peer and the bytes queued but not yet sent,
+// if reported by the kernel (4.1+ and 4.6+ respectively).
+func (s *SysInfo) SendProgress() (acked, notSent uint64, ok bool) {
+	return s.BytesAcked.Value, uint64(s.NotSentBytes.Value), s.BytesAcked.Valid && s.NotSentBytes.Valid
 }
 
 func (s *SysInfo) Warnings() []string {
@@ -767,5 +1076,23 @@ func (s *SysInfo) Warnings() []string {
 	if s.RxWindowLimited.Valid && s.RxWindowLimited.Value > 0 {
 		warns = append(warns, "rxWindowLimited="+strconv.FormatUint(s.RxWindowLimited.Value, 10))
 	}
+	// An application-limited sender does not fill the queues, so RTT inflation is not bufferbloat
+	if minRTT, ok := s.BaseRTT(); ok && !(s.DeliveryRateAppLimited.Valid && s.DeliveryRateAppLimited.Value) {
+		if warn, ok := bufferbloatWarning(s.RTT, minRTT); ok {
+			warns = append(warns, warn)
+		}
+	}
 	return warns
 }
+
+// soLinger is the socket option GetSocketOpts uses for the linger timeout in seconds.
+const soLinger = unix.SO_LINGER
+
+// getQuickAck reports whether TCP_QUICKACK is set on the socket.
+func getQuickAck(fd int) (bool, error) {
+	v, err := unix.GetsockoptInt(fd, unix.IPPROTO_TCP, unix.TCP_QUICKACK)
+	return v != 0, err
+}
+
+// tcpInfoOp names the platform and call that retrieves TCP info in errors from GetTCPInfo.
+const tcpInfoOp = "linux getsockopt(TCP_INFO)"
@@ -9,16 +9,25 @@ import (
 
 const netGetSockOpt = 15
 
-// GetTCPInfo calls socketcall(2) on Linux to retrieve tcp_info and unpacks that into the golang-friendly TCPInfo.
+// getRawTCPInfo calls socketcall(2) on Linux to retrieve tcp_info and returns it along with the
+// number of bytes the kernel actually wrote.
 // This variant is for the 32-bit x86 (386) architecture.
-func GetRawTCPInfo(fd uintptr) (*RawTCPInfo, error) {
+func getRawTCPInfo(fd uintptr) (*RawTCPInfo, int, error) {
 	var value RawTCPInfo
-	length := uint32(sizeOfRawTCPInfo)
+	length := uint32(unsafe.Sizeof(value))
+	if errNo := getsockopt(fd, syscall.SOL_TCP, syscall.TCP_INFO, unsafe.Pointer(&value), &length); errNo != 0 {
+		return nil, 0, tcpInfoError(errNo)
+	}
+	return &value, int(length), nil
+}
 
+// getsockopt calls getsockopt through socketcall(2) with a caller-provided buffer, updating length
+// to the number of bytes the kernel wrote.
+func getsockopt(fd uintptr, level, opt int, value unsafe.Pointer, length *uint32) syscall.Errno {
 	args := [5]uintptr{
-		uintptr(fd),
-		uintptr(syscall.SOL_TCP), uintptr(syscall.TCP_INFO),
-		uintptr(unsafe.Pointer(&value)), uintptr(unsafe.Pointer(&length)),
+		fd,
+		uintptr(level), uintptr(opt),
+		uintptr(value), uintptr(unsafe.Pointer(length)),
 	}
 
 	_, _, errNo := syscall.RawSyscall(
@@ -27,17 +36,5 @@ func GetRawTCPInfo(fd uintptr) (*RawTCPInfo, error) {
 		uintptr(unsafe.Pointer(&args)),
 		0,
 	)
-	if errNo != 0 {
-		switch errNo {
-		case syscall.EAGAIN:
-			return nil, EAGAIN
-		case syscall.EINVAL:
-			return nil, EINVAL
-		case syscall.ENOENT:
-			return nil, ENOENT
-		}
-		return nil, errNo
-	}
-
-	return &value, nil
+	return errNo
 }
@@ -1,4 +1,4 @@
-//go:build !(linux || darwin || windows)
+//go:build !(linux || darwin || windows || freebsd)
 
 package tcpinfo
 
@@ -6,32 +6,74 @@ import (
 	"encoding/json"
 	"fmt"
 	"runtime"
+	"time"
 )
 
 type SysInfo struct {
-	// Empty for unsupported platforms
+	// Only the connection addresses set by GetTCPInfoFromConn on unsupported platforms
+	localAddr, remoteAddr string
 }
 
 func (s *SysInfo) ToInfo() *Info {
-	return &Info{}
+	return &Info{LocalAddr: s.localAddr, RemoteAddr: s.remoteAddr}
 }
 
 func (s *SysInfo) Warnings() []string {
 	return nil
 }
 
+func (s *SysInfo) RetransmittedBytes() (uint64, bool) {
+	return 0, false
+}
+
+func (s *SysInfo) SentBytes() (uint64, bool) {
+	return 0, false
+}
+
+func (s *SysInfo) BaseRTT() (time.Duration, bool) {
+	return 0, false
+}
+
+func (s *SysInfo) LimitedFraction() (float64, bool) {
+	return 0, false
+}
+
+func (s *SysInfo) AverageSendRate() (uint64, bool) {
+	return 0, false
+}
+
+func (s *SysInfo) SendProgress() (acked, notSent uint64, ok bool) {
+	return 0, 0, false
+}
+
 func (s *SysInfo) ToMap() map[string]any {
 	return map[string]any{}
 }
 
+// String returns all fields of the SysInfo as key=value pairs, with durations in their units.
+func (s *SysInfo) String() string {
+	return formatFields(s.ToMap())
+}
+
 func (s *SysInfo) MarshalJSON() ([]byte, error) {
 	return json.Marshal(s.ToMap())
 }
 
 func GetTCPInfo(fd uintptr) (*SysInfo, error) {
-	return nil, fmt.Errorf("%s is unsupported", runtime.GOOS)
+	return nil, fmt.Errorf("tcp_info: %w: %s", ErrUnsupportedPlatform, runtime.GOOS)
 }
 
+// Supported reports whether TCP info is available on this platform.
 func Supported() bool {
-	return false
+	ok, _ := SupportedReason()
+	return ok
+}
+
+// SupportedReason is like Supported, but also explains why TCP info is unavailable.
+func SupportedReason() (bool, string) {
+	return false, runtime.GOOS + " tcp_info not implemented"
 }
+
+// tcpInfoOp names the platform and call that retrieves TCP info in errors from GetTCPInfo. It is
+// unused here, since GetTCPInfo always returns ErrUnsupportedPlatform.
+const tcpInfoOp = runtime.GOOS + " getsockopt(TCP_INFO)"
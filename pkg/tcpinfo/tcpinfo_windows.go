@@ -4,11 +4,14 @@ package tcpinfo
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strconv"
 	"syscall"
 	"time"
 	"unsafe"
+
+	"golang.org/x/sys/windows"
 )
 
 // SIO_TCP_INFO is available to non-admins, as opposed to GetPerTcpConnectionEStats:
@@ -74,6 +77,44 @@ type RawInfoV1 struct {
 	SndLimBytesSnd  uint64
 }
 
+// RawInfoV2 mirrors the _TCP_INFO_v2 structure from the Windows SDK
+// https://learn.microsoft.com/en-us/windows/win32/api/mstcpip/ns-mstcpip-tcp_info_v2
+type RawInfoV2 struct {
+	State             uint32
+	Mss               uint32
+	ConnectionTimeMs  uint64
+	TimestampsEnabled bool
+	RttUs             uint32
+	MinRttUs          uint32
+	BytesInFlight     uint32
+	Cwnd              uint32
+	SndWnd            uint32
+	RcvWnd            uint32
+	RcvBuf            uint32
+	BytesOut          uint64
+	BytesIn           uint64
+	BytesReordered    uint32
+	BytesRetrans      uint32
+	FastRetrans       uint32
+	DupAcksIn         uint32
+	TimeoutEpisodes   uint32
+	SynRetrans        uint8
+	SndLimTransRwin   uint32
+	SndLimTimeRwin    uint32
+	SndLimBytesRwin   uint64
+	SndLimTransCwnd   uint32
+	SndLimTimeCwnd    uint32
+	SndLimBytesCwnd   uint64
+	SndLimTransSnd    uint32
+	SndLimTimeSnd     uint32
+	SndLimBytesSnd    uint64
+	// New fields in v2
+	OutOfOrderPktsIn uint32
+	EcnNegotiated    bool
+	EceAcksIn        uint32
+	PtoEpisodes      uint32
+}
+
 // SysInfo is a gopher-style unpacked representation of RawTCPInfo.
 type SysInfo struct {
 	State             uint32        `tcpi:"name=state,prom_type=gauge,prom_help='Connection state, see bsd/netinet/tcp_fsm.h'" json:"-"`
@@ -87,17 +128,17 @@ type SysInfo struct {
 	TxWindow          uint32        `tcpi:"name=tx_window,prom_type=gauge,prom_help='Sender advertised window size in bytes.'" json:"txWindow,omitempty"`
 	RxWindow          uint32        `tcpi:"name=rx_window,prom_type=gauge,prom_help='Receiver advertised window size in bytes.'" json:"rxWindow,omitempty"`
 	RxBuffer          uint32        `tcpi:"name=rx_buffer,prom_type=gauge,prom_help='Receiver buffer size in bytes.'" json:"rxBuffer,omitempty"`
-	TxBytes           uint64        `tcpi:"name=tx_bytes,prom_type=gauge,prom_help='Total number of bytes sent.'" json:"txBytes,omitempty"`
-	RxBytes           uint64        `tcpi:"name=rx_bytes,prom_type=gauge,prom_help='Total number of bytes received.'" json:"rxBytes,omitempty"`
-	RxOutOfOrderBytes uint32        `tcpi:"name=rx_out_of_order_bytes,prom_type=gauge,prom_help='Total number of out-of-order bytes received.'" json:"rxOutOfOrderBytes,omitempty"`
-	TxRetransmitBytes uint64        `tcpi:"name=tx_retransmit_bytes,prom_type=gauge,prom_help='Total number of retransmitted bytes.'" json:"txRetransmitBytes,omitempty"`
-	FastRetrans       uint32        `tcpi:"name=fast_retransmissions,prom_type=gauge,prom_help='Number of fast retransmissions.'" json:"fastRetransmissions,omitempty"`
-	DupAcksIn         uint32        `tcpi:"name=duplicate_acks_in,prom_type=gauge,prom_help='Number of duplicate ACKs received.'" json:"duplicateAcksIn,omitempty"`
-	TimeoutEpisodes   uint32        `tcpi:"name=timeout_episodes,prom_type=gauge,prom_help='Number of timeout episodes.'" json:"timeoutEpisodes,omitempty"`
-	SynRetrans        uint8         `tcpi:"name=syn_retransmissions,prom_type=gauge,prom_help='Number of SYN retransmissions.'" json:"synRetransmissions,omitempty"`
+	TxBytes           uint64        `tcpi:"name=tx_bytes,prom_type=counter,prom_help='Total number of bytes sent.'" json:"txBytes,omitempty"`
+	RxBytes           uint64        `tcpi:"name=rx_bytes,prom_type=counter,prom_help='Total number of bytes received.'" json:"rxBytes,omitempty"`
+	RxOutOfOrderBytes uint32        `tcpi:"name=rx_out_of_order_bytes,prom_type=counter,prom_help='Total number of out-of-order bytes received.'" json:"rxOutOfOrderBytes,omitempty"`
+	TxRetransmitBytes uint64        `tcpi:"name=tx_retransmit_bytes,prom_type=counter,prom_help='Total number of retransmitted bytes.'" json:"txRetransmitBytes,omitempty"`
+	FastRetrans       uint32        `tcpi:"name=fast_retransmissions,prom_type=counter,prom_help='Number of fast retransmissions.'" json:"fastRetransmissions,omitempty"`
+	DupAcksIn         uint32        `tcpi:"name=duplicate_acks_in,prom_type=counter,prom_help='Number of duplicate ACKs received.'" json:"duplicateAcksIn,omitempty"`
+	TimeoutEpisodes   uint32        `tcpi:"name=timeout_episodes,prom_type=counter,prom_help='Number of timeout episodes.'" json:"timeoutEpisodes,omitempty"`
+	SynRetrans        uint8         `tcpi:"name=syn_retransmissions,prom_type=counter,prom_help='Number of SYN retransmissions.'" json:"synRetransmissions,omitempty"`
 	// Start of v1 fields
 	SndLimTransRwin     uint64        `tcpi:"name=snd_lim_trans_rwin,prom_type=gauge,prom_help='Number of segments limited by receiver window.'" json:"sndLimTransRwin,omitempty"`
-	SndLimTransTimeRwin time.Duration `tcpi:"name=snd_lim_trans_time_rwin,prom_type=gauge,prom_help='Number of bytes limited by receiver window.'" json:"sndLimTransTimeRwin,omitempty"`
+	SndLimTransTimeRwin time.Duration `tcpi:"name=snd_lim_trans_time_rwin,key=sndLimTimeRwin,prom_type=gauge,prom_help='Number of bytes limited by receiver window.'" json:"sndLimTransTimeRwin,omitempty"`
 	SndLimBytesRwin     uint64        `tcpi:"name=snd_lim_bytes_rwin,prom_type=gauge,prom_help='Number of bytes limited by sender.'" json:"sndLimBytesRwin,omitempty"`
 	SndLimTransCwnd     uint64        `tcpi:"name=snd_lim_trans_cwnd,prom_type=gauge,prom_help='Number of segments limited by congestion window.'" json:"sndLimTransCwnd,omitempty"`
 	SndLimTimeCwnd      time.Duration `tcpi:"name=snd_lim_time_cwnd,prom_type=gauge,prom_help='Time limited by congestion window in milliseconds.'" json:"sndLimTimeCwnd,omitempty"`
@@ -105,6 +146,16 @@ type SysInfo struct {
 	SndLimTransSnd      uint64        `tcpi:"name=snd_lim_trans_snd,prom_type=gauge,prom_help='Number of segments limited by congestion window.'" json:"sndLimTransSnd,omitempty"`
 	SndLimTimeSnd       time.Duration `tcpi:"name=snd_lim_time_snd,prom_type=gauge,prom_help='Time limited limited by congestion window.'" json:"sndLimTimeSnd,omitempty"`
 	SndLimBytesSnd      uint64        `tcpi:"name=snd_lim_bytes_snd,prom_type=gauge,prom_help='Number of bytes limited by congestion window.'" json:"sndLimBytesSnd,omitempty"`
+	// Start of v2 fields
+	RxOutOfOrderPackets uint32 `tcpi:"name=rx_out_of_order_packets,prom_type=counter,prom_help='Total number of out-of-order packets received.'" json:"rxOutOfOrderPackets,omitempty"`
+	ECNNegotiated       bool   `tcpi:"name=ecn_negotiated,prom_type=gauge,prom_help='Whether ECN was negotiated for the connection.'" json:"ecnNegotiated,omitempty"`
+	RxECEAcks           uint32 `tcpi:"name=rx_ece_acks,prom_type=counter,prom_help='Total number of ACKs received with the ECE flag set.'" json:"rxEceAcks,omitempty"`
+	PTOEpisodes         uint32 `tcpi:"name=pto_episodes,prom_type=counter,prom_help='Number of probe timeout episodes.'" json:"ptoEpisodes,omitempty"`
+
+	sampledAt time.Time // When GetTCPInfo retrieved this information
+
+	// The connection addresses, set by GetTCPInfoFromConn
+	localAddr, remoteAddr string
 }
 
 func (s *SysInfo) ToMap() map[string]any {
@@ -136,16 +187,22 @@ func (s *SysInfo) ToMap() map[string]any {
 		"sndLimTransSnd":      s.SndLimTransSnd,
 		"sndLimTimeSnd":       s.SndLimTimeSnd,
 		"sndLimBytesSnd":      s.SndLimBytesSnd,
+		"rxOutOfOrderPackets": s.RxOutOfOrderPackets,
+		"ecnNegotiated":       s.ECNNegotiated,
+		"rxEceAcks":           s.RxECEAcks,
+		"ptoEpisodes":         s.PTOEpisodes,
 	}
 }
 
+// String returns all fields of the SysInfo as key=value pairs, with durations in their units.
+func (s *SysInfo) String() string {
+	return formatFields(s.ToMap())
+}
+
 func (s *SysInfo) MarshalJSON() ([]byte, error) {
 	return json.Marshal(s.ToMap())
 }
 
-// timeFieldMultiplier is used to convert fields representing time in milliseconds to time.Duration (nanoseconds).
-var timeFieldMultiplier = time.Microsecond
-
 // Unpack converts fields from _TCP_INFO_v0 to SysInfo
 func (packed *RawInfoV0) Unpack() *SysInfo {
 	var unpacked SysInfo
@@ -178,7 +235,7 @@ func (packed *RawInfoV1) Unpack() *SysInfo {
 	unpacked.State = packed.State
 	unpacked.StateName = tcpStateMap[packed.State]
 	unpacked.MSS = packed.Mss
-	unpacked.ConnectedTimeNS = time.Duration(packed.ConnectionTimeMs) * timeFieldMultiplier
+	unpacked.ConnectedTimeNS = time.Duration(packed.ConnectionTimeMs) * time.Millisecond
 	unpacked.RTT = time.Duration(packed.RttUs) * time.Microsecond
 	unpacked.RTTMin = time.Duration(packed.MinRttUs) * time.Microsecond
 	unpacked.BytesInFlight = packed.BytesInFlight
@@ -207,16 +264,58 @@ func (packed *RawInfoV1) Unpack() *SysInfo {
 	return &unpacked
 }
 
+// Unpack converts fields from _TCP_INFO_v2 to SysInfo
+func (packed *RawInfoV2) Unpack() *SysInfo {
+	var unpacked SysInfo
+	unpacked.State = packed.State
+	unpacked.StateName = tcpStateMap[packed.State]
+	unpacked.MSS = packed.Mss
+	unpacked.ConnectedTimeNS = time.Duration(packed.ConnectionTimeMs) * time.Millisecond
+	unpacked.RTT = time.Duration(packed.RttUs) * time.Microsecond
+	unpacked.RTTMin = time.Duration(packed.MinRttUs) * time.Microsecond
+	unpacked.BytesInFlight = packed.BytesInFlight
+	unpacked.CongestionWindow = packed.Cwnd
+	unpacked.TxWindow = packed.SndWnd
+	unpacked.RxWindow = packed.RcvWnd
+	unpacked.RxBuffer = packed.RcvBuf
+	unpacked.TxBytes = packed.BytesOut
+	unpacked.RxBytes = packed.BytesIn
+	unpacked.RxOutOfOrderBytes = packed.BytesReordered
+	unpacked.TxRetransmitBytes = uint64(packed.BytesRetrans)
+	unpacked.FastRetrans = packed.FastRetrans
+	unpacked.DupAcksIn = packed.DupAcksIn
+	unpacked.TimeoutEpisodes = packed.TimeoutEpisodes
+	unpacked.SynRetrans = packed.SynRetrans
+	unpacked.SndLimTransRwin = uint64(packed.SndLimTransRwin)
+	unpacked.SndLimTransTimeRwin = time.Duration(packed.SndLimTimeRwin) * time.Millisecond
+	unpacked.SndLimBytesRwin = packed.SndLimBytesRwin
+	unpacked.SndLimTransCwnd = uint64(packed.SndLimTransCwnd)
+	unpacked.SndLimTimeCwnd = time.Duration(packed.SndLimTimeCwnd) * time.Millisecond
+	unpacked.SndLimBytesCwnd = packed.SndLimBytesCwnd
+	unpacked.SndLimTransSnd = uint64(packed.SndLimTransSnd)
+	unpacked.SndLimTimeSnd = time.Duration(packed.SndLimTimeSnd) * time.Millisecond
+	unpacked.SndLimBytesSnd = packed.SndLimBytesSnd
+	unpacked.RxOutOfOrderPackets = packed.OutOfOrderPktsIn
+	unpacked.ECNNegotiated = packed.EcnNegotiated
+	unpacked.RxECEAcks = packed.EceAcksIn
+	unpacked.PTOEpisodes = packed.PtoEpisodes
+
+	return &unpacked
+}
+
 func (s *SysInfo) ToInfo() *Info {
 	info := &Info{
-		State:        s.StateName,
-		TxMSS:        uint64(s.MSS),
-		RTT:          s.RTTMin,
-		RxWindow:     uint64(s.RxWindow),
-		TxWindowSegs: uint64(s.TxWindow),
-		Retransmits:  uint64(s.SynRetrans),
-		Sys:          s,
+		State:         s.StateName,
+		TxMSS:         uint64(s.MSS),
+		RTT:           s.RTT,
+		RxWindow:      uint64(s.RxWindow),
+		TxWindowBytes: uint64(s.CongestionWindow),
+		Retransmits:   uint64(s.SynRetrans),
+		ECN:           ECNInfo{Negotiated: s.ECNNegotiated, CEMarkedSegments: uint64(s.RxECEAcks)},
+		Sys:           s,
 	}
+	info.setSampledAt(s.sampledAt)
+	info.LocalAddr, info.RemoteAddr = s.localAddr, s.remoteAddr
 	return info
 }
 
@@ -261,60 +360,129 @@ func tcpInfoTCPStateString(state uint32) string {
 
 // ================================================================================================================== //
 
-// Errors from syscall package are private, so we define our own to match the errno.
-var (
-	EAGAIN error = syscall.EAGAIN
-	EINVAL error = syscall.EINVAL
-	ENOENT error = syscall.ENOENT
-)
-
-// GetTCPInfo calls getsockopt(2) on Linux to retrieve tcp_info and unpacks that into the golang-friendly TCPInfo.
-func GetTCPInfo(fds uintptr) (*SysInfo, error) {
-	fd := syscall.Handle(fds)
+// tcpInfoIoctler issues SIO_TCP_INFO for the requested _TCP_INFO version, filling out with size bytes.
+// It exists so the version negotiation in getTCPInfo can be tested without a real socket.
+type tcpInfoIoctler interface {
+	ioctl(fd syscall.Handle, version uint32, out unsafe.Pointer, size uint32) error
+}
 
-	// Try _TCP_INFO_v1 first
-	var inbufv1 uint32 = 1
-	var outbufv1 RawInfoV1
+type wsaIoctler struct{}
 
-	var cbbr uint32 = 0
+func (wsaIoctler) ioctl(fd syscall.Handle, version uint32, out unsafe.Pointer, size uint32) error {
+	var cbbr uint32
 	var ov syscall.Overlapped
-
-	// Try _TCP_INFO_v1 first to get extra fields
-	if err := syscall.WSAIoctl(
+	return syscall.WSAIoctl(
 		fd,
 		SIO_TCP_INFO,
-		(*byte)(unsafe.Pointer(&inbufv1)),
-		uint32(unsafe.Sizeof(inbufv1)),
-		(*byte)(unsafe.Pointer(&outbufv1)),
-		uint32(unsafe.Sizeof(outbufv1)),
+		(*byte)(unsafe.Pointer(&version)),
+		uint32(unsafe.Sizeof(version)),
+		(*byte)(out),
+		size,
 		&cbbr,
 		&ov,
 		0,
-	); err == nil {
-		return outbufv1.Unpack(), nil
-	}
+	)
+}
+
+var defaultIoctler tcpInfoIoctler = wsaIoctler{}
+
+// GetTCPInfo calls WSAIoctl(SIO_TCP_INFO) on Windows to retrieve _TCP_INFO and unpacks that into the golang-friendly SysInfo.
+// The newest structure version is tried first, falling back to older versions on builds that reject it.
+func GetTCPInfo(fds uintptr) (*SysInfo, error) {
+	return getTCPInfo(syscall.Handle(fds), defaultIoctler)
+}
 
-	// Fallback to using _TCP_INFO_v0
-	var inbufv0 uint32 = 0
+func getTCPInfo(fd syscall.Handle, ioc tcpInfoIoctler) (*SysInfo, error) {
+	var sysInfo *SysInfo
+
+	// Try _TCP_INFO_v2 first to get ECN and probe timeout fields
+	var outbufv2 RawInfoV2
+	var outbufv1 RawInfoV1
 	var outbufv0 RawInfoV0
-	if err := syscall.WSAIoctl(
-		fd,
-		SIO_TCP_INFO,
-		(*byte)(unsafe.Pointer(&inbufv0)),
-		uint32(unsafe.Sizeof(inbufv0)),
-		(*byte)(unsafe.Pointer(&outbufv0)),
-		uint32(unsafe.Sizeof(outbufv0)),
-		&cbbr,
-		&ov,
-		0,
-	); err != nil {
-		return nil, fmt.Errorf("could not perform the WSAIoctl: %v", err)
+	if err := ioc.ioctl(fd, 2, unsafe.Pointer(&outbufv2), uint32(unsafe.Sizeof(outbufv2))); err == nil {
+		sysInfo = outbufv2.Unpack()
+	} else if err := ioc.ioctl(fd, 1, unsafe.Pointer(&outbufv1), uint32(unsafe.Sizeof(outbufv1))); err == nil {
+		sysInfo = outbufv1.Unpack()
+	} else if err := ioc.ioctl(fd, 0, unsafe.Pointer(&outbufv0), uint32(unsafe.Sizeof(outbufv0))); err == nil {
+		sysInfo = outbufv0.Unpack()
+	} else {
+		return nil, wsaIoctlError(err)
 	}
-	return outbufv0.Unpack(), nil
+	sysInfo.sampledAt = time.Now()
+	return sysInfo, nil
+}
+
+// wsaErrnos maps the Winsock errors from WSAIoctl to the package errno sentinels.
+var wsaErrnos = map[syscall.Errno]error{
+	windows.WSAEWOULDBLOCK: EAGAIN,
+	windows.WSAEINVAL:      EINVAL,
+	windows.WSAENOTCONN:    ENOTCONN,
+	windows.WSAEOPNOTSUPP:  EOPNOTSUPP,
+	windows.WSAENOPROTOOPT: ENOPROTOOPT,
 }
 
+// wsaIoctlError wraps an error from WSAIoctl like tcpInfoError, also matching the corresponding errno sentinel
+// when there is one.
+func wsaIoctlError(err error) error {
+	var errno syscall.Errno
+	if errors.As(err, &errno) {
+		if sentinel, ok := wsaErrnos[errno]; ok {
+			return &opError{op: tcpInfoOp, err: err, sentinel: sentinel}
+		}
+	}
+	return tcpInfoError(err)
+}
+
+// Supported reports whether TCP info is available on this platform.
 func Supported() bool {
-	return true
+	ok, _ := SupportedReason()
+	return ok
+}
+
+// SupportedReason is like Supported, but also explains why TCP info is unavailable. The reason is empty when it is available.
+func SupportedReason() (bool, string) {
+	return true, ""
+}
+
+// RetransmittedBytes returns the number of bytes retransmitted.
+func (s *SysInfo) RetransmittedBytes() (uint64, bool) {
+	return s.TxRetransmitBytes, true
+}
+
+// SentBytes returns the number of bytes sent, including retransmissions.
+func (s *SysInfo) SentBytes() (uint64, bool) {
+	return s.TxBytes, true
+}
+
+// BaseRTT returns the minimum RTT observed on the connection.
+func (s *SysInfo) BaseRTT() (time.Duration, bool) {
+	return s.RTTMin, s.RTTMin > 0
+}
+
+// LimitedFraction returns the fraction of the sending time that was limited by the receive window
+// or the sender, rather than the congestion window. It is only available from SIO_TCP_INFO v1.
+func (s *SysInfo) LimitedFraction() (float64, bool) {
+	limited := s.SndLimTransTimeRwin + s.SndLimTimeSnd
+	total := limited + s.SndLimTimeCwnd
+	if total <= 0 {
+		return 0, false
+	}
+	return float64(limited) / float64(total), true
+}
+
+// AverageSendRate returns the bytes sent per second over the life of the connection, from TxBytes
+// and ConnectedTimeNS. Idle time is included, so it underestimates the available bandwidth.
+func (s *SysInfo) AverageSendRate() (uint64, bool) {
+	if s.ConnectedTimeNS <= 0 {
+		return 0, false
+	}
+	return uint64(float64(s.TxBytes) / s.ConnectedTimeNS.Seconds()), true
+}
+
+// SendProgress returns the bytes acknowledged by the peer and the bytes queued but not yet sent.
+// SIO_TCP_INFO reports neither, so this is never available.
+func (s *SysInfo) SendProgress() (acked, notSent uint64, ok bool) {
+	return 0, 0, false
 }
 
 func (s *SysInfo) Warnings() []string {
@@ -337,5 +505,17 @@ func (s *SysInfo) Warnings() []string {
 	if s.FastRetrans > 0 {
 		warns = append(warns, "fastRetransmissions="+strconv.FormatUint(uint64(s.FastRetrans), 10))
 	}
+	if s.RxOutOfOrderPackets > 0 {
+		warns = append(warns, "outOfOrderPackets="+strconv.FormatUint(uint64(s.RxOutOfOrderPackets), 10))
+	}
+	if s.PTOEpisodes > 0 {
+		warns = append(warns, "ptoEpisodes="+strconv.FormatUint(uint64(s.PTOEpisodes), 10))
+	}
+	if warn, ok := bufferbloatWarning(s.RTT, s.RTTMin); ok {
+		warns = append(warns, warn)
+	}
 	return warns
 }
+
+// tcpInfoOp names the platform and call that retrieves TCP info in errors from GetTCPInfo.
+const tcpInfoOp = "windows WSAIoctl(SIO_TCP_INFO)"
@@ -0,0 +1,248 @@
+//go:build windows
+
+package tcpinfo
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"syscall"
+	"testing"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+// fakeIoctler answers SIO_TCP_INFO for versions up to max and fails for anything newer.
+type fakeIoctler struct {
+	max   int
+	err   error
+	tried []uint32
+}
+
+func (f *fakeIoctler) ioctl(fd syscall.Handle, version uint32, out unsafe.Pointer, size uint32) error {
+	f.tried = append(f.tried, version)
+	if int(version) > f.max {
+		return f.err
+	}
+	// Every version shares the v0 prefix, so fill that in along with a version-specific marker.
+	v0 := (*RawInfoV0)(out)
+	v0.State = TCPS_ESTABLISHED
+	v0.Mss = 1000 + version
+	switch version {
+	case 2:
+		v2 := (*RawInfoV2)(out)
+		v2.SndLimTransSnd = 3
+		v2.EcnNegotiated = true
+		v2.PtoEpisodes = 4
+	case 1:
+		(*RawInfoV1)(out).SndLimTransSnd = 3
+	}
+	return nil
+}
+
+func TestGetTCPInfoVersionFallback(t *testing.T) {
+	for _, tc := range []struct {
+		name      string
+		max       int
+		wantTried []uint32
+		wantMSS   uint32
+		wantLimit uint64
+		wantECN   bool
+		wantErr   bool
+	}{
+		{name: "v2", max: 2, wantTried: []uint32{2}, wantMSS: 1002, wantLimit: 3, wantECN: true},
+		{name: "v1", max: 1, wantTried: []uint32{2, 1}, wantMSS: 1001, wantLimit: 3},
+		{name: "v0", max: 0, wantTried: []uint32{2, 1, 0}, wantMSS: 1000},
+		{name: "none", max: -1, wantTried: []uint32{2, 1, 0}, wantErr: true},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			ioc := &fakeIoctler{max: tc.max, err: syscall.EINVAL}
+			info, err := getTCPInfo(0, ioc)
+			if !reflect.DeepEqual(ioc.tried, tc.wantTried) {
+				t.Errorf("tried versions %v, want %v", ioc.tried, tc.wantTried)
+			}
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("getTCPInfo: %v", err)
+			}
+			if info.StateName != "ESTABLISHED" || info.MSS != tc.wantMSS {
+				t.Errorf("state=%q mss=%d, want ESTABLISHED/%d", info.StateName, info.MSS, tc.wantMSS)
+			}
+			if info.SndLimTransSnd != tc.wantLimit {
+				t.Errorf("SndLimTransSnd = %d, want %d", info.SndLimTransSnd, tc.wantLimit)
+			}
+			if info.ECNNegotiated != tc.wantECN {
+				t.Errorf("ECNNegotiated = %v, want %v", info.ECNNegotiated, tc.wantECN)
+			}
+			if info.sampledAt.IsZero() {
+				t.Errorf("sampledAt not set")
+			}
+		})
+	}
+}
+
+func TestRawInfoUnpackConnectedTime(t *testing.T) {
+	for name, info := range map[string]*SysInfo{
+		"v0": (&RawInfoV0{ConnectionTimeMs: 5000}).Unpack(),
+		"v1": (&RawInfoV1{ConnectionTimeMs: 5000}).Unpack(),
+		"v2": (&RawInfoV2{ConnectionTimeMs: 5000}).Unpack(),
+	} {
+		if info.ConnectedTimeNS != 5*time.Second {
+			t.Errorf("%s: ConnectedTimeNS = %v, want 5s", name, info.ConnectedTimeNS)
+		}
+	}
+}
+
+func TestWSAIoctlError(t *testing.T) {
+	for _, tc := range []struct {
+		errno    syscall.Errno
+		sentinel error
+	}{
+		{windows.WSAENOTCONN, ENOTCONN},
+		{windows.WSAEOPNOTSUPP, EOPNOTSUPP},
+	} {
+		err := wsaIoctlError(tc.errno)
+		if !errors.Is(err, tc.sentinel) || !errors.Is(err, tc.errno) {
+			t.Errorf("wsaIoctlError(%v) = %v, want it to match %v and the original errno", tc.errno, err, tc.sentinel)
+		}
+		if errors.Unwrap(err) != tc.errno {
+			t.Errorf("errors.Unwrap(%v) = %v, want %v", err, errors.Unwrap(err), tc.errno)
+		}
+	}
+}
+
+func TestInfoRetransmitRatio(t *testing.T) {
+	info := (&SysInfo{TxBytes: 4000, TxRetransmitBytes: 40}).ToInfo()
+	if got := info.RetransmitRatio(); got != 0.01 {
+		t.Errorf("RetransmitRatio() = %v, want 0.01", got)
+	}
+	if !info.IsLossy(0.005) || info.IsLossy(0.01) {
+		t.Errorf("IsLossy gave unexpected results for a ratio of 0.01")
+	}
+	if got := (&SysInfo{}).ToInfo().RetransmitRatio(); got != 0 {
+		t.Errorf("RetransmitRatio() with nothing sent = %v, want 0", got)
+	}
+}
+
+func TestSysInfoWarningsBufferbloat(t *testing.T) {
+	if got := (&SysInfo{RTT: 80 * time.Millisecond, RTTMin: 12 * time.Millisecond}).Warnings(); !reflect.DeepEqual(got, []string{"bufferbloat rtt=80ms minRtt=12ms"}) {
+		t.Errorf("Warnings() = %v, want a bufferbloat warning", got)
+	}
+	if got := (&SysInfo{RTT: 40 * time.Millisecond, RTTMin: 12 * time.Millisecond}).Warnings(); len(got) != 0 {
+		t.Errorf("Warnings() below the threshold = %v, want none", got)
+	}
+}
+
+func TestInfoHealthScore(t *testing.T) {
+	clean := (&SysInfo{
+		RTT:            10 * time.Millisecond,
+		RTTMin:         10 * time.Millisecond,
+		TxBytes:        100000,
+		SndLimTimeCwnd: time.Second,
+	}).ToInfo()
+	lossy := (&SysInfo{
+		RTT:                 40 * time.Millisecond,
+		RTTMin:              10 * time.Millisecond,
+		TxBytes:             100000,
+		TxRetransmitBytes:   2500,
+		SndLimTransTimeRwin: 300 * time.Millisecond,
+		SndLimTimeSnd:       200 * time.Millisecond,
+		SndLimTimeCwnd:      500 * time.Millisecond,
+	}).ToInfo()
+
+	if got := clean.HealthScore(); got != 100 {
+		t.Errorf("clean HealthScore() = %d, want 100", got)
+	}
+	// Retransmits cost 25 of 50, RTT inflation 30 of 30, and window limits 10 of 20.
+	if got := lossy.HealthScore(); got != 35 {
+		t.Errorf("lossy HealthScore() = %d, want 35", got)
+	}
+}
+
+func TestInfoECN(t *testing.T) {
+	got := (&RawInfoV2{EcnNegotiated: true, EceAcksIn: 3}).Unpack().ToInfo().ECN
+	want := ECNInfo{Negotiated: true, CEMarkedSegments: 3}
+	if got != want {
+		t.Errorf("ECN = %+v, want %+v", got, want)
+	}
+	if got := (&RawInfoV1{}).Unpack().ToInfo().ECN; got != (ECNInfo{}) {
+		t.Errorf("ECN from v1 = %+v, want zero", got)
+	}
+}
+
+func TestInfoEstimatedBandwidth(t *testing.T) {
+	info := (&SysInfo{TxBytes: 500000, ConnectedTimeNS: 4 * time.Second}).ToInfo()
+	if bw, appLimited, ok := info.EstimatedBandwidth(); !ok || bw != 125000 || appLimited {
+		t.Errorf("EstimatedBandwidth() = %d, %v, %v, want 125000, false, true", bw, appLimited, ok)
+	}
+	if _, _, ok := (&SysInfo{TxBytes: 500000}).ToInfo().EstimatedBandwidth(); ok {
+		t.Errorf("EstimatedBandwidth() without a connection time should not be available")
+	}
+}
+
+func TestSysInfoEqualDiff(t *testing.T) {
+	a := &SysInfo{StateName: "ESTABLISHED", RTT: 10 * time.Millisecond, CongestionWindow: 14480, TxBytes: 1000, sampledAt: time.Now()}
+	b := *a
+	b.sampledAt = a.sampledAt.Add(time.Second)
+	if !a.Equal(&b) || len(a.Diff(&b)) != 0 {
+		t.Errorf("identical SysInfos compared as different: %v", a.Diff(&b))
+	}
+
+	b.TxBytes = 2000
+	if a.Equal(&b) {
+		t.Errorf("Equal() = true for differing SysInfos")
+	}
+	want := map[string][2]any{"txBytes": {uint64(1000), uint64(2000)}}
+	if got := a.Diff(&b); !reflect.DeepEqual(got, want) {
+		t.Errorf("Diff() = %v, want %v", got, want)
+	}
+}
+
+func TestToInfoWindowFields(t *testing.T) {
+	info := (&SysInfo{MSS: 1448, CongestionWindow: 14480, TxWindow: 131072}).ToInfo()
+	if info.TxWindowBytes != 14480 {
+		t.Errorf("TxWindowBytes = %d, want 14480", info.TxWindowBytes)
+	}
+	// Windows reports the congestion window in bytes only, and no slow start thresholds
+	if info.TxWindowSegs != 0 || info.TxSSThreshold != 0 || info.RxSSThreshold != 0 {
+		t.Errorf("TxWindowSegs=%d TxSSThreshold=%d RxSSThreshold=%d, want 0", info.TxWindowSegs, info.TxSSThreshold, info.RxSSThreshold)
+	}
+}
+
+func TestSetKeepAliveCountUnsupported(t *testing.T) {
+	if err := SetKeepAlive(0, true, 0, 0, 3); !errors.Is(err, ErrUnsupportedPlatform) {
+		t.Fatalf("SetKeepAlive with a count: got %v, want ErrUnsupportedPlatform", err)
+	}
+}
+
+func TestInfoMarshalJSON_Windows(t *testing.T) {
+	sys := &SysInfo{
+		State:            TCPS_ESTABLISHED,
+		StateName:        "ESTABLISHED",
+		MSS:              65495,
+		ConnectedTimeNS:  25 * time.Millisecond,
+		RTT:              1500 * time.Microsecond,
+		RTTMin:           time.Millisecond,
+		CongestionWindow: 654950,
+		TxWindow:         2619648,
+		RxWindow:         2619648,
+		RxBuffer:         65536,
+		TxBytes:          4096,
+		RxBytes:          2048,
+		SndLimTransCwnd:  1,
+		SndLimTimeCwnd:   2 * time.Millisecond,
+		SndLimBytesCwnd:  4096,
+	}
+	data, err := json.Marshal(sys.ToInfo())
+	if err != nil {
+		t.Fatalf("marshal: %v", err)
+	}
+	checkGolden(t, "info_windows.golden.json", data)
+}
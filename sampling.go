@@ -0,0 +1,214 @@
+package conniver
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+)
+
+// StartSampling gathers a TCP info snapshot from the underlying connection every interval
+// until ctx is done, the connection is closed, or the returned stop function is called,
+// whichever comes first. Pass w.Ctx() to tie sampling to the context the connection was
+// wrapped with. Snapshots are stored in order and can be retrieved with Samples(). Every
+// snapshot is kept unless EnableSamplingRing bounds them. Sampling never triggers the report
+// callback.
+//
+// The stop function waits for the sampler to exit, so no samples are added after it returns.
+// It is safe to call more than once, and after the sampler has already stopped.
+func (w *Conn) StartSampling(ctx context.Context, interval time.Duration) (stop func()) {
+	if !w.supportsTCPInfo || w.done == nil {
+		return func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	exited := make(chan struct{})
+	w.samplers.Go(func() {
+		defer close(exited)
+		defer cancel()
+		w.sampleLoop(ctx, w.Conn, w.done, interval)
+	})
+	return func() {
+		cancel()
+		<-exited
+	}
+}
+
+// OnStateChange registers fn to be called by the sampler started with StartSampling whenever the
+// kernel TCP state (such as ESTABLISHED or CLOSE_WAIT) differs from the previous sample. It is not
+// called for the first sample, and never called at all unless StartSampling is running. The
+// callback runs on the sampler goroutine.
+func (w *Conn) OnStateChange(fn func(old, new string)) {
+	w.Lock()
+	defer w.Unlock()
+	w.onStateChange = fn
+}
+
+// sampleLoop samples conn until ctx or done is closed. The connection and done channel are passed in
+// so that a sampler stopped by Reset never sees the replacement connection.
+func (w *Conn) sampleLoop(ctx context.Context, conn net.Conn, done <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	var lastState string
+	for {
+		select {
+		case <-done:
+			return
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		// Control fails with net.ErrClosed once the connection is closed, so a
+		// sample racing with Close never touches a stale file descriptor.
+		sysInfo, _ := tcpinfo.GetTCPInfoFromConn(conn)
+		if sysInfo == nil {
+			continue
+		}
+
+		info := sysInfo.ToInfo()
+		w.Lock()
+		w.appendSample(info)
+		onStateChange := w.onStateChange
+		w.Unlock()
+
+		if lastState != "" && info.State != lastState && onStateChange != nil {
+			onStateChange(lastState, info.State)
+		}
+		lastState = info.State
+	}
+}
+
+// stopSampling signals any running sampler to exit without waiting for it.
+func (w *Conn) stopSampling() {
+	if w.done == nil {
+		return
+	}
+	w.doneOnce.Do(func() {
+		close(w.done)
+	})
+}
+
+// Samples returns a copy of the TCP info snapshots gathered by StartSampling, oldest first.
+func (w *Conn) Samples() []*tcpinfo.Info {
+	w.Lock()
+	defer w.Unlock()
+	return w.orderedSamples()
+}
+
+// EnableSamplingRing bounds the snapshots kept by StartSampling to the most recent capacity,
+// overwriting the oldest once the ring is full, so a long-lived connection's memory stays flat.
+// Snapshots already gathered beyond the capacity are dropped, oldest first. A capacity of zero
+// or less restores the default of keeping every snapshot.
+func (w *Conn) EnableSamplingRing(capacity int) {
+	w.Lock()
+	defer w.Unlock()
+	samples := w.orderedSamples()
+	if capacity > 0 && len(samples) > capacity {
+		samples = samples[len(samples)-capacity:]
+	}
+	w.samples, w.sampleHead = samples, 0
+	w.sampleRing = max(capacity, 0)
+}
+
+// SampleStats summarizes the snapshots retained by StartSampling.
+type SampleStats struct {
+	RTTMin            time.Duration `json:"rttMin"`            // Lowest RTT sampled
+	RTTMax            time.Duration `json:"rttMax"`            // Highest RTT sampled
+	RTTMean           time.Duration `json:"rttMean"`           // Mean of the sampled RTTs
+	MaxCwnd           uint64        `json:"maxCwnd"`           // Largest congestion window, in segments (see tcpinfo.Info.CongestionWindow)
+	TotalRetransDelta uint64        `json:"totalRetransDelta"` // Retransmits added between the oldest and newest samples
+}
+
+// SampleStats computes summary statistics over the snapshots currently retained by StartSampling.
+// Samples without an RTT are left out of the RTT statistics, and the zero value is returned when
+// there are no samples.
+func (w *Conn) SampleStats() SampleStats {
+	w.Lock()
+	samples := w.orderedSamples()
+	w.Unlock()
+
+	var stats SampleStats
+	var rttSum time.Duration
+	var rttCount int
+	for i, info := range samples {
+		if info.RTT > 0 {
+			if rttCount == 0 || info.RTT < stats.RTTMin {
+				stats.RTTMin = info.RTT
+			}
+			stats.RTTMax = max(stats.RTTMax, info.RTT)
+			rttSum += info.RTT
+			rttCount++
+		}
+		if cwnd, ok := info.CongestionWindow(); ok {
+			stats.MaxCwnd = max(stats.MaxCwnd, cwnd)
+		}
+		// Retransmits is cumulative, so only count increases in case a platform resets it.
+		if i > 0 && info.Retransmits > samples[i-1].Retransmits {
+			stats.TotalRetransDelta += info.Retransmits - samples[i-1].Retransmits
+		}
+	}
+	if rttCount > 0 {
+		stats.RTTMean = rttSum / time.Duration(rttCount)
+	}
+	return stats
+}
+
+// IsStalled reports whether the connection is established but making no forward progress over
+// the most recent window of samples gathered by StartSampling. The newest sample is compared with
+// the oldest one taken within window of it: the connection is stalled when no more bytes were
+// acknowledged, the bytes queued but not yet sent did not drain, and the retransmission timeout
+// grew as the kernel backed off. It returns false unless there are at least two samples within
+// the window and the platform reports send progress (see tcpinfo.SysInfo.SendProgress).
+func (w *Conn) IsStalled(window time.Duration) bool {
+	w.Lock()
+	samples := w.orderedSamples()
+	w.Unlock()
+	if len(samples) < 2 {
+		return false
+	}
+
+	latest := samples[len(samples)-1]
+	var earlier *tcpinfo.Info
+	for _, info := range samples[:len(samples)-1] {
+		if !info.SampledAt.IsZero() && latest.SampledAt.Sub(info.SampledAt) <= window {
+			earlier = info
+			break
+		}
+	}
+	if earlier == nil || latest.State != "ESTABLISHED" || latest.Sys == nil || earlier.Sys == nil {
+		return false
+	}
+	acked, notSent, ok := latest.Sys.SendProgress()
+	prevAcked, prevNotSent, prevOK := earlier.Sys.SendProgress()
+	if !ok || !prevOK {
+		return false
+	}
+	return acked <= prevAcked && notSent > 0 && notSent >= prevNotSent && latest.RTO > earlier.RTO
+}
+
+// appendSample stores info, overwriting the oldest sample once a ring set by EnableSamplingRing is
+// full. The caller must hold the lock.
+func (w *Conn) appendSample(info *tcpinfo.Info) {
+	if w.sampleRing == 0 || len(w.samples) < w.sampleRing {
+		w.samples = append(w.samples, info)
+		return
+	}
+	w.samples[w.sampleHead] = info
+	w.sampleHead = (w.sampleHead + 1) % w.sampleRing
+}
+
+// latestSample returns the most recent sample, or nil if there are none. The caller must hold the lock.
+func (w *Conn) latestSample() *tcpinfo.Info {
+	if len(w.samples) == 0 {
+		return nil
+	}
+	return w.samples[(w.sampleHead+len(w.samples)-1)%len(w.samples)]
+}
+
+// orderedSamples returns a copy of the samples, oldest first. The caller must hold the lock.
+func (w *Conn) orderedSamples() []*tcpinfo.Info {
+	samples := make([]*tcpinfo.Info, 0, len(w.samples))
+	samples = append(samples, w.samples[w.sampleHead:]...)
+	return append(samples, w.samples[:w.sampleHead]...)
+}
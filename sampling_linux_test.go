@@ -0,0 +1,87 @@
+//go:build linux
+
+package conniver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+)
+
+// progressSample builds a synthetic sample taken at the given offset from start.
+func progressSample(start time.Time, at time.Duration, acked uint64, notSent uint32, rto time.Duration) *tcpinfo.Info {
+	return &tcpinfo.Info{
+		State:     "ESTABLISHED",
+		RTO:       rto,
+		SampledAt: start.Add(at),
+		Sys: &tcpinfo.SysInfo{
+			BytesAcked:   tcpinfo.NullableUint64{Valid: true, Value: acked},
+			NotSentBytes: tcpinfo.NullableUint32{Valid: true, Value: notSent},
+		},
+	}
+}
+
+func TestIsStalled(t *testing.T) {
+	start := time.Now()
+	for _, tc := range []struct {
+		name    string
+		samples []*tcpinfo.Info
+		window  time.Duration
+		want    bool
+	}{
+		{
+			name: "stalled",
+			samples: []*tcpinfo.Info{
+				progressSample(start, 0, 1000, 65536, 200*time.Millisecond),
+				progressSample(start, time.Second, 1000, 65536, 400*time.Millisecond),
+				progressSample(start, 2*time.Second, 1000, 98304, 800*time.Millisecond),
+			},
+			window: 5 * time.Second,
+			want:   true,
+		},
+		{
+			name: "draining",
+			samples: []*tcpinfo.Info{
+				progressSample(start, 0, 1000, 65536, 200*time.Millisecond),
+				progressSample(start, time.Second, 33768, 32768, 200*time.Millisecond),
+				progressSample(start, 2*time.Second, 66536, 0, 200*time.Millisecond),
+			},
+			window: 5 * time.Second,
+			want:   false,
+		},
+		{
+			name: "idle",
+			samples: []*tcpinfo.Info{
+				progressSample(start, 0, 1000, 0, 200*time.Millisecond),
+				progressSample(start, time.Second, 1000, 0, 200*time.Millisecond),
+			},
+			window: 5 * time.Second,
+			want:   false,
+		},
+		{
+			name: "one sample in window",
+			samples: []*tcpinfo.Info{
+				progressSample(start, 0, 1000, 65536, 200*time.Millisecond),
+				progressSample(start, 10*time.Second, 1000, 65536, 800*time.Millisecond),
+			},
+			window: 5 * time.Second,
+			want:   false,
+		},
+		{
+			name:   "no samples",
+			window: 5 * time.Second,
+			want:   false,
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &Conn{}
+			for _, info := range tc.samples {
+				c.appendSample(info)
+			}
+			if got := c.IsStalled(tc.window); got != tc.want {
+				t.Errorf("IsStalled(%v) = %v, want %v", tc.window, got, tc.want)
+			}
+		})
+	}
+}
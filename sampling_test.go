@@ -0,0 +1,202 @@
+package conniver
+
+import (
+	"context"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+)
+
+func TestStartSampling(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	client, server := loopbackPair(t)
+	go func() { _, _ = io.Copy(io.Discard, server) }()
+
+	c := WrapConn(client, func(*Conn, int) {}).(*Conn)
+	c.StartSampling(context.Background(), 20*time.Millisecond)
+
+	deadline := time.Now().Add(200 * time.Millisecond)
+	buf := make([]byte, 1024)
+	for time.Now().Before(deadline) {
+		if _, err := c.Write(buf); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if err := c.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	samples := c.Samples()
+	if len(samples) < 2 {
+		t.Fatalf("expected at least 2 samples, got %d", len(samples))
+	}
+	for i, s := range samples {
+		if s == nil || s.State == "" {
+			t.Fatalf("sample %d is empty: %#v", i, s)
+		}
+	}
+
+	// The sampler must stop once the connection is closed
+	time.Sleep(60 * time.Millisecond)
+	if n := len(c.Samples()); n != len(samples) {
+		t.Fatalf("sampling continued after close: %d -> %d", len(samples), n)
+	}
+}
+
+func TestOnStateChange(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	client, server := loopbackPair(t)
+
+	c := WrapConn(client, func(*Conn, int) {}).(*Conn)
+	defer c.Close()
+	type transition struct{ old, new string }
+	changes := make(chan transition, 10)
+	c.OnStateChange(func(old, new string) { changes <- transition{old, new} })
+	c.StartSampling(context.Background(), 5*time.Millisecond)
+
+	// Let the sampler record the initial state, which must not be reported as a change.
+	deadline := time.Now().Add(time.Second)
+	for len(c.Samples()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	select {
+	case ch := <-changes:
+		t.Fatalf("unexpected transition before half-close: %v", ch)
+	default:
+	}
+
+	// Half-close from the server side, moving the client to CLOSE_WAIT.
+	if err := server.CloseWrite(); err != nil {
+		t.Fatalf("close write: %v", err)
+	}
+	select {
+	case ch := <-changes:
+		if ch.old != "ESTABLISHED" || ch.new != "CLOSE_WAIT" {
+			t.Fatalf("unexpected transition %v", ch)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("no state transition reported")
+	}
+}
+
+func TestStartSamplingContextCancel(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	client, _ := loopbackPair(t)
+	c := WrapConn(client, func(*Conn, int) {}).(*Conn)
+	defer c.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	stop := c.StartSampling(ctx, 5*time.Millisecond)
+	deadline := time.Now().Add(time.Second)
+	for len(c.Samples()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	cancel()
+
+	// stop waits for the sampler goroutine, so it only returns once the cancellation was observed
+	exited := make(chan struct{})
+	go func() {
+		stop()
+		close(exited)
+	}()
+	select {
+	case <-exited:
+	case <-time.After(time.Second):
+		t.Fatal("sampler did not exit after the context was cancelled")
+	}
+
+	n := len(c.Samples())
+	time.Sleep(30 * time.Millisecond)
+	if got := len(c.Samples()); got != n {
+		t.Fatalf("sampling continued after cancel: %d -> %d", n, got)
+	}
+	stop()
+}
+
+func TestStartSamplingStop(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	client, _ := loopbackPair(t)
+	c := WrapConn(client, func(*Conn, int) {}).(*Conn)
+	defer c.Close()
+
+	stop := c.StartSampling(context.Background(), 5*time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	stop()
+	n := len(c.Samples())
+	time.Sleep(30 * time.Millisecond)
+	if got := len(c.Samples()); got != n {
+		t.Fatalf("sampling continued after stop: %d -> %d", n, got)
+	}
+}
+
+func TestSampleStatsCwndBytes(t *testing.T) {
+	c := &Conn{}
+	// Windows, Darwin, and FreeBSD only report the congestion window in bytes
+	c.appendSample(&tcpinfo.Info{TxWindowBytes: 14480, TxMSS: 1448})
+	c.appendSample(&tcpinfo.Info{TxWindowBytes: 28960, TxMSS: 1448})
+	c.appendSample(&tcpinfo.Info{TxWindowSegs: 12})
+	if got := c.SampleStats().MaxCwnd; got != 20 {
+		t.Errorf("MaxCwnd = %d, want 20 segments", got)
+	}
+}
+
+func TestSamplingRing(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+	c := &Conn{Conn: client}
+	c.appendSample(&tcpinfo.Info{RTT: time.Second, TxWindowSegs: 100})
+	c.EnableSamplingRing(3)
+	for i := 1; i <= 5; i++ {
+		c.appendSample(&tcpinfo.Info{
+			RTT:          time.Duration(i) * 10 * time.Millisecond,
+			TxWindowSegs: uint64(10 + i),
+			Retransmits:  uint64(i * i),
+		})
+	}
+
+	samples := c.Samples()
+	if len(samples) != 3 {
+		t.Fatalf("kept %d samples, want 3", len(samples))
+	}
+	for i, info := range samples {
+		if want := time.Duration(i+3) * 10 * time.Millisecond; info.RTT != want {
+			t.Errorf("sample %d RTT = %v, want %v", i, info.RTT, want)
+		}
+	}
+	if got := c.Event(Sampled).Info; got != samples[2] {
+		t.Errorf("Event(Sampled).Info = %+v, want the newest sample", got)
+	}
+
+	// Only the samples with RTTs of 30, 40, and 50ms remain, with Retransmits 9, 16, and 25.
+	want := SampleStats{
+		RTTMin:            30 * time.Millisecond,
+		RTTMax:            50 * time.Millisecond,
+		RTTMean:           40 * time.Millisecond,
+		MaxCwnd:           15,
+		TotalRetransDelta: 16,
+	}
+	if got := c.SampleStats(); got != want {
+		t.Errorf("SampleStats() = %+v, want %+v", got, want)
+	}
+
+	c.EnableSamplingRing(2)
+	if samples := c.Samples(); len(samples) != 2 || samples[0].RTT != 40*time.Millisecond {
+		t.Errorf("shrinking the ring kept %d samples starting at %v, want 2 starting at 40ms", len(samples), samples[0].RTT)
+	}
+	if got := (&Conn{}).SampleStats(); got != (SampleStats{}) {
+		t.Errorf("SampleStats() without samples = %+v, want zero", got)
+	}
+}
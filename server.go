@@ -0,0 +1,89 @@
+package conniver
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type serverConnKey struct{}
+
+// ServerWithSockStats installs ConnContext and ConnState hooks on srv that wrap every accepted
+// connection in a Conn and report it to reportStatsFn: open when the connection is accepted, and
+// close when it is closed or hijacked. Any ConnContext and ConnState hooks already set on srv are
+// still called. Handlers can reach the Conn of a request with ServerConnFromContext.
+//
+// Since the server reads and writes the connection directly, the byte and call counters of the
+// Conn are not updated; serve on WrapListener instead when they are needed. The server has already
+// closed the connection by the time the close is reported, so ClosedInfo holds the TCP info
+// gathered when the connection last went idle between requests, or when it was hijacked.
+func ServerWithSockStats(srv *http.Server, reportStatsFn ReportStatsFn) {
+	var conns sync.Map // net.Conn -> *Conn
+
+	connContext := srv.ConnContext
+	srv.ConnContext = func(ctx context.Context, c net.Conn) context.Context {
+		if connContext != nil {
+			ctx = connContext(ctx, c)
+		}
+		w := newConn(ctx, c, reportStatsFn)
+		conns.Store(c, w)
+		return context.WithValue(ctx, serverConnKey{}, w)
+	}
+
+	connState := srv.ConnState
+	srv.ConnState = func(c net.Conn, state http.ConnState) {
+		if v, ok := conns.Load(c); ok {
+			w := v.(*Conn)
+			switch state {
+			case http.StateNew:
+				w.gatherAndReport(Opened)
+			case http.StateIdle:
+				w.updateClosedInfo()
+			case http.StateHijacked, http.StateClosed:
+				conns.Delete(c)
+				// A hijacked connection is still open, so its final TCP info is available
+				if state == http.StateHijacked {
+					w.updateClosedInfo()
+				}
+				w.reportServerClose()
+			}
+		}
+		if connState != nil {
+			connState(c, state)
+		}
+	}
+}
+
+// ServerConnFromContext returns the Conn stored by ServerWithSockStats in the context of a request
+// or connection.
+func ServerConnFromContext(ctx context.Context) (*Conn, bool) {
+	w, ok := ctx.Value(serverConnKey{}).(*Conn)
+	return w, ok
+}
+
+// updateClosedInfo keeps the current TCP info as ClosedInfo, since it can no longer be gathered
+// once the server closes the connection.
+func (w *Conn) updateClosedInfo() {
+	if w.reportStats == nil {
+		return
+	}
+	if info, _ := w.Snapshot(); info != nil {
+		w.Lock()
+		w.ClosedInfo = info
+		w.Unlock()
+	}
+}
+
+// reportServerClose reports the close of a connection that the http.Server already closed.
+func (w *Conn) reportServerClose() {
+	w.Lock()
+	w.ClosedAt = time.Now().UnixNano()
+	w.Unlock()
+	w.stopSampling()
+	w.inferCloseReason()
+	if w.reportStats != nil {
+		w.reportStats(w, Closed)
+	}
+}
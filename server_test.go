@@ -0,0 +1,77 @@
+package conniver
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+)
+
+func TestServerWithSockStats(t *testing.T) {
+	var fromHandler *Conn
+	srv := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fromHandler, _ = ServerConnFromContext(r.Context())
+		_, _ = io.WriteString(w, "hello")
+	}))
+
+	var (
+		mu        sync.Mutex
+		userState []http.ConnState
+		reports   []int
+		closed    = make(chan *Conn, 1)
+	)
+	srv.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		mu.Lock()
+		userState = append(userState, state)
+		mu.Unlock()
+	}
+	ServerWithSockStats(srv.Config, func(c *Conn, state int) {
+		mu.Lock()
+		reports = append(reports, state)
+		mu.Unlock()
+		if state == Closed {
+			closed <- c
+		}
+	})
+	srv.Start()
+	defer srv.Close()
+
+	client := srv.Client()
+	resp, err := client.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	_, _ = io.Copy(io.Discard, resp.Body)
+	resp.Body.Close()
+	client.CloseIdleConnections()
+
+	var c *Conn
+	select {
+	case c = <-closed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no close report")
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(reports) != 2 || reports[0] != Opened || reports[1] != Closed {
+		t.Errorf("reports = %v, want open then close", reports)
+	}
+	if len(userState) == 0 || userState[0] != http.StateNew || userState[len(userState)-1] != http.StateClosed {
+		t.Errorf("existing ConnState hook saw %v", userState)
+	}
+	if fromHandler != c {
+		t.Errorf("ServerConnFromContext returned %p, want the reported Conn %p", fromHandler, c)
+	}
+	if c.OpenedAt == 0 || c.ClosedAt < c.OpenedAt {
+		t.Errorf("unexpected timestamps: opened %d closed %d", c.OpenedAt, c.ClosedAt)
+	}
+	if tcpinfo.Supported() && (c.OpenedInfo == nil || c.ClosedInfo == nil) {
+		t.Errorf("missing TCP info: opened %v closed %v", c.OpenedInfo, c.ClosedInfo)
+	}
+}
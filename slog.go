@@ -0,0 +1,34 @@
+package conniver
+
+import "log/slog"
+
+// LogAttrs returns the connection report as grouped slog attributes, cheaper than ToMap for
+// structured logging. The "conn" group holds the byte counters and addresses, and the "tcp" group
+// holds the RTT, congestion window (in segments), and retransmissions from the most recent TCP
+// info, left out when none is available. Log them with slog.Logger.LogAttrs:
+//
+//	logger.LogAttrs(ctx, slog.LevelInfo, "connection closed", c.LogAttrs()...)
+func (w *Conn) LogAttrs() []slog.Attr {
+	info := w.latestInfo()
+
+	w.Lock()
+	attrs := []slog.Attr{slog.Group("conn",
+		slog.Int64("txBytes", w.TxBytes),
+		slog.Int64("rxBytes", w.RxBytes),
+		slog.String("localAddr", addrString(w.LocalAddr())),
+		slog.String("remoteAddr", addrString(w.RemoteAddr())),
+	)}
+	w.Unlock()
+
+	if info == nil {
+		return attrs
+	}
+	tcp := []any{
+		slog.Duration("rtt", info.RTT),
+		slog.Uint64("retrans", info.Retransmits),
+	}
+	if cwnd, ok := info.CongestionWindow(); ok {
+		tcp = append(tcp, slog.Uint64("cwnd", cwnd))
+	}
+	return append(attrs, slog.Group("tcp", tcp...))
+}
@@ -0,0 +1,63 @@
+package conniver
+
+import (
+	"context"
+	"log/slog"
+	"testing"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+)
+
+// captureHandler records the attributes of every record, flattening groups into dotted keys.
+type captureHandler struct {
+	attrs map[string]slog.Value
+}
+
+func (h *captureHandler) Enabled(context.Context, slog.Level) bool { return true }
+func (h *captureHandler) WithAttrs([]slog.Attr) slog.Handler       { return h }
+func (h *captureHandler) WithGroup(string) slog.Handler            { return h }
+
+func (h *captureHandler) Handle(_ context.Context, r slog.Record) error {
+	r.Attrs(func(a slog.Attr) bool {
+		h.add("", a)
+		return true
+	})
+	return nil
+}
+
+func (h *captureHandler) add(prefix string, a slog.Attr) {
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			h.add(prefix+a.Key+".", ga)
+		}
+		return
+	}
+	h.attrs[prefix+a.Key] = a.Value
+}
+
+func TestLogAttrs(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	client, _ := loopbackPair(t)
+	c := WrapConn(client, nil).(*Conn)
+	defer c.Close()
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	h := &captureHandler{attrs: map[string]slog.Value{}}
+	slog.New(h).LogAttrs(context.Background(), slog.LevelInfo, "report", c.LogAttrs()...)
+
+	for _, key := range []string{"conn.txBytes", "conn.rxBytes", "conn.localAddr", "conn.remoteAddr", "tcp.rtt", "tcp.retrans"} {
+		if _, ok := h.attrs[key]; !ok {
+			t.Errorf("missing attribute %q in %v", key, h.attrs)
+		}
+	}
+	if got := h.attrs["conn.txBytes"].Int64(); got != 5 {
+		t.Errorf("conn.txBytes = %d, want 5", got)
+	}
+	if got := h.attrs["conn.remoteAddr"].String(); got != client.RemoteAddr().String() {
+		t.Errorf("conn.remoteAddr = %q, want %q", got, client.RemoteAddr())
+	}
+}
@@ -0,0 +1,54 @@
+package conniver
+
+import (
+	"time"
+)
+
+// TxThroughput returns the send rate in bytes per second, measured from the first to the last
+// successful write. Zero is returned when fewer than two writes spanning a measurable
+// duration have occurred.
+func (w *Conn) TxThroughput() float64 {
+	w.Lock()
+	defer w.Unlock()
+	return bytesPerSecond(w.TxBytes, w.LastTxAt-w.FirstTxAt)
+}
+
+// RxThroughput returns the receive rate in bytes per second, measured from the first to the
+// last successful read. Zero is returned when fewer than two reads spanning a measurable
+// duration have occurred.
+func (w *Conn) RxThroughput() float64 {
+	w.Lock()
+	defer w.Unlock()
+	return bytesPerSecond(w.RxBytes, w.LastRxAt-w.FirstRxAt)
+}
+
+// Goodput returns the useful send rate in bytes per second over the lifetime of the connection,
+// ending at ClosedAt or now if the connection is still open. Retransmitted bytes reported by
+// the close-time TCP info are subtracted from the bytes sent when the platform provides them.
+func (w *Conn) Goodput() float64 {
+	w.Lock()
+	defer w.Unlock()
+
+	end := w.ClosedAt
+	if end == 0 {
+		end = time.Now().UnixNano()
+	}
+
+	good := w.TxBytes
+	if w.ClosedInfo != nil && w.ClosedInfo.Sys != nil {
+		if retrans, ok := w.ClosedInfo.Sys.RetransmittedBytes(); ok {
+			good -= int64(retrans)
+		}
+	}
+	if good < 0 {
+		good = 0
+	}
+	return bytesPerSecond(good, end-w.OpenedAt)
+}
+
+func bytesPerSecond(n int64, elapsed int64) float64 {
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(n) / time.Duration(elapsed).Seconds()
+}
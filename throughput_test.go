@@ -0,0 +1,57 @@
+package conniver
+
+import (
+	"io"
+	"testing"
+	"time"
+)
+
+func TestThroughput(t *testing.T) {
+	client, server := loopbackPair(t)
+	go func() { _, _ = io.Copy(server, server) }()
+
+	c := WrapConn(client, func(*Conn, int) {}).(*Conn)
+	defer c.Close()
+
+	// Send 10 x 10KB over ~100ms
+	buf := make([]byte, 10*1024)
+	for i := 0; i < 10; i++ {
+		if i > 0 {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if _, err := c.Write(buf); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+
+	// Expect roughly 100KB / 90ms ≈ 1.1MB/s; allow generous slack for slow machines
+	tx := c.TxThroughput()
+	if tx < 200_000 || tx > 2_000_000 {
+		t.Fatalf("unexpected tx throughput: %f", tx)
+	}
+
+	recv := make([]byte, len(buf))
+	for i := 0; i < 10; i++ {
+		if _, err := io.ReadFull(c, recv); err != nil {
+			t.Fatalf("read: %v", err)
+		}
+	}
+	if rx := c.RxThroughput(); rx <= 0 {
+		t.Fatalf("unexpected rx throughput: %f", rx)
+	}
+
+	goodput := c.Goodput()
+	if goodput <= 0 || goodput > tx {
+		t.Fatalf("unexpected goodput: %f (tx %f)", goodput, tx)
+	}
+}
+
+func TestThroughputZeroDuration(t *testing.T) {
+	c := &Conn{TxBytes: 100, FirstTxAt: 10, LastTxAt: 10}
+	if got := c.TxThroughput(); got != 0 {
+		t.Fatalf("expected zero throughput, got %f", got)
+	}
+	if got := c.RxThroughput(); got != 0 {
+		t.Fatalf("expected zero throughput, got %f", got)
+	}
+}
@@ -0,0 +1,27 @@
+package conniver
+
+import (
+	"crypto/tls"
+	"time"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+)
+
+// SnapshotAfterHandshake runs the TLS handshake on tc and gathers the TCP info of the underlying
+// socket as soon as it completes, returning it along with the time spent in the handshake. The
+// socket is found through tc.NetConn, which may itself be a *Conn. If the handshake has already
+// completed, the returned duration is close to zero. If the handshake fails, its error is returned
+// with the time spent and no info.
+func SnapshotAfterHandshake(tc *tls.Conn) (*tcpinfo.Info, time.Duration, error) {
+	start := time.Now()
+	err := tc.Handshake()
+	elapsed := time.Since(start)
+	if err != nil {
+		return nil, elapsed, err
+	}
+	sysInfo, err := tcpinfo.GetTCPInfoFromConn(tc.NetConn())
+	if sysInfo == nil {
+		return nil, elapsed, err
+	}
+	return sysInfo.ToInfo(), elapsed, nil
+}
@@ -0,0 +1,37 @@
+package conniver
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+)
+
+func TestSnapshotAfterHandshake(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	tc := tls.Client(WrapConn(conn, nil), &tls.Config{InsecureSkipVerify: true})
+	defer tc.Close()
+
+	info, elapsed, err := SnapshotAfterHandshake(tc)
+	if info == nil {
+		t.Fatalf("SnapshotAfterHandshake: %v", err)
+	}
+	if elapsed <= 0 {
+		t.Errorf("handshake time = %v, want > 0", elapsed)
+	}
+	if info.State != "ESTABLISHED" {
+		t.Errorf("unexpected state %q", info.State)
+	}
+}
@@ -0,0 +1,36 @@
+package conniver
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"time"
+)
+
+// NewTransport returns a clone of base whose DialContext wraps every new connection with
+// WrapConn using the given report function. The existing DialContext, TLS configuration,
+// and other settings of base are preserved. If base is nil, http.DefaultTransport is used.
+// The time spent in DialContext is recorded for ConnectLatency.
+// Connections created through DialTLSContext are not wrapped.
+func NewTransport(base *http.Transport, reportStatsFn ReportStatsFn) *http.Transport {
+	if base == nil {
+		base = http.DefaultTransport.(*http.Transport)
+	}
+	t := base.Clone()
+
+	dial := t.DialContext
+	if dial == nil {
+		d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+		dial = d.DialContext
+	}
+
+	t.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		start := time.Now()
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return WrapDialedConn(ctx, start, conn, reportStatsFn), nil
+	}
+	return t
+}
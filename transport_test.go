@@ -0,0 +1,88 @@
+package conniver
+
+import (
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNewTransport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello")
+	}))
+	defer srv.Close()
+
+	var mu sync.Mutex
+	var closed bool
+	var txBytes, rxBytes int64
+	tr := NewTransport(&http.Transport{}, func(c *Conn, state int) {
+		if state != Closed {
+			return
+		}
+		// The byte counts are only safe to read under the Conn's lock.
+		c.Lock()
+		tx, rx := c.TxBytes, c.RxBytes
+		c.Unlock()
+		mu.Lock()
+		closed, txBytes, rxBytes = true, tx, rx
+		mu.Unlock()
+	})
+	cl := &http.Client{Transport: tr}
+
+	resp, err := cl.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	if string(body) != "hello" {
+		t.Fatalf("unexpected body: %q", body)
+	}
+	cl.CloseIdleConnections()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !closed {
+		t.Fatal("expected a close report")
+	}
+	if txBytes == 0 || rxBytes == 0 {
+		t.Fatalf("expected non-zero byte counts, got tx=%d rx=%d", txBytes, rxBytes)
+	}
+}
+
+func TestNewTransportConnectLatency(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	// Slow down the dial so the latency is clearly above loopback noise.
+	const delay = 50 * time.Millisecond
+	base := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			time.Sleep(delay)
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}
+	latency := make(chan time.Duration, 1)
+	tr := NewTransport(base, func(c *Conn, state int) {
+		if state == Opened {
+			latency <- c.ConnectLatency()
+		}
+	})
+	cl := &http.Client{Transport: tr}
+	defer cl.CloseIdleConnections()
+
+	resp, err := cl.Get(srv.URL)
+	if err != nil {
+		t.Fatalf("get: %v", err)
+	}
+	_ = resp.Body.Close()
+
+	if got := <-latency; got < delay || got > 10*time.Second {
+		t.Fatalf("ConnectLatency = %v, want at least %v", got, delay)
+	}
+}
@@ -2,9 +2,12 @@ package conniver
 
 import (
 	"context"
+	"errors"
+	"io"
 	"net"
 	"strconv"
 	"sync"
+	"syscall"
 	"time"
 
 	"github.com/runZeroInc/conniver/pkg/tcpinfo"
@@ -13,35 +16,77 @@ import (
 const (
 	Opened = 0
 	Closed = 1
+	// Sampled is never reported to the callback; it selects the latest sample in Conn.Event.
+	Sampled = 2
 )
 
 var StateMap = map[int]string{
-	Opened: "open",
-	Closed: "close",
+	Opened:  "open",
+	Closed:  "close",
+	Sampled: "sample",
 }
 
+// Close reasons inferred by Close. CloseWithReason accepts these or any application-defined reason.
+const (
+	CloseReasonApplication = "application" // Closed by the application with nothing else to explain it
+	CloseReasonEOF         = "eof"         // The peer closed its side gracefully
+	CloseReasonTimeout     = "timeout"     // The last read or write hit a deadline
+	CloseReasonReset       = "reset"       // The peer reset the connection
+)
+
 type ReportStatsFn func(tic *Conn, state int)
 
+var (
+	ErrNotTCP      = tcpinfo.ErrNotTCP
+	ErrUnsupported = tcpinfo.ErrUnsupportedPlatform
+
+	// ErrNotConnected matches an InfoErr from a socket that was never connected; see Conn.Connected.
+	ErrNotConnected = tcpinfo.ENOTCONN
+
+	// getTCPInfoFromConn is replaced in tests to simulate platform errors.
+	getTCPInfoFromConn = tcpinfo.GetTCPInfoFromConn
+)
+
 type Conn struct {
 	net.Conn `json:"-"`
 	Context  context.Context `json:"-"`
 
-	reportStats     func(*Conn, int) `json:"-"`
-	OpenedAt        int64            `json:"openedAt,omitempty"`
-	ClosedAt        int64            `json:"closedAt,omitempty"`
-	FirstRxAt       int64            `json:"firstRxAt,omitempty"`
-	FirstTxAt       int64            `json:"firstTxAt,omitempty"`
-	LastRxAt        int64            `json:"lastRxAt,omitempty"`
-	LastTxAt        int64            `json:"lastTxAt,omitempty"`
-	TxBytes         int64            `json:"txBytes"`
-	RxBytes         int64            `json:"rxBytes"`
-	RxErr           error            `json:"rxErr,omitempty"`
-	TxErr           error            `json:"txErr,omitempty"`
-	InfoErr         error            `json:"infoErr,omitempty"`
-	Reconnects      int              `json:"reconnects,omitempty"`
-	OpenedInfo      *tcpinfo.Info    `json:"openedInfo,omitempty"`
-	ClosedInfo      *tcpinfo.Info    `json:"closedInfo,omitempty"`
+	reportStats     func(*Conn, int)    `json:"-"`
+	DialStartedAt   int64               `json:"dialStartedAt,omitempty"`
+	OpenedAt        int64               `json:"openedAt,omitempty"`
+	ClosedAt        int64               `json:"closedAt,omitempty"`
+	CloseReason     string              `json:"closeReason,omitempty"`
+	FirstRxAt       int64               `json:"firstRxAt,omitempty"`
+	FirstTxAt       int64               `json:"firstTxAt,omitempty"`
+	LastRxAt        int64               `json:"lastRxAt,omitempty"`
+	LastTxAt        int64               `json:"lastTxAt,omitempty"`
+	GotConnAt       int64               `json:"gotConnAt,omitempty"`           // Set by ClientTrace when an HTTP request got this connection
+	WroteRequestAt  int64               `json:"wroteRequestAt,omitempty"`      // Set by ClientTrace when the HTTP request was written
+	FirstRespByteAt int64               `json:"firstResponseByteAt,omitempty"` // Set by ClientTrace when the first response byte arrived
+	TxBytes         int64               `json:"txBytes"`
+	RxBytes         int64               `json:"rxBytes"`
+	ReadCalls       int64               `json:"readCalls"`
+	WriteCalls      int64               `json:"writeCalls"`
+	MinWrite        int                 `json:"minWrite,omitempty"`
+	MaxWrite        int                 `json:"maxWrite,omitempty"`
+	RxErr           error               `json:"rxErr,omitempty"`
+	TxErr           error               `json:"txErr,omitempty"`
+	InfoErr         error               `json:"infoErr,omitempty"`
+	Connected       bool                `json:"connected"`
+	Reconnects      int                 `json:"reconnects,omitempty"`
+	OpenedInfo      *tcpinfo.Info       `json:"openedInfo,omitempty"`
+	ClosedInfo      *tcpinfo.Info       `json:"closedInfo,omitempty"`
+	OpenedSockOpts  *tcpinfo.SocketOpts `json:"socketOptions,omitempty"` // Socket options read with the open report
 	supportsTCPInfo bool
+	samples         []*tcpinfo.Info
+	sampleRing      int // Capacity set by EnableSamplingRing, or zero to keep every sample
+	sampleHead      int // Index of the oldest sample once the ring is full
+	onStateChange   func(old, new string)
+	sawEOF          bool
+	lastIOTimedOut  bool
+	done            chan struct{}
+	doneOnce        sync.Once
+	samplers        sync.WaitGroup // Running StartSampling goroutines, waited for by Reset
 	sync.Mutex
 }
 
@@ -57,16 +102,36 @@ func WrapConn(ncon net.Conn, reportStatsFn ReportStatsFn) net.Conn {
 // and returns the wrapped connection. Reads and writes are tracked and the final
 // report is triggered on Close. Separate tcpinfo stats are gathered on open and
 // close events.
+//
+// The context is stored on the Conn as metadata for the report callback (request IDs,
+// trace spans, etc). It is never used to cancel or time out Read, Write, or Close; use
+// deadlines on the underlying connection for that.
 func WrapConnWithContext(ctx context.Context, ncon net.Conn, reportStatsFn ReportStatsFn) net.Conn {
-	w := &Conn{
+	w := newConn(ctx, ncon, reportStatsFn)
+	w.gatherAndReport(Opened)
+	return w
+}
+
+// WrapDialedConn is like WrapConnWithContext, but also records when dialing started so that
+// ConnectLatency can report how long it took to establish the connection.
+func WrapDialedConn(ctx context.Context, dialStart time.Time, ncon net.Conn, reportStatsFn ReportStatsFn) net.Conn {
+	w := newConn(ctx, ncon, reportStatsFn)
+	w.DialStartedAt = dialStart.UnixNano()
+	w.gatherAndReport(Opened)
+	return w
+}
+
+// newConn wraps ncon without triggering the open report.
+func newConn(ctx context.Context, ncon net.Conn, reportStatsFn ReportStatsFn) *Conn {
+	return &Conn{
 		Conn:            ncon,
 		reportStats:     reportStatsFn,
 		OpenedAt:        time.Now().UnixNano(),
 		supportsTCPInfo: tcpinfo.Supported(),
+		Connected:       true,
 		Context:         ctx,
+		done:            make(chan struct{}),
 	}
-	w.gatherAndReport(Opened)
-	return w
 }
 
 func (w *Conn) gatherAndReport(state int) {
@@ -86,41 +151,46 @@ func (w *Conn) gatherAndReport(state int) {
 	}
 
 	// Write the report at the end regardless of success or failure
-	defer w.reportStats(w, state)
+	defer func() {
+		if state == Closed {
+			w.inferCloseReason()
+		}
+		w.reportStats(w, state)
+	}()
 
-	// Skipped platform or previously errored
-	if !w.supportsTCPInfo || w.InfoErr != nil {
+	// Skipped platform or previously failed to return any info
+	if !w.supportsTCPInfo || (w.InfoErr != nil && w.OpenedInfo == nil) {
 		return
 	}
 
-	tcpConn, ok := w.Conn.(*net.TCPConn)
-	if !ok {
+	sysInfo, tcpErr := w.getSysInfo()
+	if errors.Is(tcpErr, ErrNotTCP) {
 		return
 	}
 
-	rawConn, err := tcpConn.SyscallConn()
-	if err != nil {
-		return
+	// Read the socket options once here, so ToMap does not make syscalls under the lock
+	var sockOpts *tcpinfo.SocketOpts
+	if state == Opened {
+		if opts, err := w.SocketOptions(); err == nil {
+			sockOpts = &opts
+		}
 	}
 
-	var sysInfo *tcpinfo.SysInfo
-	var tcpErr error
-	err = rawConn.Control(func(fd uintptr) {
-		sysInfo, tcpErr = tcpinfo.GetTCPInfo(fd)
-	})
-
 	// Lock the struct to store the gathered info
 	w.Lock()
 	defer w.Unlock()
 
-	if err != nil {
-		w.InfoErr = err
-		return
-	}
-
+	// GetTCPInfo may return partial results along with an error (such as
+	// missing congestion control details), so keep whatever was returned.
 	if tcpErr != nil {
 		w.InfoErr = tcpErr
-		return
+	}
+	// A handshake that failed, or a socket that was never connected, cannot report TCP info
+	if state == Opened && errors.Is(tcpErr, ErrNotConnected) {
+		w.Connected = false
+	}
+	if sockOpts != nil {
+		w.OpenedSockOpts = sockOpts
 	}
 
 	if sysInfo == nil {
@@ -135,6 +205,177 @@ func (w *Conn) gatherAndReport(state int) {
 	w.ClosedInfo = sysInfo.ToInfo()
 }
 
+// getSysInfo gathers the platform-specific TCP info from the underlying connection.
+func (w *Conn) getSysInfo() (*tcpinfo.SysInfo, error) {
+	return getTCPInfoFromConn(w.Conn)
+}
+
+// Ctx returns the context the connection was wrapped with, or context.Background if there is none.
+// Report callbacks can use it to recover request IDs or trace spans, for example with
+// trace.SpanContextFromContext(c.Ctx()).
+func (w *Conn) Ctx() context.Context {
+	if w.Context == nil {
+		return context.Background()
+	}
+	return w.Context
+}
+
+// NetConn returns the wrapped connection, allowing GetTCPInfoFromConn and similar helpers to
+// reach the underlying socket.
+func (w *Conn) NetConn() net.Conn {
+	return w.Conn
+}
+
+// Snapshot gathers the current TCP info from the underlying connection without closing it.
+// The OpenedInfo and ClosedInfo fields are not modified.
+func (w *Conn) Snapshot() (*tcpinfo.Info, error) {
+	if !w.supportsTCPInfo {
+		return nil, ErrUnsupported
+	}
+	sysInfo, err := w.getSysInfo()
+	if sysInfo == nil {
+		return nil, err
+	}
+	return sysInfo.ToInfo(), nil
+}
+
+// SnapshotContext is like Snapshot, but returns ctx.Err() if ctx is done before the snapshot is taken.
+// The getsockopt(2) call itself never blocks; this only guards against waiting for the runtime to
+// serialize the Control callback with other operations on the socket. An abandoned snapshot still
+// completes in the background and is discarded.
+func (w *Conn) SnapshotContext(ctx context.Context) (*tcpinfo.Info, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	type result struct {
+		info *tcpinfo.Info
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		info, err := w.Snapshot()
+		done <- result{info, err}
+	}()
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case r := <-done:
+		return r.info, r.err
+	}
+}
+
+// SetCongestionControl sets the TCP congestion control algorithm (such as "reno" or "bbr") on the
+// underlying connection. This is only supported on Linux; see tcpinfo.SetTCPCongestionAlgorithm for
+// the errors returned when the algorithm is not permitted or not available.
+func (w *Conn) SetCongestionControl(algo string) error {
+	return w.control(func(fd uintptr) error {
+		return tcpinfo.SetTCPCongestionAlgorithm(fd, algo)
+	})
+}
+
+// SetMaxPacingRate limits the rate, in bytes per second, at which the kernel paces outgoing data on
+// the underlying connection. This is only supported on Linux; see tcpinfo.SetMaxPacingRate for how
+// large rates are clamped.
+func (w *Conn) SetMaxPacingRate(bytesPerSec uint64) error {
+	return w.control(func(fd uintptr) error {
+		return tcpinfo.SetMaxPacingRate(fd, bytesPerSec)
+	})
+}
+
+// SetNotSentLowat sets TCP_NOTSENT_LOWAT on the underlying connection, bounding the unsent data
+// queued in the kernel. This is only supported on Linux; see tcpinfo.SetNotSentLowat.
+func (w *Conn) SetNotSentLowat(bytes uint32) error {
+	return w.control(func(fd uintptr) error {
+		return tcpinfo.SetNotSentLowat(fd, bytes)
+	})
+}
+
+// SetKeepAlive configures TCP keepalive on the underlying connection. See tcpinfo.SetKeepAlive for
+// how zero values are handled and which settings each platform supports.
+func (w *Conn) SetKeepAlive(enable bool, idle, interval time.Duration, count int) error {
+	return w.control(func(fd uintptr) error {
+		return tcpinfo.SetKeepAlive(fd, enable, idle, interval, count)
+	})
+}
+
+// SocketOptions reads the socket-level options in effect on the underlying connection, such as
+// TCP_NODELAY and the send and receive buffer sizes. See tcpinfo.GetSocketOpts.
+func (w *Conn) SocketOptions() (tcpinfo.SocketOpts, error) {
+	var opts tcpinfo.SocketOpts
+	err := w.control(func(fd uintptr) error {
+		var err error
+		opts, err = tcpinfo.GetSocketOpts(fd)
+		return err
+	})
+	return opts, err
+}
+
+// control runs fn with the file descriptor of the underlying TCP connection, unwrapping it the same
+// way as the TCP info getters do. See tcpinfo.ControlConn.
+func (w *Conn) control(fn func(fd uintptr) error) error {
+	return tcpinfo.ControlConn(w.Conn, fn)
+}
+
+// latestInfo returns a fresh snapshot of the TCP info, falling back to the most recent sample,
+// ClosedInfo, or OpenedInfo when a snapshot cannot be taken, such as after Close.
+func (w *Conn) latestInfo() *tcpinfo.Info {
+	if info, _ := w.Snapshot(); info != nil {
+		return info
+	}
+	w.Lock()
+	defer w.Unlock()
+	if info := w.latestSample(); info != nil {
+		return info
+	}
+	if w.ClosedInfo != nil {
+		return w.ClosedInfo
+	}
+	return w.OpenedInfo
+}
+
+// RTT returns the current smoothed round-trip time, or false if it is not available.
+func (w *Conn) RTT() (time.Duration, bool) {
+	info := w.latestInfo()
+	if info == nil || info.RTT == 0 {
+		return 0, false
+	}
+	return info.RTT, true
+}
+
+// RTTVar returns the current round-trip time variation, or false if it is not available.
+func (w *Conn) RTTVar() (time.Duration, bool) {
+	info := w.latestInfo()
+	if info == nil || info.RTTVar == 0 {
+		return 0, false
+	}
+	return info.RTTVar, true
+}
+
+// CongestionWindow returns the current sender congestion window in segments, or false if it is not available.
+func (w *Conn) CongestionWindow() (uint64, bool) {
+	info := w.latestInfo()
+	if info == nil {
+		return 0, false
+	}
+	return info.CongestionWindow()
+}
+
+// ConnectLatency returns how long it took to establish the connection, from the start of the dial
+// to when it was wrapped. It returns zero when the dial start is unknown, which is the case unless
+// the connection came from WrapDialedConn, NewTransport, or DialWithSockStats.
+func (w *Conn) ConnectLatency() time.Duration {
+	w.Lock()
+	defer w.Unlock()
+	return w.connectLatency()
+}
+
+func (w *Conn) connectLatency() time.Duration {
+	if w.DialStartedAt == 0 {
+		return 0
+	}
+	return time.Duration(w.OpenedAt - w.DialStartedAt)
+}
+
 // SetReconnects stores the number of additional connection attempts that were needed to open this connection.
 // This is managed externally by the caller, but reported in the final stats.
 func (w *Conn) SetReconnects(reconnects int) {
@@ -143,21 +384,113 @@ func (w *Conn) SetReconnects(reconnects int) {
 	w.Reconnects = reconnects
 }
 
-// Close invokes the reportWrapper with a close event before closing the connection.
+// Reset reuses w for a new connection to the same logical stream, such as after reconnecting a dropped
+// connection. The embedded net.Conn is replaced with ncon, Reconnects is incremented, the per-connection
+// timestamps, byte and call counters, errors, samples, OpenedInfo, and ClosedInfo are cleared, and an Open
+// report is triggered. The report function, context, state change callback, and sampling ring capacity are kept,
+// while CloseReason is cleared.
+//
+// The previous connection is neither closed nor reported; call Close first for a final report. Any sampler
+// started with StartSampling is stopped, and Reset waits for it to exit, so Reset must not be called from
+// an OnStateChange callback. Samplers must be restarted afterwards. The caller must not Read, Write, or
+// Close w while Reset is running.
+func (w *Conn) Reset(ncon net.Conn) {
+	// Wait for the samplers so that none of them adds a sample of the old connection after the
+	// samples are cleared.
+	w.stopSampling()
+	w.samplers.Wait()
+
+	w.Lock()
+	w.Conn = ncon
+	w.Reconnects++
+	w.DialStartedAt = 0
+	w.OpenedAt = time.Now().UnixNano()
+	w.ClosedAt = 0
+	w.CloseReason = ""
+	w.sawEOF, w.lastIOTimedOut = false, false
+	w.FirstRxAt, w.FirstTxAt, w.LastRxAt, w.LastTxAt = 0, 0, 0, 0
+	w.GotConnAt, w.WroteRequestAt, w.FirstRespByteAt = 0, 0, 0
+	w.TxBytes, w.RxBytes = 0, 0
+	w.ReadCalls, w.WriteCalls = 0, 0
+	w.MinWrite, w.MaxWrite = 0, 0
+	w.RxErr, w.TxErr, w.InfoErr = nil, nil, nil
+	w.Connected = true
+	w.OpenedInfo, w.ClosedInfo = nil, nil
+	w.OpenedSockOpts = nil
+	w.samples, w.sampleHead = nil, 0
+	w.done = make(chan struct{})
+	w.doneOnce = sync.Once{}
+	w.Unlock()
+
+	// The gatherAndReport function must not be called while holding the lock.
+	w.gatherAndReport(Opened)
+}
+
+// Close invokes the reportWrapper with a close event before closing the connection. CloseReason
+// is inferred from what the connection saw, as described by CloseWithReason.
 func (w *Conn) Close() error {
+	return w.CloseWithReason("")
+}
+
+// CloseWithReason is like Close, but records reason as CloseReason for the close report. An empty
+// reason is inferred instead: CloseReasonReset if a read or write failed with ECONNRESET or the
+// kernel already moved the socket to CLOSE (as it does after an RST), CloseReasonEOF if a read
+// saw EOF, CloseReasonTimeout if the last read or write hit a deadline, and CloseReasonApplication
+// otherwise.
+func (w *Conn) CloseWithReason(reason string) error {
 	w.Lock()
 	w.ClosedAt = time.Now().UnixNano()
+	if reason != "" {
+		w.CloseReason = reason
+	}
 	w.Unlock()
+	w.stopSampling()
 	// The gatherAndReport function must not be called while holding the lock.
 	w.gatherAndReport(Closed)
+	// The report already inferred the reason, unless there is no report function.
+	w.inferCloseReason()
 	return w.Conn.Close()
 }
 
+// inferCloseReason sets CloseReason from the errors and TCP state seen so far, unless it is already set.
+func (w *Conn) inferCloseReason() {
+	w.Lock()
+	defer w.Unlock()
+	if w.CloseReason != "" {
+		return
+	}
+	switch {
+	case errors.Is(w.RxErr, syscall.ECONNRESET) || errors.Is(w.TxErr, syscall.ECONNRESET) ||
+		(w.ClosedInfo != nil && w.ClosedInfo.State == "CLOSE"):
+		w.CloseReason = CloseReasonReset
+	case w.sawEOF:
+		w.CloseReason = CloseReasonEOF
+	case w.lastIOTimedOut:
+		w.CloseReason = CloseReasonTimeout
+	default:
+		w.CloseReason = CloseReasonApplication
+	}
+}
+
 // Read wraps the underlying Read method and tracks the bytes received
 func (w *Conn) Read(b []byte) (int, error) {
 	n, err := w.Conn.Read(b)
+	w.recordRead(n, err)
+	return n, err
+}
+
+// Write wraps the underlying Write method and tracks the bytes sent
+func (w *Conn) Write(b []byte) (int, error) {
+	n, err := w.Conn.Write(b)
+	w.recordWrite(n, err)
+	return n, err
+}
+
+// recordRead updates the receive stats after a read of n bytes.
+func (w *Conn) recordRead(n int, err error) {
 	w.Lock()
 	defer w.Unlock()
+	w.ReadCalls++
 	if err == nil && n > 0 {
 		ts := time.Now().UnixNano()
 		if w.FirstRxAt == 0 {
@@ -168,17 +501,32 @@ func (w *Conn) Read(b []byte) (int, error) {
 		}
 	}
 	w.RxBytes += int64(n)
-	if err, ok := err.(net.Error); ok && !err.Timeout() {
-		w.RxErr = err
+	if n > 0 {
+		w.lastIOTimedOut = false
+	}
+	if errors.Is(err, io.EOF) {
+		w.sawEOF = true
+	}
+	if err, ok := err.(net.Error); ok {
+		if err.Timeout() {
+			w.lastIOTimedOut = true
+		} else {
+			w.RxErr = err
+		}
 	}
-	return n, err
 }
 
-// Write wraps the underlying Write method and tracks the bytes sent
-func (w *Conn) Write(b []byte) (int, error) {
-	n, err := w.Conn.Write(b)
+// recordWrite updates the send stats after a write of n bytes.
+func (w *Conn) recordWrite(n int, err error) {
 	w.Lock()
 	defer w.Unlock()
+	w.WriteCalls++
+	if n > 0 {
+		if w.MinWrite == 0 || n < w.MinWrite {
+			w.MinWrite = n
+		}
+		w.MaxWrite = max(w.MaxWrite, n)
+	}
 	if err == nil && n > 0 {
 		ts := time.Now().UnixNano()
 		if w.FirstTxAt == 0 {
@@ -189,13 +537,37 @@ func (w *Conn) Write(b []byte) (int, error) {
 		}
 	}
 	w.TxBytes += int64(n)
-	w.TxErr = err
-	if err, ok := err.(net.Error); ok && !err.Timeout() {
+	if n > 0 {
+		w.lastIOTimedOut = false
+	}
+	// Like reads, timeouts are expected with deadlines and are not recorded
+	if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+		w.lastIOTimedOut = true
+		return
+	}
+	if err != nil {
 		w.TxErr = err
 	}
-	return n, err
 }
 
+// BytesSent returns the number of bytes written so far. Unlike reading TxBytes directly,
+// it is safe to call while other goroutines are reading from or writing to the connection.
+func (w *Conn) BytesSent() int64 {
+	w.Lock()
+	defer w.Unlock()
+	return w.TxBytes
+}
+
+// BytesReceived returns the number of bytes read so far. Unlike reading RxBytes directly,
+// it is safe to call while other goroutines are reading from or writing to the connection.
+func (w *Conn) BytesReceived() int64 {
+	w.Lock()
+	defer w.Unlock()
+	return w.RxBytes
+}
+
+// Warnings returns a summary of connection problems, including reconnects and any
+// warnings reported by the platform-specific TCP info at open and close.
 func (w *Conn) Warnings() []string {
 	w.Lock()
 	defer w.Unlock()
@@ -214,11 +586,24 @@ func (w *Conn) warnings() []string {
 		if info.Retransmits > 0 {
 			warns = append(warns, "retransmits="+strconv.FormatInt(int64(info.Retransmits), 10))
 		}
-		warns = append(warns, info.Sys.Warnings()...)
+		if info.Sys != nil {
+			warns = append(warns, info.Sys.Warnings()...)
+		}
 	}
 	return warns
 }
 
+// InfoDelta returns how the TCP info changed between OpenedInfo and ClosedInfo, with counters
+// reported as the difference and gauges as their final value. See tcpinfo.InfoDelta.
+func (w *Conn) InfoDelta() map[string]any {
+	w.Lock()
+	defer w.Unlock()
+	return tcpinfo.InfoDelta(w.OpenedInfo, w.ClosedInfo)
+}
+
+// ToMap returns the connection stats as a map suitable for structured logging.
+// Error fields are only included when set, and socket options when they were read with the open
+// report.
 func (w *Conn) ToMap() map[string]any {
 	w.Lock()
 	defer w.Unlock()
@@ -231,13 +616,25 @@ func (w *Conn) ToMap() map[string]any {
 		"lastTxAt":   w.LastTxAt,
 		"txBytes":    w.TxBytes,
 		"rxBytes":    w.RxBytes,
+		"readCalls":  w.ReadCalls,
+		"writeCalls": w.WriteCalls,
+		"minWrite":   w.MinWrite,
+		"maxWrite":   w.MaxWrite,
 		"reconnects": w.Reconnects,
-		"localAddr":  w.LocalAddr().String(),
-		"remoteAddr": w.RemoteAddr().String(),
+		"connected":  w.Connected,
+		"localAddr":  addrString(w.LocalAddr()),
+		"remoteAddr": addrString(w.RemoteAddr()),
 		"warnings":   w.warnings(),
 	}
-	if w.RxErr != nil {
-		fset["rxErr"] = w.RxErr.Error()
+	if w.DialStartedAt != 0 {
+		fset["dialStartedAt"] = w.DialStartedAt
+		fset["connectLatency"] = w.connectLatency()
+	}
+	if w.FirstRespByteAt != 0 {
+		fset["ttfb"] = w.ttfb()
+	}
+	if w.CloseReason != "" {
+		fset["closeReason"] = w.CloseReason
 	}
 	if w.RxErr != nil {
 		fset["rxErr"] = w.RxErr.Error()
@@ -248,6 +645,9 @@ func (w *Conn) ToMap() map[string]any {
 	if w.InfoErr != nil {
 		fset["infoErr"] = w.InfoErr.Error()
 	}
+	if w.OpenedSockOpts != nil {
+		fset["socketOptions"] = w.OpenedSockOpts.ToMap()
+	}
 	if w.OpenedInfo != nil {
 		fset["openedInfo"] = w.OpenedInfo.ToMap()
 	}
@@ -256,3 +656,12 @@ func (w *Conn) ToMap() map[string]any {
 	}
 	return fset
 }
+
+// addrString returns the string form of addr, or an empty string for unconnected packet sockets
+// that have no remote address.
+func addrString(addr net.Addr) string {
+	if addr == nil {
+		return ""
+	}
+	return addr.String()
+}
@@ -0,0 +1,663 @@
+package conniver
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"runtime"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/runZeroInc/conniver/pkg/tcpinfo"
+)
+
+// loopbackPair returns a connected client and server TCP connection over the loopback interface.
+func loopbackPair(t *testing.T) (*net.TCPConn, *net.TCPConn) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	defer ln.Close()
+
+	accepted := make(chan net.Conn, 1)
+	go func() {
+		c, err := ln.Accept()
+		if err != nil {
+			accepted <- nil
+			return
+		}
+		accepted <- c
+	}()
+
+	client, err := net.Dial("tcp", ln.Addr().String())
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	server := <-accepted
+	if server == nil {
+		t.Fatal("accept failed")
+	}
+	t.Cleanup(func() {
+		_ = client.Close()
+		_ = server.Close()
+	})
+	return client.(*net.TCPConn), server.(*net.TCPConn)
+}
+
+func TestCloseGathersClosedInfo(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	client, _ := loopbackPair(t)
+
+	reports := map[int]int{}
+	c := WrapConn(client, func(c *Conn, state int) {
+		reports[state]++
+	}).(*Conn)
+
+	if c.OpenedInfo == nil {
+		t.Fatalf("expected OpenedInfo to be gathered, infoErr=%v", c.InfoErr)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if c.ClosedInfo == nil {
+		t.Fatalf("expected ClosedInfo to be gathered, infoErr=%v", c.InfoErr)
+	}
+
+	// A second close must not trigger a second report
+	_ = c.Close()
+	if reports[Opened] != 1 || reports[Closed] != 1 {
+		t.Fatalf("unexpected report counts: %v", reports)
+	}
+}
+
+func TestCloseReason(t *testing.T) {
+	closeReason := func(t *testing.T, c net.Conn, close func(*Conn) error) (reported string) {
+		t.Helper()
+		w := WrapConn(c, func(w *Conn, state int) {
+			if state == Closed {
+				reported = w.CloseReason
+			}
+		}).(*Conn)
+		if close != nil {
+			_ = close(w)
+		} else {
+			// Reading exposes how the peer closed its side before the application closes.
+			_, _ = w.Read(make([]byte, 1))
+			_ = w.Close()
+		}
+		if reported != w.CloseReason || w.ToMap()["closeReason"] != reported {
+			t.Errorf("reported %q, but CloseReason = %q and ToMap has %v", reported, w.CloseReason, w.ToMap()["closeReason"])
+		}
+		return reported
+	}
+
+	t.Run("application", func(t *testing.T) {
+		client, _ := loopbackPair(t)
+		if got := closeReason(t, client, (*Conn).Close); got != CloseReasonApplication {
+			t.Errorf("CloseReason = %q, want %q", got, CloseReasonApplication)
+		}
+	})
+	t.Run("explicit", func(t *testing.T) {
+		client, _ := loopbackPair(t)
+		got := closeReason(t, client, func(w *Conn) error { return w.CloseWithReason("shutdown") })
+		if got != "shutdown" {
+			t.Errorf("CloseReason = %q, want shutdown", got)
+		}
+	})
+	t.Run("eof", func(t *testing.T) {
+		client, server := loopbackPair(t)
+		_ = server.Close()
+		if got := closeReason(t, client, nil); got != CloseReasonEOF {
+			t.Errorf("CloseReason = %q, want %q", got, CloseReasonEOF)
+		}
+	})
+	t.Run("timeout", func(t *testing.T) {
+		client, _ := loopbackPair(t)
+		_ = client.SetReadDeadline(time.Now().Add(10 * time.Millisecond))
+		if got := closeReason(t, client, nil); got != CloseReasonTimeout {
+			t.Errorf("CloseReason = %q, want %q", got, CloseReasonTimeout)
+		}
+	})
+	t.Run("reset", func(t *testing.T) {
+		client, server := loopbackPair(t)
+		// A zero linger makes close send an RST instead of a FIN.
+		if err := server.SetLinger(0); err != nil {
+			t.Fatalf("SetLinger: %v", err)
+		}
+		_ = server.Close()
+		if got := closeReason(t, client, nil); got != CloseReasonReset {
+			t.Errorf("CloseReason = %q, want %q", got, CloseReasonReset)
+		}
+	})
+}
+
+func TestWrapConnWithContextStoresContext(t *testing.T) {
+	client, _ := loopbackPair(t)
+
+	type ctxKey struct{}
+	ctx, cancel := context.WithCancel(context.WithValue(context.Background(), ctxKey{}, "req-1"))
+	c := WrapConnWithContext(ctx, client, func(*Conn, int) {}).(*Conn)
+	defer c.Close()
+
+	if got := c.Context.Value(ctxKey{}); got != "req-1" {
+		t.Fatalf("unexpected context value: %v", got)
+	}
+
+	// Cancelling the context must not affect I/O on the connection
+	cancel()
+	if _, err := c.Write([]byte("ping")); err != nil {
+		t.Fatalf("write after cancel: %v", err)
+	}
+}
+
+func TestCtxInReport(t *testing.T) {
+	client, _ := loopbackPair(t)
+
+	type ctxKey struct{}
+	ctx := context.WithValue(context.Background(), ctxKey{}, "req-1")
+	seen := map[int]any{}
+	c := WrapConnWithContext(ctx, client, func(c *Conn, state int) {
+		seen[state] = c.Ctx().Value(ctxKey{})
+	}).(*Conn)
+	if err := c.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+	if seen[Opened] != "req-1" || seen[Closed] != "req-1" {
+		t.Errorf("context values seen by the report callback = %v, want req-1 for open and close", seen)
+	}
+
+	if (&Conn{}).Ctx() == nil {
+		t.Errorf("Ctx() without a context = nil, want context.Background()")
+	}
+}
+
+func TestConnectedNotConnected(t *testing.T) {
+	orig := getTCPInfoFromConn
+	t.Cleanup(func() { getTCPInfoFromConn = orig })
+	getTCPInfoFromConn = func(net.Conn) (*tcpinfo.SysInfo, error) {
+		return nil, fmt.Errorf("tcp_info: getsockopt(TCP_INFO): %w", syscall.ENOTCONN)
+	}
+
+	client, server := net.Pipe()
+	defer server.Close()
+	c := &Conn{Conn: client, reportStats: func(*Conn, int) {}, supportsTCPInfo: true, Connected: true}
+	c.gatherAndReport(Opened)
+	defer c.Close()
+
+	if c.Connected {
+		t.Errorf("Connected = true after ENOTCONN on open")
+	}
+	if !errors.Is(c.InfoErr, ErrNotConnected) {
+		t.Errorf("InfoErr = %v, want ErrNotConnected", c.InfoErr)
+	}
+	if got := c.ToMap()["connected"]; got != false {
+		t.Errorf(`ToMap()["connected"] = %v, want false`, got)
+	}
+}
+
+func TestConnectedEstablished(t *testing.T) {
+	client, _ := loopbackPair(t)
+	c := WrapConn(client, func(*Conn, int) {}).(*Conn)
+	defer c.Close()
+
+	if !c.Connected {
+		t.Errorf("Connected = false for an established connection (InfoErr %v)", c.InfoErr)
+	}
+	if got := c.ToMap()["connected"]; got != true {
+		t.Errorf(`ToMap()["connected"] = %v, want true`, got)
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	client, _ := loopbackPair(t)
+	c := WrapConn(client, func(*Conn, int) {}).(*Conn)
+	defer c.Close()
+
+	opened := c.OpenedInfo
+	info, err := c.Snapshot()
+	if err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if info == nil || info.State != "ESTABLISHED" {
+		t.Fatalf("unexpected snapshot: %#v", info)
+	}
+	if c.OpenedInfo != opened || c.ClosedInfo != nil {
+		t.Fatal("snapshot must not modify OpenedInfo or ClosedInfo")
+	}
+}
+
+func TestSnapshotContext(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	client, _ := loopbackPair(t)
+	c := WrapConn(client, nil).(*Conn)
+	defer c.Close()
+
+	info, err := c.SnapshotContext(context.Background())
+	if err != nil || info == nil || info.State != "ESTABLISHED" {
+		t.Fatalf("SnapshotContext() = %v, %v, want an ESTABLISHED snapshot", info, err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if info, err := c.SnapshotContext(ctx); info != nil || !errors.Is(err, context.Canceled) {
+		t.Fatalf("SnapshotContext() with a cancelled context = %v, %v, want context.Canceled", info, err)
+	}
+}
+
+func TestSetCongestionControl(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("setting congestion control is only supported on Linux")
+	}
+	client, _ := loopbackPair(t)
+	c := WrapConn(client, nil).(*Conn)
+	defer c.Close()
+
+	if err := c.SetCongestionControl("reno"); err != nil {
+		t.Fatalf("SetCongestionControl: %v", err)
+	}
+	info, err := c.Snapshot()
+	if info == nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+	if got := info.Sys.ToMap()["ccAlgorithm"]; got != "reno" {
+		t.Fatalf("ccAlgorithm = %v, want reno", got)
+	}
+}
+
+func TestWrapConnTLS(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	tlsConn, err := tls.Dial("tcp", srv.Listener.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	// Wrap twice to check that both the TLS and conniver layers are unwrapped.
+	inner := WrapConn(tlsConn, func(*Conn, int) {})
+	c := WrapConn(inner, func(*Conn, int) {}).(*Conn)
+	defer c.Close()
+
+	if c.OpenedInfo == nil {
+		t.Fatalf("expected OpenedInfo for a TLS connection, InfoErr=%v", c.InfoErr)
+	}
+	if c.OpenedInfo.State != "ESTABLISHED" {
+		t.Fatalf("unexpected state %q", c.OpenedInfo.State)
+	}
+}
+
+func TestSnapshotNotTCP(t *testing.T) {
+	client, server := net.Pipe()
+	defer server.Close()
+	c := WrapConn(client, func(*Conn, int) {}).(*Conn)
+	defer c.Close()
+
+	if _, err := c.Snapshot(); !errors.Is(err, ErrNotTCP) && !errors.Is(err, ErrUnsupported) {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestErrUnsupportedMatchesTCPInfo(t *testing.T) {
+	c := &Conn{Conn: &net.TCPConn{}}
+	if _, err := c.Snapshot(); !errors.Is(err, tcpinfo.ErrUnsupportedPlatform) {
+		t.Fatalf("expected tcpinfo.ErrUnsupportedPlatform, got %v", err)
+	}
+}
+
+func TestConcurrentReadWrite(t *testing.T) {
+	client, server := loopbackPair(t)
+	go func() { _, _ = io.Copy(server, server) }()
+
+	c := WrapConn(client, func(*Conn, int) {}).(*Conn)
+	defer c.Close()
+
+	const total = 64 * 1024
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 512)
+		for sent := 0; sent < total; sent += len(buf) {
+			if _, err := c.Write(buf); err != nil {
+				t.Errorf("write: %v", err)
+				return
+			}
+			_ = c.BytesSent()
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		buf := make([]byte, 700)
+		for recv := 0; recv < total; {
+			n, err := c.Read(buf)
+			if err != nil {
+				t.Errorf("read: %v", err)
+				return
+			}
+			recv += n
+			_ = c.BytesReceived()
+		}
+	}()
+	wg.Wait()
+
+	if got := c.BytesSent(); got != total {
+		t.Fatalf("unexpected bytes sent: %d", got)
+	}
+	if got := c.BytesReceived(); got != total {
+		t.Fatalf("unexpected bytes received: %d", got)
+	}
+}
+
+func TestToMap(t *testing.T) {
+	client, _ := loopbackPair(t)
+	c := WrapConn(client, func(*Conn, int) {}).(*Conn)
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	_ = c.Close()
+
+	m := c.ToMap()
+	for _, k := range []string{"openedAt", "closedAt", "firstTxAt", "txBytes", "rxBytes", "reconnects", "localAddr", "remoteAddr"} {
+		if _, ok := m[k]; !ok {
+			t.Errorf("missing key %q", k)
+		}
+	}
+	for _, k := range []string{"rxErr", "txErr", "connectLatency"} {
+		if _, ok := m[k]; ok {
+			t.Errorf("unexpected key %q", k)
+		}
+	}
+	if m["txBytes"] != int64(5) {
+		t.Errorf("unexpected txBytes: %v", m["txBytes"])
+	}
+	if m["remoteAddr"] != client.RemoteAddr().String() {
+		t.Errorf("unexpected remoteAddr: %v", m["remoteAddr"])
+	}
+	if tcpinfo.Supported() {
+		if _, ok := m["openedInfo"].(map[string]any); !ok {
+			t.Errorf("missing openedInfo map")
+		}
+		if _, ok := m["closedInfo"].(map[string]any); !ok {
+			t.Errorf("missing closedInfo map")
+		}
+	}
+}
+
+func TestSocketOptions(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("socket options are not supported on this platform")
+	}
+	client, _ := loopbackPair(t)
+	if err := client.SetNoDelay(true); err != nil {
+		t.Fatalf("SetNoDelay: %v", err)
+	}
+	c := WrapConn(client, func(*Conn, int) {}).(*Conn)
+	defer c.Close()
+
+	for _, noDelay := range []bool{false, true} {
+		if err := client.SetNoDelay(noDelay); err != nil {
+			t.Fatalf("SetNoDelay(%v): %v", noDelay, err)
+		}
+		opts, err := c.SocketOptions()
+		if err != nil {
+			t.Fatalf("SocketOptions: %v", err)
+		}
+		if opts.NoDelay != noDelay {
+			t.Errorf("NoDelay = %v, want %v", opts.NoDelay, noDelay)
+		}
+		if opts.SendBuf <= 0 || opts.RecvBuf <= 0 {
+			t.Errorf("unexpected buffer sizes %d/%d", opts.SendBuf, opts.RecvBuf)
+		}
+	}
+
+	// ToMap reports the options read on open, which remain after the socket is closed.
+	_ = c.Close()
+	if m, ok := c.ToMap()["socketOptions"].(map[string]any); !ok || m["noDelay"] != true {
+		t.Errorf("unexpected socketOptions in ToMap: %v", m)
+	}
+}
+
+func TestSocketOptionsTLS(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("socket options are not supported on this platform")
+	}
+	client, _ := loopbackPair(t)
+	c := WrapConn(tls.Client(client, &tls.Config{InsecureSkipVerify: true}), nil).(*Conn)
+	defer c.Close()
+
+	// The setters and getters must reach the socket beneath the tls.Conn.
+	if err := c.SetKeepAlive(true, 0, 0, 0); err != nil {
+		t.Fatalf("SetKeepAlive: %v", err)
+	}
+	opts, err := c.SocketOptions()
+	if err != nil {
+		t.Fatalf("SocketOptions: %v", err)
+	}
+	if opts.SendBuf <= 0 || opts.RecvBuf <= 0 {
+		t.Errorf("unexpected buffer sizes %d/%d", opts.SendBuf, opts.RecvBuf)
+	}
+}
+
+// errConn is a net.Conn whose reads and writes fail with err.
+type errConn struct {
+	net.Conn
+	err error
+}
+
+func (c *errConn) Read([]byte) (int, error)  { return 0, c.err }
+func (c *errConn) Write([]byte) (int, error) { return 0, c.err }
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestWriteErrors(t *testing.T) {
+	client, server := net.Pipe()
+	defer client.Close()
+	defer server.Close()
+
+	c := WrapConn(&errConn{Conn: client, err: timeoutError{}}, nil).(*Conn)
+	if _, err := c.Write([]byte("hello")); err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if _, err := c.Read(make([]byte, 5)); err == nil {
+		t.Fatal("expected a timeout error")
+	}
+	if c.TxErr != nil || c.RxErr != nil {
+		t.Errorf("timeouts were recorded: TxErr=%v RxErr=%v", c.TxErr, c.RxErr)
+	}
+
+	// Other errors are recorded, whether or not they are a net.Error.
+	for _, want := range []error{errors.New("broken"), &net.OpError{Op: "write", Err: syscall.EPIPE}} {
+		c = WrapConn(&errConn{Conn: client, err: want}, nil).(*Conn)
+		if _, err := c.Write([]byte("hello")); err != want {
+			t.Fatalf("Write returned %v, want %v", err, want)
+		}
+		if c.TxErr != want {
+			t.Errorf("TxErr = %v, want %v", c.TxErr, want)
+		}
+	}
+}
+
+func TestWarnings(t *testing.T) {
+	c := &Conn{
+		Reconnects: 2,
+		OpenedInfo: &tcpinfo.Info{},
+		ClosedInfo: &tcpinfo.Info{Retransmits: 3, Sys: &tcpinfo.SysInfo{}},
+	}
+	warns := c.Warnings()
+	want := []string{"reconnects=2", "retransmits=3"}
+	if len(warns) != len(want) {
+		t.Fatalf("unexpected warnings: %v", warns)
+	}
+	for i := range want {
+		if warns[i] != want[i] {
+			t.Fatalf("unexpected warnings: %v", warns)
+		}
+	}
+}
+
+func TestInfoDelta(t *testing.T) {
+	c := &Conn{}
+	if d := c.InfoDelta(); d != nil {
+		t.Fatalf("expected nil delta without snapshots, got %v", d)
+	}
+	c.OpenedInfo = &tcpinfo.Info{State: "ESTABLISHED", RTT: time.Millisecond}
+	if d := c.InfoDelta(); d["rtt"] != time.Millisecond {
+		t.Fatalf("expected the opened snapshot, got %v", d)
+	}
+	c.ClosedInfo = &tcpinfo.Info{State: "CLOSE", RTT: 2 * time.Millisecond}
+	if d := c.InfoDelta(); d["state"] != "CLOSE" || d["rtt"] != 2*time.Millisecond {
+		t.Fatalf("expected final gauge values, got %v", d)
+	}
+}
+
+func TestReadWriteCalls(t *testing.T) {
+	client, server := loopbackPair(t)
+	c := WrapConn(client, nil).(*Conn)
+	defer c.Close()
+
+	for _, size := range []int{1, 1, 100, 10} {
+		if _, err := c.Write(make([]byte, size)); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	}
+	if _, err := server.Write([]byte("hello")); err != nil {
+		t.Fatalf("server write: %v", err)
+	}
+	if _, err := io.ReadFull(c, make([]byte, 5)); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+
+	m := c.ToMap()
+	if m["writeCalls"] != int64(4) || m["minWrite"] != 1 || m["maxWrite"] != 100 {
+		t.Errorf("unexpected write stats: calls=%v min=%v max=%v", m["writeCalls"], m["minWrite"], m["maxWrite"])
+	}
+	if calls, _ := m["readCalls"].(int64); calls < 1 || calls > 5 {
+		t.Errorf("unexpected readCalls: %v", m["readCalls"])
+	}
+}
+
+func TestConnTelemetryAccessors(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	client, server := loopbackPair(t)
+	go func() { _, _ = io.Copy(io.Discard, server) }()
+	c := WrapConn(client, func(*Conn, int) {}).(*Conn)
+	if _, err := c.Write(make([]byte, 4096)); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+
+	if rtt, ok := c.RTT(); !ok || rtt <= 0 {
+		t.Errorf("RTT() = %v, %v; want a positive RTT", rtt, ok)
+	}
+	if cwnd, ok := c.CongestionWindow(); !ok || cwnd == 0 {
+		t.Errorf("CongestionWindow() = %v, %v; want a positive window", cwnd, ok)
+	}
+
+	// After Close the accessors fall back to ClosedInfo.
+	_ = c.Close()
+	if rtt, ok := c.RTT(); !ok || rtt != c.ClosedInfo.RTT {
+		t.Errorf("RTT() after close = %v, %v; want ClosedInfo.RTT %v", rtt, ok, c.ClosedInfo.RTT)
+	}
+}
+
+func TestReset(t *testing.T) {
+	first, _ := loopbackPair(t)
+	second, secondPeer := loopbackPair(t)
+
+	var reports []string
+	c := WrapConn(first, func(c *Conn, state int) {
+		reports = append(reports, StateMap[state]+":"+c.LocalAddr().String())
+	}).(*Conn)
+	c.SetReconnects(1)
+	if _, err := c.Write([]byte("hello")); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("close: %v", err)
+	}
+
+	c.Reset(second)
+	if c.Reconnects != 2 {
+		t.Errorf("Reconnects = %d, want 2", c.Reconnects)
+	}
+	if c.TxBytes != 0 || c.WriteCalls != 0 || c.FirstTxAt != 0 || c.ClosedAt != 0 || c.ClosedInfo != nil {
+		t.Errorf("per-connection stats not reset: %+v", c)
+	}
+	if tcpinfo.Supported() && c.OpenedInfo == nil {
+		t.Errorf("expected OpenedInfo for the new connection")
+	}
+
+	if _, err := c.Write([]byte("hi")); err != nil {
+		t.Fatalf("write after reset: %v", err)
+	}
+	buf := make([]byte, 2)
+	if _, err := io.ReadFull(secondPeer, buf); err != nil || string(buf) != "hi" {
+		t.Fatalf("peer read = %q, %v", buf, err)
+	}
+	if c.TxBytes != 2 {
+		t.Errorf("TxBytes = %d, want 2", c.TxBytes)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatalf("close after reset: %v", err)
+	}
+
+	want := []string{
+		"open:" + first.LocalAddr().String(),
+		"close:" + first.LocalAddr().String(),
+		"open:" + second.LocalAddr().String(),
+		"close:" + second.LocalAddr().String(),
+	}
+	if !reflect.DeepEqual(reports, want) {
+		t.Errorf("reports = %v, want %v", reports, want)
+	}
+}
+
+func TestResetWaitsForSampler(t *testing.T) {
+	if !tcpinfo.Supported() {
+		t.Skip("tcpinfo is not supported on this platform")
+	}
+	first, _ := loopbackPair(t)
+	second, _ := loopbackPair(t)
+	c := WrapConn(first, nil).(*Conn)
+	defer c.Close()
+
+	stop := c.StartSampling(context.Background(), time.Millisecond)
+	defer stop()
+	deadline := time.Now().Add(time.Second)
+	for len(c.Samples()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+	if len(c.Samples()) == 0 {
+		t.Fatal("expected samples before Reset")
+	}
+
+	// Once Reset returns, the old sampler has exited, so no sample of first can appear.
+	c.Reset(second)
+	time.Sleep(10 * time.Millisecond)
+	if n := len(c.Samples()); n != 0 {
+		t.Errorf("got %d samples after Reset, want 0", n)
+	}
+}